@@ -4,8 +4,12 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/mvptianyu/aihub/ssestream"
+	uuid "github.com/satori/go.uuid"
 	"io"
+	"log"
+	"slices"
 	"sync"
 	"time"
 )
@@ -13,8 +17,14 @@ import (
 type agent struct {
 	cfg           *AgentConfig
 	memory        IMemory
+	longTerm      *longTermMemory
+	sessionStore  ISessionStore
 	toolFunctions []ToolFunction
+	guardrails    []*guardrailIns
 	lock          sync.RWMutex
+
+	toolSem  chan struct{}            // agent内所有工具调用共享的并发限制
+	toolSems map[string]chan struct{} // toolName => agent内该工具的并发限制
 }
 
 func newAgent(cfg *AgentConfig) (IAgent, error) {
@@ -22,22 +32,57 @@ func newAgent(cfg *AgentConfig) (IAgent, error) {
 		return nil, err
 	}
 
+	// 校验系统提示词模板
+	var err error
+	if err = checkPromptTemplate(cfg.SystemPrompt, cfg.PromptFragments); err != nil {
+		return nil, err
+	}
+
+	guardrails, err := newGuardrails(cfg.Guardrails)
+	if err != nil {
+		return nil, err
+	}
+
+	mem, err := newMemoryByConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
 	ag := &agent{
-		cfg:    cfg,
-		memory: newMemory(&cfg.AgentRuntimeCfg),
+		cfg:        cfg,
+		memory:     mem,
+		guardrails: guardrails,
+		toolSems:   make(map[string]chan struct{}),
+		toolSem:    newSemaphore(cfg.MaxToolConcurrency),
+	}
+	if cfg.Memory != nil && cfg.Memory.LongTerm != nil {
+		if ag.longTerm, err = newLongTermMemory(cfg.Memory.LongTerm); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.SessionStore != nil {
+		if ag.sessionStore, err = newSessionStore(cfg.SessionStore); err != nil {
+			return nil, err
+		}
 	}
 	return ag, nil
 }
 
 // getSystemMsg 获取系统消息
 func (a *agent) getSystemMsg(opts *RunOptions) *Message {
-	if a.cfg.SystemPrompt == "" {
+	content := ""
+	if a.cfg.SystemPrompt != "" {
+		content = opts.UpdateSystemPrompt(a.cfg.SystemPrompt)
+	}
+	content += formatRecalls(opts.GetRecalls())     // 长期记忆
+	content += formatKnowledge(opts.GetKnowledge()) // 知识库
+	if content == "" {
 		return nil
 	}
 
 	return &Message{
 		Role:    MessageRoleSystem,
-		Content: opts.UpdateSystemPrompt(a.cfg.SystemPrompt),
+		Content: content,
 	}
 }
 
@@ -56,31 +101,58 @@ func (a *agent) ResetMemory(ctx context.Context, opts ...RunOptionFunc) error {
 	return nil
 }
 
+func (a *agent) GetMemory() IMemory {
+	return a.memory
+}
+
+func (a *agent) Close() error {
+	return a.memory.Close()
+}
+
 func (a *agent) Run(ctx context.Context, input string, opts ...RunOptionFunc) (ret *Response) {
 	ret = &Response{}
-	LLMIns := GetLLMHub().GetLLM(a.cfg.LLM)
+	options := a.newRunOptions()
+	for _, opt := range opts {
+		opt(options)
+	}
+	if err := a.fixRunOptions(options); err != nil {
+		ret.Err = err
+		return
+	}
+
+	LLMIns := GetLLMHub().GetLLM(options.RuntimeCfg.LLM)
 	if LLMIns == nil {
 		ret.Err = ErrConfiguration
 		return
 	}
 
-	options := a.newRunOptions()
-	for _, opt := range opts {
-		opt(options)
+	// 检测嵌套调用的循环及深度
+	var err error
+	if ctx, err = enterCallChain(ctx, a.cfg.Name, &options.RuntimeCfg); err != nil {
+		ret.Err = err
+		return
 	}
 
+	ret.RunID = options.RunID
 	ret.Session = options.Session
 	ctx = ContextWithSession(ctx, options.Session) // 绑定重设ctx
-	newCtx, cancel := context.WithTimeout(ctx, time.Duration(options.RuntimeCfg.RunTimeout)*time.Second)
-	defer cancel()
-
-	userMsg := &Message{
-		Role:    MessageRoleUser,
-		Content: input,
+	ctx = contextWithRunOptions(ctx, options)
+	cancelCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	newCtx, cancelTimeout := context.WithTimeoutCause(cancelCtx, time.Duration(options.RuntimeCfg.RunTimeout)*time.Second, ErrAgentRunTimeout)
+	defer cancelTimeout()
+	options.ctx = newCtx
+
+	// 登记运行状态，支持外部查询和取消
+	registry := GetAgentHub().GetRunRegistry().(*runRegistry)
+	if err = registry.register(a, input, options, cancel); err != nil {
+		ret.Err = err
+		return
 	}
-	a.memory.Push(options, userMsg)
+	defer registry.unregister(options.RunID)
+	finish := registry.detach(options.RunID)
 
-	var doneCh = make(chan bool)
+	var doneCh = make(chan *Response, 1) // 带缓冲，超时或取消后协程仍可正常退出
 	var endStep = &RunStep{
 		StepType: StepType_End,
 		State:    RunState_Idle,
@@ -92,92 +164,188 @@ func (a *agent) Run(ctx context.Context, input string, opts ...RunOptionFunc) (r
 	})
 
 	go func() {
-		defer func() {
-			doneCh <- true
-		}()
-
-		for {
-			// 超过最大步数跳出
-			if options.CheckStepQuit() {
-				ret.Err = ErrChatCompletionOverMaxStep
-				return
-			}
+		defer finish() // 超时或取消后协程仍在执行时，关闭agent需等待其退出
+		inner := a.runLoop(newCtx, input, options, LLMIns)
+		a.saveSession(options) // 主循环结束后再保存，超时或取消时不写入执行中的会话快照
+		doneCh <- inner
+	}()
 
-			messages := make([]*Message, 0)
-			messages = append(messages, a.getSystemMsg(options))        // system
-			messages = append(messages, a.memory.GetLatest(options)...) // latest N
-
-			req := &CreateChatCompletionReq{
-				Messages:         messages,
-				Tools:            a.getToolCfg(),
-				MaxTokens:        options.RuntimeCfg.MaxTokens,
-				FrequencyPenalty: options.RuntimeCfg.FrequencyPenalty,
-				PresencePenalty:  options.RuntimeCfg.PresencePenalty,
-				Temperature:      options.RuntimeCfg.Temperature,
-			}
+	select {
+	case <-newCtx.Done():
+		ret.Err = context.Cause(newCtx)
+	case inner := <-doneCh:
+		ret.Message = inner.Message
+		ret.Err = inner.Err
+	}
 
-			// 结束词规则
-			if options.RuntimeCfg.StopWords != "" {
-				req.Stop = options.RuntimeCfg.StopWords
-			}
+	if ret.Err != nil {
+		endStep.State = RunState_Failed
+		errors.As(ret.Err, &ret.Guardrail)
+	} else if ret.Message != nil {
+		endStep.Result = ret.Message.Content
+	}
+	options.AddStep(endStep)
+	ret.Content = options.RenderFinalAnswer()
+	ret.Steps = options.GetSteps()
+	ret.Citations = options.GetCitations()
+	return ret
+}
+
+// runLoop 执行对话主循环：输入护栏 => LLM/工具调用循环 => 输出护栏
+func (a *agent) runLoop(ctx context.Context, input string, options *RunOptions, LLMIns ILLM) (ret *Response) {
+	ret = &Response{}
+
+	// 输入护栏检查
+	if ret.Err = a.checkGuardrails(ctx, GuardrailStage_Input, &input, options); ret.Err != nil {
+		return
+	}
+
+	userMsg := &Message{
+		Role:    MessageRoleUser,
+		Content: input,
+	}
+	a.memory.Push(options, userMsg)
 
-			rsp, err1 := LLMIns.CreateChatCompletion(newCtx, req)
+	// 检索长期记忆，注入系统提示词
+	if a.longTerm != nil {
+		recalls, err := a.longTerm.Recall(ctx, options, input)
+		if err != nil {
+			log.Printf("agent::runLoop recall long term memory failed => runID:%s, err:%v\n", options.RunID, err)
+		}
+		options.setRecalls(recalls)
+	}
+	a.retrieveKnowledge(ctx, options, input)
+
+	reflectRounds := 0
+	pending := make([]*Message, 0)      // 首次评审未通过后本次运行的消息，暂不写入记忆
+	rejected := make(map[*Message]bool) // 未通过评审的草稿及评审意见，不写入记忆
+	push := func(msgs ...*Message) {
+		if len(pending) > 0 {
+			pending = append(pending, msgs...)
+			return
+		}
+		a.memory.Push(options, msgs...)
+	}
+	for {
+		// 已超时或被取消跳出
+		if ctx.Err() != nil {
+			ret.Err = context.Cause(ctx)
+			return
+		}
+
+		// 超过最大步数跳出
+		if options.CheckStepQuit() {
+			ret.Err = ErrChatCompletionOverMaxStep
+			return
+		}
+
+		messages := make([]*Message, 0)
+		messages = append(messages, a.getSystemMsg(options))        // system
+		messages = append(messages, a.memory.GetLatest(options)...) // latest N
+		messages = append(messages, pending...)
+
+		req := &CreateChatCompletionReq{
+			Messages:         messages,
+			Tools:            a.getToolCfg(options),
+			MaxTokens:        options.RuntimeCfg.MaxTokens,
+			FrequencyPenalty: options.RuntimeCfg.FrequencyPenalty,
+			PresencePenalty:  options.RuntimeCfg.PresencePenalty,
+			Temperature:      options.RuntimeCfg.Temperature,
+		}
+
+		// 结束词规则
+		if options.RuntimeCfg.StopWords != "" {
+			req.Stop = options.RuntimeCfg.StopWords
+		}
+
+		rsp, err1 := createChatCompletion(ctx, LLMIns, req)
+		if err1 != nil {
+			ret.Err = err1
+			return
+		}
+
+		if rsp.Error != nil {
+			ret.Err = errors.New(rsp.Error.Message)
+			return
+		}
+
+		choice := rsp.Choices[0]
+		switch choice.FinishReason {
+		case ChatCompletionRspFinishReasonToolCalls:
+			// 处理tool调用
+			push(choice.Message)
+			toolMsgs, err1 := a.processToolCalls(ctx, choice.Message, options)
 			if err1 != nil {
 				ret.Err = err1
 				return
 			}
-
-			if rsp.Error != nil {
-				ret.Err = errors.New(rsp.Error.Message)
-				return
-			}
-
-			choice := rsp.Choices[0]
-			a.memory.Push(options, choice.Message)
-
-			switch choice.FinishReason {
-			case ChatCompletionRspFinishReasonToolCalls:
-				// 处理tool调用
-				toolMsgs, err1 := a.processToolCalls(newCtx, choice.Message, options)
+			push(toolMsgs...)
+		default:
+			// 反思评审，不通过时附带评审意见退回修正
+			if a.cfg.Reflection != nil && reflectRounds < a.cfg.Reflection.MaxRounds {
+				reflectRounds++
+				critique, err1 := a.critique(ctx, input, choice.Message.Content, options, reflectRounds)
 				if err1 != nil {
 					ret.Err = err1
 					return
 				}
-				a.memory.Push(options, toolMsgs...)
-			default:
-				ret.Message = choice.Message
-				endStep.Result = choice.Message.Content
-				return
+				if !critique.Pass {
+					critic := &Message{
+						Role:    MessageRoleUser,
+						Name:    "critic",
+						Content: fmt.Sprintf(reflectionFeedbackTpl, critique.Feedback),
+					}
+					rejected[choice.Message], rejected[critic] = true, true
+					pending = append(pending, choice.Message, critic)
+					continue
+				}
 			}
-		}
-	}()
 
-	select {
-	case <-newCtx.Done():
-		ret.Err = ErrAgentRunTimeout
-	case <-doneCh:
-	}
+			// 输出护栏检查，通过后才写入记忆，避免被拦截的内容进入后续上下文
+			if ret.Err = a.checkGuardrails(ctx, GuardrailStage_Output, &choice.Message.Content, options); ret.Err != nil {
+				return
+			}
+			msgs := make([]*Message, 0, len(pending)+1)
+			for _, msg := range pending {
+				if !rejected[msg] {
+					msgs = append(msgs, msg)
+				}
+			}
+			a.memory.Push(options, append(msgs, choice.Message)...)
+			ret.Message = choice.Message
 
-	if ret.Err != nil {
-		endStep.State = RunState_Failed
+			// 写入长期记忆
+			if a.longTerm != nil {
+				if err := a.longTerm.Remember(ctx, options, input, choice.Message.Content); err != nil {
+					log.Printf("agent::runLoop remember long term memory failed => runID:%s, err:%v\n", options.RunID, err)
+				}
+			}
+			return
+		}
 	}
-	options.AddStep(endStep)
-	ret.Content = options.RenderFinalAnswer()
-	return ret
 }
 
 func (a *agent) RunStream(ctx context.Context, input string, opts ...RunOptionFunc) (stream *ssestream.StreamReader[Response]) {
+	return runStream(ctx, func() *Response {
+		return a.Run(ctx, input, opts...)
+	})
+}
+
+// runStream 执行run并将最终结果分块流式返回
+func runStream(ctx context.Context, run func() *Response) (stream *ssestream.StreamReader[Response]) {
 	var err error
 	r, w := io.Pipe()
 	writer := ssestream.NewStreamWriter[Response](ssestream.NewEncoder(w), ctx)
 	stream = ssestream.NewStreamReader[Response](ssestream.NewDecoder(r), err)
 
 	go func() {
-		rsp := a.Run(ctx, input, opts...)
+		rsp := run()
 		if rsp.Err != nil {
-			writer.Append(&Response{
+			block := &Response{
 				Err: rsp.Err,
-			})
+			}
+			block.Guardrail = rsp.Guardrail
+			writer.Append(block)
 			time.Sleep(30 * time.Millisecond)
 			writer.Close()
 			return
@@ -209,6 +377,9 @@ func (a *agent) GetToolFunctions() []ToolFunction {
 	}
 
 	a.toolFunctions = make([]ToolFunction, 0)
+	if a.cfg.Knowledge != nil && a.cfg.Knowledge.Mode == KnowledgeMode_Tool {
+		a.toolFunctions = append(a.toolFunctions, newKnowledgeToolFunction(a.cfg.Knowledge))
+	}
 	// 全局tool白名单
 	if a.cfg.Tools == nil || len(a.cfg.Tools) <= 0 {
 		return a.toolFunctions
@@ -222,14 +393,32 @@ func (a *agent) GetToolFunctions() []ToolFunction {
 		a.toolFunctions = append(a.toolFunctions, GetToolHub().GetToolFunctions(a.cfg.Tools...)...)
 	}
 
+	a.toolFunctions = a.hideToolSessionArgs(a.toolFunctions)
 	return a.toolFunctions
 }
 
+// hideToolSessionArgs 按agent配置移除工具中session绑定的参数
+func (a *agent) hideToolSessionArgs(toolFunctions []ToolFunction) []ToolFunction {
+	for i := range toolFunctions {
+		if bindings, ok := a.cfg.ToolSessionArgs[toolFunctions[i].Name]; ok {
+			toolFunctions[i].Parameters = hideToolSessionArgs(toolFunctions[i].Parameters, bindings)
+		}
+	}
+	return toolFunctions
+}
+
 func (a *agent) newRunOptions() *RunOptions {
 	options := &RunOptions{
+		RunID:      uuid.NewV4().String(),
 		RuntimeCfg: a.cfg.AgentRuntimeCfg,
 		Tools:      a.getRelatedToolBriefInfos(),
 		Session:    newSession(a.cfg.SessionData),
+		PromptVars: make(map[string]interface{}),
+
+		promptFragments: a.cfg.PromptFragments,
+	}
+	for key, value := range a.cfg.PromptVars {
+		options.PromptVars[key] = value
 	}
 	return options
 }
@@ -244,11 +433,73 @@ func (a *agent) getRelatedToolBriefInfos() []BriefInfo {
 	return ret
 }
 
-func (a *agent) getToolCfg() []*Tool {
-	toolFunctions := a.GetToolFunctions()
+// loadSession 从会话数据存储加载已有会话的数据，优先级：WithSessionData > 已保存数据 > 配置默认值
+func (a *agent) loadSession(opts *RunOptions) error {
+	if a.sessionStore == nil || !opts.sessionIDSet {
+		return nil
+	}
 
+	data, err := a.sessionStore.Load(opts.GetSessionID())
+	if err != nil || data == nil {
+		return err
+	}
+	opts.Session.MergeSessionData(data)
+	opts.Session.MergeSessionData(opts.sessionOverrides)
+	return nil
+}
+
+// saveSession 运行结束后将会话数据写回存储，自动生成的会话ID不保存
+func (a *agent) saveSession(opts *RunOptions) {
+	if a.sessionStore == nil || !opts.sessionIDSet {
+		return
+	}
+
+	ttl := a.cfg.SessionStore.TTL
+	if ttl <= 0 {
+		ttl = opts.RuntimeCfg.MemoryTimeout
+	}
+	if err := a.sessionStore.Save(opts.GetSessionID(), opts.CopySessionData(), time.Duration(ttl)*time.Second); err != nil {
+		log.Printf("agent::saveSession failed => sessionID:%s, err:%v\n", opts.GetSessionID(), err)
+	}
+}
+
+// fixRunOptions 选项设置完成后修正运行时配置及本次可用工具
+func (a *agent) fixRunOptions(opts *RunOptions) error {
+	if opts.RuntimeCfg.LLM == "" {
+		opts.RuntimeCfg.LLM = a.cfg.LLM
+	}
+	if err := opts.RuntimeCfg.AutoFix(); err != nil {
+		return err
+	}
+	if err := a.loadSession(opts); err != nil {
+		return err
+	}
+
+	if opts.toolNames == nil {
+		opts.toolFunctions = a.GetToolFunctions()
+		return nil
+	}
+
+	// 按本次指定的工具集重新获取
+	opts.toolFunctions = make([]ToolFunction, 0)
+	if len(opts.toolNames) > 0 {
+		opts.toolFunctions = append(opts.toolFunctions, GetMCPHub().GetToolFunctions(a.cfg.Mcps, opts.toolNames)...)
+		opts.toolFunctions = append(opts.toolFunctions, GetToolHub().GetToolFunctions(opts.toolNames...)...)
+		opts.toolFunctions = a.hideToolSessionArgs(opts.toolFunctions)
+		if a.cfg.Knowledge != nil && a.cfg.Knowledge.Mode == KnowledgeMode_Tool && slices.Contains(opts.toolNames, a.cfg.Knowledge.ToolName) {
+			opts.toolFunctions = append(opts.toolFunctions, newKnowledgeToolFunction(a.cfg.Knowledge))
+		}
+	}
+	opts.Tools = make([]BriefInfo, 0)
+	for _, item := range opts.toolFunctions {
+		opts.Tools = append(opts.Tools, item.BriefInfo)
+	}
+	return nil
+}
+
+func (a *agent) getToolCfg(opts *RunOptions) []*Tool {
 	ret := make([]*Tool, 0)
-	for _, item := range toolFunctions {
+	for _, item := range opts.toolFunctions {
 		ret = append(ret, &Tool{
 			Type:     ToolTypeFunction,
 			Function: item,
@@ -276,6 +527,7 @@ func (a *agent) processToolCalls(ctx context.Context, req *Message, opts *RunOpt
 	wg := sync.WaitGroup{}
 	wg.Add(cnt)
 	rsp = make([]*Message, cnt)
+	errs := make([]error, cnt)
 	steps := make([]*RunStep, cnt)
 	for i := 0; i < cnt; i++ {
 		toolCall := req.ToolCalls[i]
@@ -296,16 +548,37 @@ func (a *agent) processToolCalls(ctx context.Context, req *Message, opts *RunOpt
 		go func(i int, toolCall *MessageToolCall) {
 			defer wg.Done()
 
-			err1 := a.InvokeToolCall(ctx, toolCall.Function.Name, toolCall.Function.Arguments, rsp[i])
-			steps[i].Result = rsp[i].Content
-			steps[i].State = RunState_Failed
+			err1 := opts.checkToolAllowed(toolCall.Function.Name)
 			if err1 == nil {
-				steps[i].State = RunState_Succeed
+				err1 = a.InvokeToolCall(ctx, toolCall.Function.Name, toolCall.Function.Arguments, rsp[i])
+			} else {
+				rsp[i].Content = err1.Error()
 			}
+			errs[i] = err1
+			state := RunState_Failed
+			if err1 == nil {
+				state = RunState_Succeed
+			}
+			opts.updateStep(steps[i], rsp[i].Content, state)
 		}(i, toolCall)
 	}
 	wg.Wait()
 
+	// 入参校验失败，反馈给模型修正，单次调用超出修正次数则退出
+	for i := 0; i < cnt; i++ {
+		argErr := &ToolArgumentsError{}
+		if !errors.As(errs[i], &argErr) {
+			opts.resetToolRepair(req.ToolCalls[i].Function.Name)
+			continue
+		}
+		argErr.Remaining = opts.addToolRepair(argErr.Tool)
+		if argErr.Remaining < 0 {
+			return nil, fmt.Errorf("%w: %s", ErrToolArgumentsRepairExceeded, argErr.Tool)
+		}
+		rsp[i].Content = argErr.Error()
+		opts.updateStep(steps[i], rsp[i].Content, RunState_Failed)
+	}
+
 	// 判断加入SessionKey
 	for i := 0; i < cnt; i++ {
 		toolCall := req.ToolCalls[i]
@@ -329,22 +602,64 @@ func (a *agent) processToolCalls(ctx context.Context, req *Message, opts *RunOpt
 
 // InvokeToolCall 处理本步骤toolCall
 func (a *agent) InvokeToolCall(ctx context.Context, name string, args string, output *Message) (err error) {
-	// 1.MCP调用
-	err = GetMCPHub().ProxyCall(ctx, name, args, output)
-	if errors.Is(err, ErrCallNameNotMatch) {
-		// 2.ToolCall本地调用
-		err = GetToolHub().ProxyCall(ctx, name, args, output)
+	// 按agent配置填充session绑定的参数
+	if args, err = injectToolSessionArgs(args, a.cfg.ToolSessionArgs[name], SessionFromContext(ctx)); err != nil {
+		output.Content = err.Error()
+		return
 	}
 
+	err = invokeWithPolicy(ctx, a.getToolPolicy(name), a.getToolSemaphores(name), func(ctx context.Context, output *Message) (err error) {
+		// 0.知识库检索
+		if a.cfg.Knowledge != nil && a.cfg.Knowledge.Mode == KnowledgeMode_Tool && name == a.cfg.Knowledge.ToolName {
+			return a.invokeKnowledgeTool(ctx, args, output)
+		}
+
+		// 1.MCP调用
+		err = GetMCPHub().ProxyCall(ctx, name, args, output)
+		if errors.Is(err, ErrCallNameNotMatch) {
+			// 2.ToolCall本地调用
+			err = GetToolHub().ProxyCall(ctx, name, args, output)
+		}
+		return
+	}, output)
+
 	if err != nil {
 		output.Content = err.Error()
 		return
 	}
 
-	if output.Content == "" {
+	if output.Content == "" && len(output.MultiContent) == 0 {
 		err = ErrToolCallResponseEmpty
 		output.Content = err.Error()
 	}
 
 	return
 }
+
+// getToolPolicy 获取工具调用策略，agent配置覆盖工具注册时的默认策略
+func (a *agent) getToolPolicy(name string) ToolPolicy {
+	policy, _ := getToolPolicy(name)
+	if tmp, ok := a.cfg.ToolPolicies[name]; ok {
+		policy.MergeWith(&tmp)
+	}
+	return policy
+}
+
+// getToolSemaphores 获取工具调用需依次获取的信号量：进程内整体限制、agent整体限制、工具注册时的限制、agent内该工具限制
+func (a *agent) getToolSemaphores(name string) []chan struct{} {
+	_, sem := getToolPolicy(name)
+	return []chan struct{}{getGlobalToolSemaphore(), a.toolSem, sem, a.getAgentToolSemaphore(name)}
+}
+
+// getAgentToolSemaphore 获取agent内该工具的信号量，首次获取时按配置创建
+func (a *agent) getAgentToolSemaphore(name string) chan struct{} {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if sem, ok := a.toolSems[name]; ok {
+		return sem
+	}
+
+	sem := newSemaphore(a.cfg.ToolPolicies[name].MaxConcurrency)
+	a.toolSems[name] = sem
+	return sem
+}
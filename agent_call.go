@@ -0,0 +1,174 @@
+/*
+@Project: aihub
+@Module: aihub
+@File : agent_call.go
+*/
+package aihub
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"sync"
+)
+
+// =======AgentCall注册==========
+const AgentCallFuncName = "AgentCall"
+
+type AgentCallReq struct {
+	ToolInputBase
+
+	Action   string           `json:"_action_,omitempty" yaml:"_action_,omitempty" description:"调用的agent名称，单个任务时必填"`                      // 调用的agent名称
+	Question string           `json:"_question_,omitempty" yaml:"_question_,omitempty" description:"调用agent的输入提示词，单个任务时必填"`               // 调用agent的输入
+	Tasks    []*AgentCallTask `json:"_tasks_,omitempty" yaml:"_tasks_,omitempty" description:"多个相互独立、可并行执行的子任务，设置时忽略_action_和_question_"` // 并行子任务
+}
+
+// AgentCallTask 并行调度的子任务
+type AgentCallTask struct {
+	Action   string `json:"_action_" yaml:"_action_" description:"该子任务调用的agent名称" required:"true"`        // 调用的agent名称
+	Question string `json:"_question_" yaml:"_question_" description:"该子任务调用agent的输入提示词" required:"true"` // 调用agent的输入
+}
+
+// AgentCallResult 并行子任务结果，按子任务顺序返回
+type AgentCallResult struct {
+	Action   string `json:"_action_"`
+	Question string `json:"_question_"`
+	Result   string `json:"_result_,omitempty"`
+	Error    string `json:"_error_,omitempty"`
+}
+
+// AgentCall 调用其他agent能力的工具，需由使用方注册到ToolHub后在agent的tools中引用
+func AgentCall(ctx context.Context, input *AgentCallReq, output *Message) (err error) {
+	if len(input.Tasks) > 0 {
+		return agentCallParallel(ctx, input.Tasks, output)
+	}
+
+	parent := SessionFromContext(ctx)
+	ret := runSubAgent(ctx, input.Action, input.Question, parent)
+	if ret.session != nil && parent != nil {
+		parent.MergeSessionData(ret.session)
+	}
+	output.Content = ret.content
+	return ret.err
+}
+
+// agentCallParallel 并发执行子任务，结果及session变更按子任务顺序合并
+func agentCallParallel(ctx context.Context, tasks []*AgentCallTask, output *Message) (err error) {
+	parent := SessionFromContext(ctx)
+	limit := 0
+	if opts := runOptionsFromContext(ctx); opts != nil {
+		limit = opts.RuntimeCfg.MaxAgentConcurrency
+	}
+
+	rets := dispatchSubAgents(ctx, tasks, parent, limit)
+	results := make([]*AgentCallResult, 0, len(rets))
+	for idx, ret := range rets {
+		item := &AgentCallResult{
+			Action:   tasks[idx].Action,
+			Question: tasks[idx].Question,
+			Result:   ret.content,
+		}
+		if ret.err != nil {
+			item.Error = ret.err.Error()
+			if err == nil {
+				err = ret.err
+			}
+		}
+		if ret.session != nil && parent != nil {
+			parent.MergeSessionData(ret.session)
+		}
+		results = append(results, item)
+	}
+
+	bs, _ := json.Marshal(results)
+	output.Content = string(bs)
+
+	// 部分子任务成功时返回结果由模型自行判断，全部失败才返回错误
+	for _, item := range results {
+		if item.Error == "" {
+			return nil
+		}
+	}
+	return err
+}
+
+// subAgentResult 子agent运行结果
+type subAgentResult struct {
+	content string
+	session map[string]interface{} // 子运行中新增或变更的session数据
+	err     error
+}
+
+// dispatchSubAgents 按并发上限并行运行子agent，结果顺序与tasks一致
+func dispatchSubAgents(ctx context.Context, tasks []*AgentCallTask, parent *Session, limit int) []*subAgentResult {
+	if limit <= 0 {
+		limit = 3
+	}
+
+	rets := make([]*subAgentResult, len(tasks))
+	sem := make(chan struct{}, limit)
+	wg := sync.WaitGroup{}
+	for idx, task := range tasks {
+		wg.Add(1)
+		go func(idx int, task *AgentCallTask) {
+			defer wg.Done()
+			if err := acquireSemaphore(ctx, sem); err != nil {
+				rets[idx] = &subAgentResult{content: err.Error(), err: err}
+				return
+			}
+			defer releaseSemaphore(sem)
+			rets[idx] = runSubAgent(ctx, task.Action, task.Question, parent)
+		}(idx, task)
+	}
+	wg.Wait()
+	return rets
+}
+
+// runSubAgent 以独立的子session运行agent，仅回传子运行中新增或变更的session数据
+func runSubAgent(ctx context.Context, name string, question string, parent *Session) (ret *subAgentResult) {
+	ret = &subAgentResult{}
+	ag := GetAgentHub().GetAgent(name)
+	if ag == nil {
+		ret.content = "无可用匹配的Agent能力 => " + name
+		ret.err = ErrCallNameNotMatch
+		return
+	}
+
+	snapshot := make(map[string]interface{})
+	optionFuncs := []RunOptionFunc{
+		WithDebug(false),
+	}
+	if parent != nil {
+		snapshot = parent.CopySessionData()
+		optionFuncs = append(optionFuncs, WithSessionData(parent.CopySessionData()))
+	}
+
+	// 调用执行
+	rsp := ag.Run(ctx, question, optionFuncs...)
+	if rsp.Err != nil {
+		ret.content = rsp.Err.Error()
+		ret.err = rsp.Err
+		return
+	}
+
+	if rsp.Message != nil {
+		ret.content = rsp.Message.Content
+	}
+	if ret.content == "" {
+		ret.err = ErrToolCallResponseEmpty
+		ret.content = ErrToolCallResponseEmpty.Error()
+	}
+
+	if rsp.Session != nil {
+		for key, value := range rsp.Session.CopySessionData() {
+			if old, ok := snapshot[key]; ok && reflect.DeepEqual(old, value) {
+				continue
+			}
+			if ret.session == nil {
+				ret.session = make(map[string]interface{})
+			}
+			ret.session[key] = value
+		}
+	}
+	return
+}
@@ -2,6 +2,7 @@ package aihub
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
@@ -12,10 +13,14 @@ import (
 )
 
 type agentHub struct {
-	agents map[string]IAgent // agent Name => ILLM
-
-	mcpSrv IMCPServer
-	lock   sync.RWMutex
+	agents    map[string]IAgent                 // agent Name => IAgent
+	templates map[string]map[string]interface{} // template Name => 原始配置
+	fragments map[string]string                 // fragment Name => 提示词片段
+
+	mcpSrv      IMCPServer
+	runRegistry *runRegistry
+	lock        sync.RWMutex
+	tplLock     sync.RWMutex
 }
 
 func (h *agentHub) GetAllNameList() []string {
@@ -51,15 +56,62 @@ func (h *agentHub) GetAgent(name string) IAgent {
 	return nil
 }
 
+// DelAgent 注销agent，实例在运行中的请求结束后关闭
 func (h *agentHub) DelAgent(name string) error {
 	h.lock.Lock()
-	defer h.lock.Unlock()
+	ag, ok := h.agents[name]
 	delete(h.agents, name)
 	h.delMCPServerTool(name)
-	return nil
+	h.lock.Unlock()
+
+	if !ok {
+		return nil
+	}
+	if h.runRegistry.hasRuns(ag) {
+		go h.closeAfterDrain(name, ag)
+		return nil
+	}
+	return ag.Close()
+}
+
+// Close 取消运行中的请求，等待其退出后关闭所有agent
+func (h *agentHub) Close() error {
+	return h.shutdown(context.Background())
+}
+
+// shutdown 取消运行中的请求，等待其退出后关闭所有agent，ctx结束时不再等待直接关闭
+func (h *agentHub) shutdown(ctx context.Context) error {
+	h.runRegistry.cancelAll(ErrAgentRunCanceled)
+
+	h.lock.Lock()
+	agents := h.agents
+	h.agents = make(map[string]IAgent)
+	for name := range agents {
+		h.delMCPServerTool(name)
+	}
+	h.lock.Unlock()
+
+	errs := make([]error, 0)
+	for name, ag := range agents {
+		if err := h.runRegistry.waitDrain(ctx, ag); err != nil {
+			log.Printf("agentHub::shutdown wait runs drain failed => name:%s, err:%v\n", name, err)
+		}
+		if err := ag.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("agent %s => %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
 }
 
 func (h *agentHub) SetAgent(cfg *AgentConfig) (IAgent, error) {
+	if cfg.Extends != "" {
+		// 按继承链合并基础配置
+		var err error
+		if cfg, err = h.resolveAgentConfig(cfg); err != nil {
+			return nil, err
+		}
+	}
+
 	h.lock.Lock()
 	defer h.lock.Unlock()
 
@@ -67,12 +119,38 @@ func (h *agentHub) SetAgent(cfg *AgentConfig) (IAgent, error) {
 	if err != nil {
 		return nil, err
 	}
-	h.agents[cfg.Name] = ag
-	h.addMCPServerTool(ag) // 加入MCPServer
+	h.setAgentIns(ag)
 	return ag, err
 }
 
+// setAgentIns 登记agent实例，同名旧实例在运行中的请求结束后关闭，调用方需持有锁
+func (h *agentHub) setAgentIns(ag IAgent) {
+	name := ag.GetBriefInfo().Name
+	if old, ok := h.agents[name]; ok && old != ag {
+		if h.runRegistry.hasRuns(old) {
+			go h.closeAfterDrain(name, old)
+		} else {
+			h.closeAfterDrain(name, old)
+		}
+	}
+	h.agents[name] = ag
+	h.addMCPServerTool(ag) // 加入MCPServer
+}
+
+// closeAfterDrain 等待agent实例运行中的请求结束后关闭
+func (h *agentHub) closeAfterDrain(name string, ag IAgent) {
+	h.runRegistry.waitDrain(context.Background(), ag)
+	if err := ag.Close(); err != nil {
+		log.Printf("agentHub::closeAfterDrain close old agent failed => name:%s, err:%v\n", name, err)
+	}
+}
+
 func (h *agentHub) SetAgentByYamlData(yamlData []byte) (IAgent, error) {
+	yamlData, err := h.resolveAgentYamlData(yamlData)
+	if err != nil {
+		return nil, err
+	}
+
 	cfg, err := YamlDataToAgentConfig(yamlData)
 	if err != nil {
 		return nil, err
@@ -149,3 +227,7 @@ func (h *agentHub) delMCPServerTool(name string) {
 func (a *agentHub) GetMCPServer() IMCPServer {
 	return a.mcpSrv
 }
+
+func (a *agentHub) GetRunRegistry() IRunRegistry {
+	return a.runRegistry
+}
@@ -0,0 +1,210 @@
+/*
+@Project: aihub
+@Module: aihub
+@File : agent_template.go
+*/
+package aihub
+
+import (
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	configExtendsKey    = "extends"
+	configListAppendTag = "+" // 列表字段名后缀，例如 tools+: 表示追加到基础配置列表，否则替换
+)
+
+func (h *agentHub) SetAgentTemplate(cfg *AgentConfig) error {
+	bs, err := yaml.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+	return h.SetAgentTemplateByYamlData(bs)
+}
+
+func (h *agentHub) SetAgentTemplateByYamlData(yamlData []byte) error {
+	raw := make(map[string]interface{})
+	if err := yaml.Unmarshal(yamlData, &raw); err != nil {
+		log.Printf("Error AgentTemplate Unmarshal YAML data: %s => %v\n", string(yamlData), err)
+		return err
+	}
+
+	name, _ := raw["name"].(string)
+	if name == "" {
+		return ErrConfiguration
+	}
+
+	h.tplLock.Lock()
+	defer h.tplLock.Unlock()
+	h.templates[name] = raw
+	return nil
+}
+
+func (h *agentHub) SetAgentTemplateByYamlFile(yamlFile string) error {
+	// 读取 YAML 文件内容
+	yamlData, err := os.ReadFile(filepath.Clean(yamlFile))
+	if err != nil {
+		log.Printf("Error reading YAML file: %s => %v\n", yamlFile, err)
+		return err
+	}
+	return h.SetAgentTemplateByYamlData(yamlData)
+}
+
+func (h *agentHub) DelAgentTemplate(name string) error {
+	h.tplLock.Lock()
+	defer h.tplLock.Unlock()
+	delete(h.templates, name)
+	return nil
+}
+
+func (h *agentHub) SetPromptFragment(name string, content string) {
+	h.tplLock.Lock()
+	defer h.tplLock.Unlock()
+	h.fragments[name] = content
+}
+
+func (h *agentHub) GetPromptFragment(name string) (string, bool) {
+	h.tplLock.RLock()
+	defer h.tplLock.RUnlock()
+	tmp, ok := h.fragments[name]
+	return tmp, ok
+}
+
+// resolveAgentYamlData 按extends继承链合并基础配置，返回合并后的YAML数据
+func (h *agentHub) resolveAgentYamlData(yamlData []byte) ([]byte, error) {
+	raw := make(map[string]interface{})
+	if err := yaml.Unmarshal(yamlData, &raw); err != nil {
+		log.Printf("Error AgentConfig Unmarshal YAML data: %s => %v\n", string(yamlData), err)
+		return nil, err
+	}
+	if _, ok := raw[configExtendsKey]; !ok {
+		return yamlData, nil
+	}
+
+	h.tplLock.RLock()
+	merged, err := h.resolveTemplate(raw, nil)
+	h.tplLock.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(finalizeConfigMap(merged))
+}
+
+// resolveAgentConfig 按extends继承链合并基础配置，与YAML配置走同一合并逻辑；结构体零值视为未设置，需以false、0覆盖时使用YAML配置
+func (h *agentHub) resolveAgentConfig(cfg *AgentConfig) (*AgentConfig, error) {
+	node := &yaml.Node{}
+	if err := node.Encode(cfg); err != nil {
+		return nil, err
+	}
+	raw := make(map[string]interface{})
+	if err := node.Decode(&raw); err != nil {
+		return nil, err
+	}
+
+	h.tplLock.RLock()
+	merged, err := h.resolveTemplate(raw, nil)
+	h.tplLock.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+
+	node = &yaml.Node{}
+	if err = node.Encode(finalizeConfigMap(merged)); err != nil {
+		return nil, err
+	}
+	ret := &AgentConfig{}
+	if err = node.Decode(ret); err != nil {
+		return nil, fmt.Errorf("%w: extends %s => %w", ErrConfiguration, cfg.Extends, err)
+	}
+	return ret, nil
+}
+
+// resolveTemplate 递归合并继承链，chain用于检测循环继承
+func (h *agentHub) resolveTemplate(raw map[string]interface{}, chain []string) (map[string]interface{}, error) {
+	baseName, _ := raw[configExtendsKey].(string)
+	if baseName == "" {
+		return raw, nil
+	}
+
+	for _, name := range chain {
+		if name == baseName {
+			return nil, fmt.Errorf("%w: %s -> %s", ErrConfigExtendsCycle, strings.Join(chain, " -> "), baseName)
+		}
+	}
+
+	base, ok := h.templates[baseName]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrConfigExtendsNotFound, baseName)
+	}
+
+	resolvedBase, err := h.resolveTemplate(base, append(chain, baseName))
+	if err != nil {
+		return nil, err
+	}
+
+	// 名称和描述不继承
+	parent := make(map[string]interface{})
+	for key, value := range resolvedBase {
+		if key != configExtendsKey && key != "name" && key != "description" {
+			parent[key] = value
+		}
+	}
+	child := make(map[string]interface{})
+	for key, value := range raw {
+		if key != configExtendsKey {
+			child[key] = value
+		}
+	}
+	return mergeConfigMap(parent, child), nil
+}
+
+// mergeConfigMap 深度合并配置：map递归合并，列表默认替换，键名带+后缀时追加
+func mergeConfigMap(dst map[string]interface{}, src map[string]interface{}) map[string]interface{} {
+	ret := make(map[string]interface{})
+	for key, value := range dst {
+		ret[key] = value
+	}
+
+	for key, value := range src {
+		if strings.HasSuffix(key, configListAppendTag) {
+			realKey := strings.TrimSuffix(key, configListAppendTag)
+			list := make([]interface{}, 0)
+			if tmp, ok := ret[realKey].([]interface{}); ok {
+				list = append(list, tmp...)
+			} else if tmp, ok := ret[key].([]interface{}); ok {
+				list = append(list, tmp...) // 基础配置自身也是追加声明
+			}
+			if tmp, ok := value.([]interface{}); ok {
+				list = append(list, tmp...)
+			}
+			delete(ret, key)
+			ret[realKey] = list
+			continue
+		}
+
+		srcMap, ok1 := value.(map[string]interface{})
+		dstMap, ok2 := ret[key].(map[string]interface{})
+		if ok1 && ok2 {
+			ret[key] = mergeConfigMap(dstMap, srcMap)
+			continue
+		}
+		ret[key] = value
+	}
+	return ret
+}
+
+// finalizeConfigMap 清理遗留的追加声明键名
+func finalizeConfigMap(raw map[string]interface{}) map[string]interface{} {
+	for key, value := range raw {
+		if strings.HasSuffix(key, configListAppendTag) {
+			delete(raw, key)
+			raw[strings.TrimSuffix(key, configListAppendTag)] = value
+		}
+	}
+	return raw
+}
@@ -0,0 +1,139 @@
+/*
+@Project: aihub
+@Module: aihub
+@File : agent_template_test.go
+*/
+package aihub
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testBaseTemplateYaml = `
+name: test-base
+llm: test-base-llm
+claim: 本结果由AIHub自动生成
+max_step_quit: 8
+tools:
+  - GetWeather
+session_data:
+  region: CN
+  profile:
+    level: 1
+prompt_fragments:
+  rules: |
+    1. 回答保持简洁
+`
+
+const testChildAgentYaml = `
+extends: test-base
+name: test-child
+description: 子agent
+system_prompt: |
+  你是一位助手。
+  {{fragment "rules"}}
+tools+:
+  - GetSong
+session_data:
+  lang: zh
+`
+
+func Test_agentHub_SetAgentByYamlDataExtends(t *testing.T) {
+	if err := GetAgentHub().SetAgentTemplateByYamlData([]byte(testBaseTemplateYaml)); err != nil {
+		t.Fatal(err)
+	}
+
+	ag, err := GetAgentHub().SetAgentByYamlData([]byte(testChildAgentYaml))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := ag.(*agent).cfg
+	if cfg.Name != "test-child" || cfg.LLM != "test-base-llm" || cfg.MaxStepQuit != 8 || cfg.Claim == "" {
+		t.Fatalf("inherit failed => %+v", cfg)
+	}
+	if !reflect.DeepEqual(cfg.Tools, []string{"GetWeather", "GetSong"}) {
+		t.Fatalf("list append failed => %v", cfg.Tools)
+	}
+	if cfg.SessionData["region"] != "CN" || cfg.SessionData["lang"] != "zh" {
+		t.Fatalf("map merge failed => %v", cfg.SessionData)
+	}
+	if prompt := ag.(*agent).getSystemMsg(ag.(*agent).newRunOptions()).Content; !strings.Contains(prompt, "回答保持简洁") {
+		t.Fatalf("fragment include failed => %s", prompt)
+	}
+}
+
+func Test_agentHub_SetAgentByYamlDataExtendsCycle(t *testing.T) {
+	GetAgentHub().SetAgentTemplateByYamlData([]byte("name: test-cycle-a\nextends: test-cycle-b\n"))
+	GetAgentHub().SetAgentTemplateByYamlData([]byte("name: test-cycle-b\nextends: test-cycle-a\n"))
+
+	_, err := GetAgentHub().SetAgentByYamlData([]byte("name: test-cycle\nextends: test-cycle-a\nllm: test\n"))
+	if !errors.Is(err, ErrConfigExtendsCycle) {
+		t.Fatalf("want ErrConfigExtendsCycle, got %v", err)
+	}
+
+	_, err = GetAgentHub().SetAgentByYamlData([]byte("name: test-missing\nextends: test-missing-base\nllm: test\n"))
+	if !errors.Is(err, ErrConfigExtendsNotFound) {
+		t.Fatalf("want ErrConfigExtendsNotFound, got %v", err)
+	}
+}
+
+func Test_agentHub_SetAgentExtends(t *testing.T) {
+	if err := GetAgentHub().SetAgentTemplateByYamlData([]byte(testBaseTemplateYaml)); err != nil {
+		t.Fatal(err)
+	}
+
+	ag, err := GetAgentHub().SetAgent(&AgentConfig{
+		BriefInfo:       BriefInfo{Name: "test-child-struct"},
+		AgentRuntimeCfg: AgentRuntimeCfg{MaxStepQuit: 3},
+		Extends:         "test-base",
+		SessionData:     map[string]interface{}{"lang": "zh"},
+		ToolPolicies:    map[string]ToolPolicy{"GetWeather": {Timeout: 5}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := ag.(*agent).cfg
+	if cfg.Name != "test-child-struct" || cfg.LLM != "test-base-llm" || cfg.MaxStepQuit != 3 || cfg.Claim == "" || cfg.Extends != "" {
+		t.Fatalf("inherit failed => %+v", cfg)
+	}
+	if !reflect.DeepEqual(cfg.Tools, []string{"GetWeather"}) {
+		t.Fatalf("list inherit failed => %v", cfg.Tools)
+	}
+	if cfg.SessionData["region"] != "CN" || cfg.SessionData["lang"] != "zh" {
+		t.Fatalf("map merge failed => %v", cfg.SessionData)
+	}
+	if cfg.ToolPolicies["GetWeather"].Timeout != 5 || cfg.PromptFragments["rules"] == "" {
+		t.Fatalf("field lost => %+v", cfg)
+	}
+
+	// 与YAML配置合并结果一致：嵌套map深度合并
+	ag, err = GetAgentHub().SetAgent(&AgentConfig{
+		BriefInfo:   BriefInfo{Name: "test-child-deep"},
+		Extends:     "test-base",
+		SessionData: map[string]interface{}{"profile": map[string]interface{}{"vip": true}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profile, _ := ag.(*agent).cfg.SessionData["profile"].(map[string]interface{}); profile["level"] != 1 || profile["vip"] != true {
+		t.Fatalf("deep merge failed => %v", ag.(*agent).cfg.SessionData)
+	}
+
+	// agent与基础模板同名不视为循环继承
+	if _, err = GetAgentHub().SetAgent(&AgentConfig{BriefInfo: BriefInfo{Name: "test-base"}, Extends: "test-base"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = GetAgentHub().SetAgentByYamlData([]byte("name: test-base\nextends: test-base\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = GetAgentHub().SetAgent(&AgentConfig{BriefInfo: BriefInfo{Name: "test-child-missing"}, Extends: "test-missing-base"})
+	if !errors.Is(err, ErrConfigExtendsNotFound) {
+		t.Fatalf("want ErrConfigExtendsNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,311 @@
+/*
+@Project: aihub
+@Module: aihub
+@File : agent_test.go
+*/
+package aihub
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestLLM 基于httptest注册一个模拟的LLM，handler按请求返回对应的Message
+func newTestLLM(t *testing.T, name string, handler func(req *CreateChatCompletionReq) *ChatCompletionRspChoice) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := &CreateChatCompletionReq{}
+		json.NewDecoder(r.Body).Decode(req)
+		rsp := &CreateChatCompletionRsp{
+			Choices: []*ChatCompletionRspChoice{handler(req)},
+		}
+		json.NewEncoder(w).Encode(rsp)
+	}))
+	t.Cleanup(srv.Close)
+
+	_, err := GetLLMHub().SetLLM(&LLMConfig{
+		BriefInfo: BriefInfo{Name: name},
+		Provider:  "test",
+		BaseURL:   srv.URL,
+		APIKey:    "test",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_agent_RunCancel(t *testing.T) {
+	newTestLLM(t, "test-cancel-llm", func(req *CreateChatCompletionReq) *ChatCompletionRspChoice {
+		time.Sleep(500 * time.Millisecond)
+		return &ChatCompletionRspChoice{
+			Message:      &Message{Role: MessageRoleAssistant, Content: "done"},
+			FinishReason: ChatCompletionRspFinishReasonStop,
+		}
+	})
+
+	ag, err := GetAgentHub().SetAgent(&AgentConfig{
+		BriefInfo:       BriefInfo{Name: "test-cancel"},
+		AgentRuntimeCfg: AgentRuntimeCfg{LLM: "test-cancel-llm"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stateCh := make(chan RunState, 1)
+	dupCh := make(chan error, 1)
+	go func() {
+		for {
+			if infos := GetAgentHub().GetRunRegistry().GetRunInfoList(); len(infos) > 0 {
+				// 重复的运行ID被拒绝，且不影响已登记的运行
+				dupCh <- ag.Run(context.Background(), "hello", WithRunID("test-cancel-run")).Err
+				GetAgentHub().GetRunRegistry().Cancel(infos[0].RunID)
+				stateCh <- GetAgentHub().GetRunRegistry().GetRunInfo(infos[0].RunID).State
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+
+	rsp := ag.Run(context.Background(), "hello", WithRunID("test-cancel-run"))
+	if err = <-dupCh; !errors.Is(err, ErrRunIDRepeat) {
+		t.Fatalf("want ErrRunIDRepeat, got %v", err)
+	}
+	if state := <-stateCh; state != RunState_Canceled {
+		t.Fatalf("want canceled state, got %s", state)
+	}
+	if !errors.Is(rsp.Err, ErrAgentRunCanceled) {
+		t.Fatalf("want ErrAgentRunCanceled, got %v", rsp.Err)
+	}
+	if rsp.RunID != "test-cancel-run" {
+		t.Fatalf("want run id test-cancel-run, got %s", rsp.RunID)
+	}
+	if GetAgentHub().GetRunRegistry().GetRunInfo("test-cancel-run") != nil {
+		t.Fatal("run should be unregistered after finished")
+	}
+}
+
+func Test_agent_ToolArgumentsRepair(t *testing.T) {
+	GetToolHub().SetTool(ToolEntry{Function: Method1, Description: "Method1 desc"})
+
+	// 调用序列：两次入参错误后修正成功，下一次调用重新计数
+	args := []string{"{}", "{}", `{"aa":1}`, "{}"}
+	feedbacks := make([]string, 0)
+	newTestLLM(t, "test-repair-llm", func(req *CreateChatCompletionReq) *ChatCompletionRspChoice {
+		last := req.Messages[len(req.Messages)-1]
+		if last.Role == MessageRoleTool {
+			feedbacks = append(feedbacks, last.Content)
+		}
+		if len(feedbacks) == len(args) {
+			return &ChatCompletionRspChoice{
+				Message:      &Message{Role: MessageRoleAssistant, Content: "done"},
+				FinishReason: ChatCompletionRspFinishReasonStop,
+			}
+		}
+
+		toolCall := &MessageToolCall{Id: fmt.Sprintf("call_%d", len(feedbacks)), Type: ToolTypeFunction}
+		toolCall.Function.Name = "Method1"
+		toolCall.Function.Arguments = args[len(feedbacks)]
+		return &ChatCompletionRspChoice{
+			Message:      &Message{Role: MessageRoleAssistant, ToolCalls: []*MessageToolCall{toolCall}},
+			FinishReason: ChatCompletionRspFinishReasonToolCalls,
+		}
+	})
+
+	ag, err := GetAgentHub().SetAgent(&AgentConfig{
+		BriefInfo:       BriefInfo{Name: "test-repair"},
+		AgentRuntimeCfg: AgentRuntimeCfg{LLM: "test-repair-llm", MaxToolRepair: 2},
+		Tools:           []string{"Method1"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rsp := ag.Run(context.Background(), "hello")
+	if rsp.Err != nil {
+		t.Fatal(rsp.Err)
+	}
+	if len(feedbacks) != len(args) {
+		t.Fatalf("unexpected feedbacks: %d", len(feedbacks))
+	}
+	for i, remaining := range map[int]string{0: "\"remaining_repairs\":1", 1: "\"remaining_repairs\":0", 3: "\"remaining_repairs\":1"} {
+		if !strings.Contains(feedbacks[i], "required field is missing") || !strings.Contains(feedbacks[i], remaining) {
+			t.Fatalf("unexpected feedback %d: %s", i, feedbacks[i])
+		}
+	}
+
+	// 0表示不修正，首次入参错误即退出
+	feedbacks = feedbacks[:0]
+	rsp = ag.Run(context.Background(), "hello", WithRuntimeCfg(AgentRuntimeCfg{LLM: "test-repair-llm"}))
+	if !errors.Is(rsp.Err, ErrToolArgumentsRepairExceeded) {
+		t.Fatalf("want ErrToolArgumentsRepairExceeded, got %v", rsp.Err)
+	}
+}
+
+func Test_agent_RunOverrides(t *testing.T) {
+	GetToolHub().SetTool(ToolEntry{Function: Method1, Description: "Method1 desc"})
+
+	var lastReq *CreateChatCompletionReq
+	for _, name := range []string{"test-override-a", "test-override-b"} {
+		llmName := name
+		newTestLLM(t, llmName, func(req *CreateChatCompletionReq) *ChatCompletionRspChoice {
+			lastReq = req
+			return &ChatCompletionRspChoice{
+				Message:      &Message{Role: MessageRoleAssistant, Content: llmName},
+				FinishReason: ChatCompletionRspFinishReasonStop,
+			}
+		})
+	}
+
+	ag, err := GetAgentHub().SetAgent(&AgentConfig{
+		BriefInfo:       BriefInfo{Name: "test-override"},
+		AgentRuntimeCfg: AgentRuntimeCfg{LLM: "test-override-a", Temperature: 0.5},
+		Tools:           []string{"Method1"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rsp := ag.Run(context.Background(), "hello")
+	if rsp.Message.Content != "test-override-a" || len(lastReq.Tools) != 1 || lastReq.Temperature != 0.5 {
+		t.Fatalf("default run failed => content:%s, tools:%d, temperature:%v", rsp.Message.Content, len(lastReq.Tools), lastReq.Temperature)
+	}
+
+	rsp = ag.Run(context.Background(), "hello",
+		WithLLM("test-override-b"),
+		WithTemperature(1.2),
+		WithMaxTokens(100),
+		WithTools(),
+	)
+	if rsp.Message.Content != "test-override-b" || len(lastReq.Tools) != 0 || lastReq.Temperature != 1.2 || lastReq.MaxTokens != 100 {
+		t.Fatalf("override run failed => content:%s, tools:%d, temperature:%v, maxTokens:%d", rsp.Message.Content, len(lastReq.Tools), lastReq.Temperature, lastReq.MaxTokens)
+	}
+}
+
+type SessionEchoInput struct {
+	ToolInputBase
+
+	OrderNo string `json:"order_no"`
+}
+
+func SessionEchoMethod(ctx context.Context, input *SessionEchoInput, output *Message) (err error) {
+	output.Content = "order_no=" + input.OrderNo
+	return nil
+}
+
+func Test_agent_ToolSessionArgs(t *testing.T) {
+	GetToolHub().SetTool(ToolEntry{Function: SessionEchoMethod, Description: "SessionEchoMethod desc"})
+
+	var toolReq *CreateChatCompletionReq
+	newTestLLM(t, "test-session-args-llm", func(req *CreateChatCompletionReq) *ChatCompletionRspChoice {
+		last := req.Messages[len(req.Messages)-1]
+		if last.Role == MessageRoleTool {
+			return &ChatCompletionRspChoice{
+				Message:      &Message{Role: MessageRoleAssistant, Content: last.Content},
+				FinishReason: ChatCompletionRspFinishReasonStop,
+			}
+		}
+
+		toolReq = req
+		toolCall := &MessageToolCall{Id: "call_1", Type: ToolTypeFunction}
+		toolCall.Function.Name = "SessionEchoMethod"
+		toolCall.Function.Arguments = `{"order_no":"B999"}`
+		return &ChatCompletionRspChoice{
+			Message:      &Message{Role: MessageRoleAssistant, ToolCalls: []*MessageToolCall{toolCall}},
+			FinishReason: ChatCompletionRspFinishReasonToolCalls,
+		}
+	})
+
+	ag, err := GetAgentHub().SetAgent(&AgentConfig{
+		BriefInfo:       BriefInfo{Name: "test-session-args"},
+		AgentRuntimeCfg: AgentRuntimeCfg{LLM: "test-session-args-llm"},
+		Tools:           []string{"SessionEchoMethod"},
+		ToolSessionArgs: map[string]map[string]string{"SessionEchoMethod": {"order_no": "order_no"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rsp := ag.Run(context.Background(), "hello", WithSessionData(map[string]interface{}{"order_no": "A001"}))
+	if rsp.Err != nil {
+		t.Fatal(rsp.Err)
+	}
+	if _, ok := toolReq.Tools[0].Function.Parameters.Properties["order_no"]; ok {
+		t.Fatalf("bound arg should be hidden => %v", toolReq.Tools[0].Function.Parameters.Properties)
+	}
+	// 模型传入的绑定参数被session值覆盖
+	if !strings.Contains(rsp.Content, "order_no=A001") {
+		t.Fatalf("want order_no=A001, got %s", rsp.Content)
+	}
+	// session中无值时也不采用模型传入的值
+	output := &Message{}
+	ag.(*agent).InvokeToolCall(context.Background(), "SessionEchoMethod", `{"order_no":"B999"}`, output)
+	if strings.Contains(output.Content, "B999") {
+		t.Fatalf("want spoofed arg dropped => %s", output.Content)
+	}
+	if err = ag.(*agent).InvokeToolCall(context.Background(), "SessionEchoMethod", `{"order_no":`, &Message{}); err == nil {
+		t.Fatal("want invalid arguments error")
+	}
+	// 工具注册的原始定义不受影响
+	if _, ok := GetToolHub().GetToolFunctions("SessionEchoMethod")[0].Parameters.Properties["order_no"]; !ok {
+		t.Fatal("tool hub definition should keep bound arg")
+	}
+}
+
+func Test_runRegistry_SnapshotDuringToolCalls(t *testing.T) {
+	slow := func(ctx context.Context, input *Method1Input, output *Message) error {
+		time.Sleep(20 * time.Millisecond)
+		output.Content = "slow"
+		return nil
+	}
+	GetToolHub().SetTool(ToolEntry{Name: "test_snapshot_slow", Function: slow})
+
+	newTestLLM(t, "test-snapshot-llm", func(req *CreateChatCompletionReq) *ChatCompletionRspChoice {
+		if req.Messages[len(req.Messages)-1].Role == MessageRoleTool {
+			return &ChatCompletionRspChoice{
+				Message:      &Message{Role: MessageRoleAssistant, Content: "done"},
+				FinishReason: ChatCompletionRspFinishReasonStop,
+			}
+		}
+		toolCalls := make([]*MessageToolCall, 0)
+		for _, id := range []string{"call_1", "call_2"} {
+			toolCall := &MessageToolCall{Id: id, Type: ToolTypeFunction}
+			toolCall.Function.Name = "test_snapshot_slow"
+			toolCall.Function.Arguments = `{"aa":1}`
+			toolCalls = append(toolCalls, toolCall)
+		}
+		return &ChatCompletionRspChoice{
+			Message:      &Message{Role: MessageRoleAssistant, ToolCalls: toolCalls},
+			FinishReason: ChatCompletionRspFinishReasonToolCalls,
+		}
+	})
+
+	ag, err := GetAgentHub().SetAgent(&AgentConfig{
+		BriefInfo:       BriefInfo{Name: "test-snapshot"},
+		AgentRuntimeCfg: AgentRuntimeCfg{LLM: "test-snapshot-llm"},
+		Tools:           []string{"test_snapshot_slow"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 50; i++ {
+			if info := GetAgentHub().GetRunRegistry().GetRunInfo("test-snapshot-run"); info != nil && info.CurrentStep != nil {
+				_ = info.CurrentStep.Result
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}()
+	if rsp := ag.Run(context.Background(), "hello", WithRunID("test-snapshot-run")); rsp.Err != nil {
+		t.Fatal(rsp.Err)
+	}
+	<-done
+}
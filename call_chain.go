@@ -0,0 +1,134 @@
+/*
+@Project: aihub
+@Module: aihub
+@File : call_chain.go
+*/
+package aihub
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const contextAIHubCallChainKey = "AIHUB_CALL_CHAIN"
+
+const (
+	defaultMaxCallDepth = 5
+	defaultMaxTreeSteps = 100
+)
+
+// callChain agent嵌套调用链路，随ctx向下传递
+type callChain struct {
+	names  []string    // 调用链路上的agent名称，首个为根调用
+	budget *callBudget // 整个调用树共享的预算
+}
+
+// callBudget 整个调用树共享的预算，由根调用的运行时配置创建
+type callBudget struct {
+	maxDepth  int
+	maxSteps  int64
+	maxTokens int64
+	deadline  time.Time
+
+	steps  int64 // 已用LLM调用次数
+	tokens int64 // 已用token数
+}
+
+func newCallBudget(cfg *AgentRuntimeCfg) *callBudget {
+	b := &callBudget{
+		maxDepth:  cfg.MaxCallDepth,
+		maxSteps:  int64(cfg.MaxTreeSteps),
+		maxTokens: cfg.MaxTreeTokens,
+		deadline:  time.Now().Add(time.Duration(cfg.RunTimeout) * time.Second),
+	}
+	if b.maxDepth <= 0 {
+		b.maxDepth = defaultMaxCallDepth
+	}
+	if b.maxSteps <= 0 {
+		b.maxSteps = defaultMaxTreeSteps
+	}
+	if cfg.RunTimeout <= 0 {
+		b.deadline = time.Now().Add(60 * 60 * time.Second)
+	}
+	return b
+}
+
+// acquireStep 占用一次LLM调用，超出步数、token或时间预算时返回错误
+func (b *callBudget) acquireStep() error {
+	if steps := atomic.AddInt64(&b.steps, 1); steps > b.maxSteps {
+		return fmt.Errorf("%w: llm calls over %d", ErrCallBudgetExceeded, b.maxSteps)
+	}
+	if tokens := atomic.LoadInt64(&b.tokens); b.maxTokens > 0 && tokens >= b.maxTokens {
+		return fmt.Errorf("%w: tokens %d over %d", ErrCallBudgetExceeded, tokens, b.maxTokens)
+	}
+	if time.Now().After(b.deadline) {
+		return fmt.Errorf("%w: over deadline %s", ErrCallBudgetExceeded, b.deadline.Format(time.DateTime))
+	}
+	return nil
+}
+
+func (b *callBudget) addTokens(tokens int) {
+	atomic.AddInt64(&b.tokens, int64(tokens))
+}
+
+func callChainFromContext(ctx context.Context) *callChain {
+	if tmp, ok := ctx.Value(contextAIHubCallChainKey).(*callChain); ok {
+		return tmp
+	}
+	return nil
+}
+
+// CallChainFromContext 获取当前调用链路上的agent名称，首个为根调用
+func CallChainFromContext(ctx context.Context) []string {
+	chain := callChainFromContext(ctx)
+	if chain == nil {
+		return []string{}
+	}
+	return append([]string{}, chain.names...)
+}
+
+// enterCallChain 进入agent调用：检测循环调用及嵌套深度，根调用创建整个调用树共享的预算
+func enterCallChain(ctx context.Context, name string, cfg *AgentRuntimeCfg) (context.Context, error) {
+	parent := callChainFromContext(ctx)
+	if parent == nil {
+		return context.WithValue(ctx, contextAIHubCallChainKey, &callChain{
+			names:  []string{name},
+			budget: newCallBudget(cfg),
+		}), nil
+	}
+
+	for _, item := range parent.names {
+		if item == name {
+			return ctx, fmt.Errorf("%w: %s -> %s", ErrAgentCallCycle, strings.Join(parent.names, " -> "), name)
+		}
+	}
+	if len(parent.names) >= parent.budget.maxDepth {
+		return ctx, fmt.Errorf("%w: %s -> %s, max depth %d", ErrAgentCallDepthExceeded, strings.Join(parent.names, " -> "), name, parent.budget.maxDepth)
+	}
+
+	names := make([]string, 0, len(parent.names)+1)
+	names = append(names, parent.names...)
+	return context.WithValue(ctx, contextAIHubCallChainKey, &callChain{
+		names:  append(names, name),
+		budget: parent.budget,
+	}), nil
+}
+
+// createChatCompletion 调用LLM并计入调用树共享预算
+func createChatCompletion(ctx context.Context, LLMIns ILLM, req *CreateChatCompletionReq) (*CreateChatCompletionRsp, error) {
+	chain := callChainFromContext(ctx)
+	if chain != nil {
+		if err := chain.budget.acquireStep(); err != nil {
+			return nil, err
+		}
+	}
+
+	rsp, err := LLMIns.CreateChatCompletion(ctx, req)
+	if err == nil && rsp != nil && chain != nil {
+		chain.budget.addTokens(rsp.Usage.TotalTokens)
+	}
+	return rsp, err
+}
@@ -0,0 +1,97 @@
+/*
+@Project: aihub
+@Module: aihub
+@File : call_chain_test.go
+*/
+package aihub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func Test_enterCallChain(t *testing.T) {
+	ctx, err := enterCallChain(context.Background(), "a", &AgentRuntimeCfg{MaxCallDepth: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ctx, err = enterCallChain(ctx, "b", &AgentRuntimeCfg{MaxCallDepth: 10}); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(CallChainFromContext(ctx), ",") != "a,b" {
+		t.Fatalf("unexpected chain => %v", CallChainFromContext(ctx))
+	}
+
+	if _, err = enterCallChain(ctx, "a", &AgentRuntimeCfg{}); !errors.Is(err, ErrAgentCallCycle) || !strings.Contains(err.Error(), "a -> b -> a") {
+		t.Fatalf("want ErrAgentCallCycle, got %v", err)
+	}
+
+	// 深度限制以根调用配置为准
+	if ctx, err = enterCallChain(ctx, "c", &AgentRuntimeCfg{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = enterCallChain(ctx, "d", &AgentRuntimeCfg{MaxCallDepth: 10}); !errors.Is(err, ErrAgentCallDepthExceeded) {
+		t.Fatalf("want ErrAgentCallDepthExceeded, got %v", err)
+	}
+}
+
+func Test_agent_RunCallCycle(t *testing.T) {
+	if len(GetToolHub().GetTool(AgentCallFuncName)) == 0 {
+		GetToolHub().SetTool(ToolEntry{Function: AgentCall})
+	}
+
+	newTestLLM(t, "test-chain-llm", func(req *CreateChatCompletionReq) *ChatCompletionRspChoice {
+		last := req.Messages[len(req.Messages)-1]
+		if last.Role == MessageRoleTool {
+			return &ChatCompletionRspChoice{
+				Message:      &Message{Role: MessageRoleAssistant, Content: last.Content},
+				FinishReason: ChatCompletionRspFinishReasonStop,
+			}
+		}
+
+		// 相互调用：call-a => test-chain-a，call-b => test-chain-b
+		target := "test-chain-" + strings.TrimPrefix(last.Content, "call-")
+		next := "call-a"
+		if target == "test-chain-a" {
+			next = "call-b"
+		}
+		toolCall := &MessageToolCall{Id: "call_1", Type: ToolTypeFunction}
+		toolCall.Function.Name = AgentCallFuncName
+		toolCall.Function.Arguments = fmt.Sprintf(`{"_action_":"%s","_question_":"%s","_think_":"调用","_state_":1}`, target, next)
+		return &ChatCompletionRspChoice{
+			Message:      &Message{Role: MessageRoleAssistant, ToolCalls: []*MessageToolCall{toolCall}},
+			FinishReason: ChatCompletionRspFinishReasonToolCalls,
+		}
+	})
+
+	for _, name := range []string{"test-chain-a", "test-chain-b"} {
+		if _, err := GetAgentHub().SetAgent(&AgentConfig{
+			BriefInfo:       BriefInfo{Name: name},
+			AgentRuntimeCfg: AgentRuntimeCfg{LLM: "test-chain-llm"},
+			Tools:           []string{AgentCallFuncName},
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// a调用b，b再调用a时返回循环调用错误给模型
+	rsp := GetAgentHub().GetAgent("test-chain-a").Run(context.Background(), "call-b")
+	if rsp.Err != nil {
+		t.Fatal(rsp.Err)
+	}
+	if !strings.Contains(rsp.Message.Content, "test-chain-a -> test-chain-b -> test-chain-a") {
+		t.Fatalf("want cycle error feedback, got %s", rsp.Message.Content)
+	}
+
+	// 整个调用树共享LLM调用次数预算
+	rsp = GetAgentHub().GetAgent("test-chain-a").Run(context.Background(), "call-b", WithRuntimeCfg(AgentRuntimeCfg{
+		LLM:          "test-chain-llm",
+		MaxTreeSteps: 2,
+	}))
+	if !errors.Is(rsp.Err, ErrCallBudgetExceeded) {
+		t.Fatalf("want ErrCallBudgetExceeded, got %v", rsp.Err)
+	}
+}
@@ -17,10 +17,24 @@ type AgentConfig struct {
 	BriefInfo       `yaml:",inline"` // yaml解析inline结构
 	AgentRuntimeCfg `yaml:",inline"` // yaml解析inline结构
 
+	Extends         string                 `json:"extends,omitempty" yaml:"extends,omitempty"`                   // 继承的基础配置模板名称
+	PromptFragments map[string]string      `json:"prompt_fragments,omitempty" yaml:"prompt_fragments,omitempty"` // 可复用提示词片段，系统提示词中以 {{fragment "name"}} 引用
+	PromptVars      map[string]interface{} `json:"prompt_vars,omitempty" yaml:"prompt_vars,omitempty"`           // 系统提示词模板自定义变量，模板中以 {{.Vars.key}} 引用
+
 	Tools       []string               `json:"tools,omitempty" yaml:"tools,omitempty"`               // 用到的工具名
 	Mcps        []string               `json:"mcps,omitempty" yaml:"mcps,omitempty"`                 // 用到的MCP服务
 	Middlewares []string               `json:"middlewares,omitempty" yaml:"middlewares,omitempty"`   // 用到的Middleware
 	SessionData map[string]interface{} `json:"session_data,omitempty" yaml:"session_data,omitempty"` // 用到的Session数据
+
+	ToolPolicies map[string]ToolPolicy `json:"tool_policies,omitempty" yaml:"tool_policies,omitempty"` // 工具调用策略，toolName => 策略，覆盖工具注册时的默认策略（含MCP工具），其中MaxConcurrency为该agent内的限制，与工具注册时（ToolEntry.Policy或MCPHub.SetToolPolicy）的限制及SetMaxToolConcurrency的进程内限制叠加生效
+	Guardrails   []*GuardrailConfig    `json:"guardrails,omitempty" yaml:"guardrails,omitempty"`       // 输入输出护栏
+	Reflection   *ReflectionConfig     `json:"reflection,omitempty" yaml:"reflection,omitempty"`       // 可选，最终回答的反思评审
+
+	Memory       *MemoryConfig       `json:"memory,omitempty" yaml:"memory,omitempty"`               // 可选，会话记忆存储，默认进程内存储
+	SessionStore *SessionStoreConfig `json:"session_store,omitempty" yaml:"session_store,omitempty"` // 可选，会话数据存储，配置后可按会话ID恢复会话数据
+	Knowledge    *KnowledgeConfig    `json:"knowledge,omitempty" yaml:"knowledge,omitempty"`         // 可选，关联知识库，按输入检索注入或作为检索工具
+
+	ToolSessionArgs map[string]map[string]string `json:"tool_session_args,omitempty" yaml:"tool_session_args,omitempty"` // 工具入参绑定session数据，toolName => 参数名 => session key，绑定的参数不暴露给模型，调用时从session数据填充，用于无法声明session tag的MCP工具
 }
 
 func (cfg *AgentConfig) AutoFix() error {
@@ -40,6 +54,46 @@ func (cfg *AgentConfig) AutoFix() error {
 	if cfg.SessionData == nil {
 		cfg.SessionData = make(map[string]interface{})
 	}
+	if cfg.PromptFragments == nil {
+		cfg.PromptFragments = make(map[string]string)
+	}
+	if cfg.PromptVars == nil {
+		cfg.PromptVars = make(map[string]interface{})
+	}
+	if cfg.ToolPolicies == nil {
+		cfg.ToolPolicies = make(map[string]ToolPolicy)
+	}
+	if cfg.ToolSessionArgs == nil {
+		cfg.ToolSessionArgs = make(map[string]map[string]string)
+	}
+	if cfg.Guardrails == nil {
+		cfg.Guardrails = make([]*GuardrailConfig, 0)
+	}
+	if cfg.Reflection != nil {
+		if err := cfg.Reflection.AutoFix(); err != nil {
+			return err
+		}
+	}
+	if cfg.Memory != nil {
+		if err := cfg.Memory.AutoFix(); err != nil {
+			return err
+		}
+		if cfg.Memory.Summary != nil {
+			if err := cfg.Memory.Summary.AutoFix(cfg.MaxStoreMemory); err != nil {
+				return err
+			}
+		}
+	}
+	if cfg.SessionStore != nil {
+		if err := cfg.SessionStore.AutoFix(); err != nil {
+			return err
+		}
+	}
+	if cfg.Knowledge != nil {
+		if err := cfg.Knowledge.AutoFix(); err != nil {
+			return err
+		}
+	}
 
 	return nil
 }
@@ -61,6 +115,16 @@ type AgentRuntimeCfg struct {
 	RunTimeout   int64  `json:"run_timeout,omitempty" yaml:"run_timeout,omitempty"`     // 执行超时秒数
 	Claim        string `json:"claim,omitempty" yaml:"claim,omitempty"`                 // 宣称文案，例如：本次返回由xxx提供
 	Debug        bool   `json:"debug,omitempty" yaml:"debug,omitempty"`                 // debug输出标志，开启则输出具体工具调用过程信息
+
+	MaxToolConcurrency int `json:"max_tool_concurrency,omitempty" yaml:"max_tool_concurrency,omitempty"` // 限制该agent工具调用的最大并发数（跨步骤及运行共享），0表示不限制
+	MaxToolRepair      int `json:"max_tool_repair,omitempty" yaml:"max_tool_repair,omitempty"`           // 限制单次工具调用入参校验失败后模型的最大修正次数，0表示不修正
+
+	MaxReplan           int `json:"max_replan,omitempty" yaml:"max_replan,omitempty"`                       // Manus任务失败后的最大重新规划次数
+	MaxAgentConcurrency int `json:"max_agent_concurrency,omitempty" yaml:"max_agent_concurrency,omitempty"` // 限制并行调度子agent的最大并发数
+
+	MaxCallDepth  int   `json:"max_call_depth,omitempty" yaml:"max_call_depth,omitempty"`   // 作为根调用时，限制agent嵌套调用的最大深度
+	MaxTreeSteps  int   `json:"max_tree_steps,omitempty" yaml:"max_tree_steps,omitempty"`   // 作为根调用时，限制整个调用树的LLM调用总次数
+	MaxTreeTokens int64 `json:"max_tree_tokens,omitempty" yaml:"max_tree_tokens,omitempty"` // 作为根调用时，限制整个调用树的token总数，0表示不限制
 }
 
 func (cfg *AgentRuntimeCfg) AutoFix() error {
@@ -92,6 +156,29 @@ func (cfg *AgentRuntimeCfg) AutoFix() error {
 	if cfg.RunTimeout <= 0 || cfg.RunTimeout > 60*60 {
 		cfg.RunTimeout = 60 * 60
 	}
+	if cfg.MaxToolConcurrency < 0 {
+		cfg.MaxToolConcurrency = 0
+	}
+	if cfg.MaxToolRepair < 0 {
+		cfg.MaxToolRepair = 0
+	} else if cfg.MaxToolRepair > 5 {
+		cfg.MaxToolRepair = 5
+	}
+	if cfg.MaxReplan <= 0 || cfg.MaxReplan > 5 {
+		cfg.MaxReplan = 2
+	}
+	if cfg.MaxAgentConcurrency <= 0 || cfg.MaxAgentConcurrency > 10 {
+		cfg.MaxAgentConcurrency = 3
+	}
+	if cfg.MaxCallDepth <= 0 || cfg.MaxCallDepth > 10 {
+		cfg.MaxCallDepth = defaultMaxCallDepth
+	}
+	if cfg.MaxTreeSteps <= 0 || cfg.MaxTreeSteps > 500 {
+		cfg.MaxTreeSteps = defaultMaxTreeSteps
+	}
+	if cfg.MaxTreeTokens < 0 {
+		cfg.MaxTreeTokens = 0
+	}
 
 	if cfg.LLM == "" {
 		return ErrConfiguration
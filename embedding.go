@@ -0,0 +1,37 @@
+/*
+@Project: aihub
+@Module: aihub
+@File : embedding.go
+*/
+package aihub
+
+import (
+	"sync"
+)
+
+var (
+	embedders     = make(map[string]IEmbedder)
+	embeddersLock sync.RWMutex
+)
+
+// RegisterEmbedder 注册自定义向量化实现，同名覆盖
+func RegisterEmbedder(name string, embedder IEmbedder) {
+	embeddersLock.Lock()
+	defer embeddersLock.Unlock()
+	embedders[name] = embedder
+}
+
+// GetEmbedder 按名称获取向量化实现：优先取RegisterEmbedder注册的实现，其次取LLMHub中支持embeddings接口的LLM
+func GetEmbedder(name string) IEmbedder {
+	embeddersLock.RLock()
+	ret, ok := embedders[name]
+	embeddersLock.RUnlock()
+	if ok {
+		return ret
+	}
+
+	if tmp, ok := GetLLMHub().GetLLM(name).(IEmbedder); ok {
+		return tmp
+	}
+	return nil
+}
@@ -6,6 +6,7 @@ var (
 	ErrUnknown                     = errors.New("unknown error")
 	ErrConfiguration               = errors.New("invalid agent or llm configuration")
 	ErrToolRegisterRepeat          = errors.New("tool function name register repeated")
+	ErrToolRegisterInvalid         = errors.New("tool function register invalid")
 	ErrProviderRateLimit           = errors.New("llm trigger rate limit")
 	ErrAgentRunTimeout             = errors.New("agent run timeout")
 	ErrCallNameNotMatch            = errors.New("not found matched call name with mcp/tool entry")
@@ -16,4 +17,27 @@ var (
 	ErrHTTPRequestBodyInvalid      = errors.New("http request body invalid")
 	ErrHTTPRequestTimeout          = errors.New("http request timeout")
 	ErrToolCallResponseEmpty       = errors.New("tool call response empty")
+	ErrAgentRunCanceled            = errors.New("agent run canceled")
+	ErrRunNotFound                 = errors.New("not found matched run id")
+	ErrRunIDRepeat                 = errors.New("run id already running")
+	ErrToolCallTimeout             = errors.New("tool call timeout")
+	ErrToolCallRetryable           = errors.New("tool call retryable error")
+	ErrToolArgumentsInvalid        = errors.New("tool call arguments invalid")
+	ErrToolArgumentsRepairExceeded = errors.New("tool call arguments repair over max attempts")
+	ErrGuardrailTripped            = errors.New("guardrail tripped")
+	ErrGuardrailJudgeInvalid       = errors.New("guardrail judge response invalid")
+	ErrConfigExtendsCycle          = errors.New("agent config extends cycle")
+	ErrConfigExtendsNotFound       = errors.New("agent config extends template not found")
+	ErrPromptFragmentNotFound      = errors.New("prompt fragment not found")
+	ErrPromptTemplateInvalid       = errors.New("system prompt template invalid")
+	ErrWorkflowCycle               = errors.New("workflow steps depends cycle")
+	ErrWorkflowStepFailed          = errors.New("workflow step run failed")
+	ErrPlanInvalid                 = errors.New("manus plan invalid")
+	ErrPlanReplanExceeded          = errors.New("manus plan replan over max attempts")
+	ErrAgentCallCycle              = errors.New("agent call cycle detected")
+	ErrAgentCallDepthExceeded      = errors.New("agent call over max depth")
+	ErrCallBudgetExceeded          = errors.New("agent call tree over budget")
+	ErrEmbeddingInvalid            = errors.New("embedding response invalid")
+	ErrMemoryNotFound              = errors.New("memory session or message not found")
+	ErrMemoryImportInvalid         = errors.New("memory import data invalid")
 )
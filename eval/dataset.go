@@ -0,0 +1,82 @@
+/*
+@Project: aihub
+@Module: eval
+@File : dataset.go
+*/
+package eval
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Case 评测用例，数据集每行一个JSON
+type Case struct {
+	ID             string                 `json:"id,omitempty"`              // 用例ID，为空时按行号生成
+	Input          string                 `json:"input"`                     // 输入问题
+	Expected       string                 `json:"expected,omitempty"`        // 期望输出，exact/llm_judge使用
+	Pattern        string                 `json:"pattern,omitempty"`         // 期望输出需匹配的正则，regex使用
+	ExpectedFields map[string]interface{} `json:"expected_fields,omitempty"` // 期望输出JSON字段值，key为gjson路径，json_field使用
+	ExpectedTools  []string               `json:"expected_tools,omitempty"`  // 期望的工具/子agent调用顺序，tool_sequence使用
+	Criteria       string                 `json:"criteria,omitempty"`        // 评判标准，llm_judge使用
+	SessionData    map[string]interface{} `json:"session_data,omitempty"`    // 运行时session数据
+	Tags           []string               `json:"tags,omitempty"`            // 标签，便于分组统计
+}
+
+// Dataset 评测数据集
+type Dataset struct {
+	Name  string  `json:"name"`
+	Cases []*Case `json:"cases"`
+}
+
+// LoadDataset 从JSONL文件加载数据集，数据集名称默认取文件名
+func LoadDataset(file string) (*Dataset, error) {
+	f, err := os.Open(filepath.Clean(file))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	name := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+	return ParseDataset(name, f)
+}
+
+// ParseDataset 解析JSONL数据，忽略空行及#开头的注释行
+func ParseDataset(name string, reader io.Reader) (*Dataset, error) {
+	ds := &Dataset{
+		Name:  name,
+		Cases: make([]*Case, 0),
+	}
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		c := &Case{}
+		if err := json.Unmarshal([]byte(text), c); err != nil {
+			return nil, fmt.Errorf("%w: line %d => %v", ErrDatasetInvalid, line, err)
+		}
+		if c.Input == "" {
+			return nil, fmt.Errorf("%w: line %d => empty input", ErrDatasetInvalid, line)
+		}
+		if c.ID == "" {
+			c.ID = fmt.Sprintf("%s-%d", name, line)
+		}
+		ds.Cases = append(ds.Cases, c)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return ds, nil
+}
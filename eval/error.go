@@ -0,0 +1,15 @@
+/*
+@Project: aihub
+@Module: eval
+@File : error.go
+*/
+package eval
+
+import "errors"
+
+var (
+	ErrDatasetInvalid     = errors.New("eval dataset invalid")
+	ErrScorerConfig       = errors.New("eval scorer configuration invalid")
+	ErrJudgeInvalid       = errors.New("eval judge response invalid")
+	ErrReportIncompatible = errors.New("eval reports not comparable")
+)
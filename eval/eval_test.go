@@ -0,0 +1,177 @@
+/*
+@Project: aihub
+@Module: eval
+@File : eval_test.go
+*/
+package eval
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/mvptianyu/aihub"
+	"github.com/mvptianyu/aihub/ssestream"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeAgent 按输入返回固定结果的agent
+type fakeAgent struct {
+	answers map[string]string
+	tools   []string
+}
+
+func (a *fakeAgent) GetBriefInfo() aihub.BriefInfo {
+	return aihub.BriefInfo{Name: "fake"}
+}
+
+func (a *fakeAgent) Run(ctx context.Context, input string, opts ...aihub.RunOptionFunc) *aihub.Response {
+	rsp := &aihub.Response{}
+	rsp.Message = &aihub.Message{Role: aihub.MessageRoleAssistant, Content: a.answers[input]}
+	for _, name := range a.tools {
+		rsp.Steps = append(rsp.Steps, &aihub.RunStep{Action: name, StepType: aihub.StepType_Tool})
+	}
+	return rsp
+}
+
+func (a *fakeAgent) RunStream(ctx context.Context, input string, opts ...aihub.RunOptionFunc) *ssestream.StreamReader[aihub.Response] {
+	return nil
+}
+
+func (a *fakeAgent) ResetMemory(ctx context.Context, opts ...aihub.RunOptionFunc) error {
+	return nil
+}
+
+func (a *fakeAgent) GetMemory() aihub.IMemory {
+	return nil
+}
+
+func (a *fakeAgent) Close() error {
+	return nil
+}
+
+func (a *fakeAgent) GetToolFunctions() []aihub.ToolFunction {
+	return nil
+}
+
+func (a *fakeAgent) InvokeToolCall(ctx context.Context, name string, args string, output *aihub.Message) error {
+	return nil
+}
+
+const testDataset = `
+# 注释行
+{"id":"c1","input":"1+1","expected":"2"}
+{"id":"c2","input":"天气","pattern":"晴|雨","expected_tools":["Weather"]}
+{"id":"c3","input":"用户","expected_fields":{"user.name":"tom","user.age":18}}
+{"id":"c4","input":"介绍","criteria":"需要提到产品名称"}
+`
+
+func Test_Runner_Run(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := &aihub.CreateChatCompletionReq{}
+		json.NewDecoder(r.Body).Decode(req)
+		content := `{"score":0.2,"reason":"未提到产品名称"}`
+		if strings.Contains(req.Messages[1].Content, "AIHub") {
+			content = "```json\n{\"score\":0.9,\"reason\":\"ok\"}\n```"
+		}
+		json.NewEncoder(w).Encode(&aihub.CreateChatCompletionRsp{
+			Choices: []*aihub.ChatCompletionRspChoice{
+				{Message: &aihub.Message{Role: aihub.MessageRoleAssistant, Content: content}},
+			},
+		})
+	}))
+	defer srv.Close()
+	if _, err := aihub.GetLLMHub().SetLLM(&aihub.LLMConfig{
+		BriefInfo: aihub.BriefInfo{Name: "test-eval-judge"},
+		Provider:  "test",
+		BaseURL:   srv.URL,
+		APIKey:    "test",
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	ds, err := ParseDataset("basic", strings.NewReader(testDataset))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ds.Cases) != 4 {
+		t.Fatalf("want 4 cases, got %d", len(ds.Cases))
+	}
+
+	scorers := []Scorer{&ExactMatch{}, &Regex{}, &JSONField{}, &ToolSequence{}, &LLMJudge{LLM: "test-eval-judge"}}
+	base := NewRunner(&fakeAgent{answers: map[string]string{
+		"1+1": "2",
+		"天气":  "多云",
+		"用户":  `{"user":{"name":"tom","age":20}}`,
+		"介绍":  "这是一个框架",
+	}}, scorers...)
+	base.Label = "v1"
+	baseReport := base.Run(context.Background(), ds)
+
+	// c1: exact+judge，c2: regex不通过，c3: json字段半数匹配，c4: judge不通过
+	if baseReport.Total != 4 || baseReport.Passed != 0 {
+		t.Fatalf("unexpected base report => total:%d passed:%d", baseReport.Total, baseReport.Passed)
+	}
+	if baseReport.Scorers["json_field"].AvgScore != 0.5 {
+		t.Fatalf("want json_field avg 0.5, got %v", baseReport.Scorers["json_field"].AvgScore)
+	}
+
+	current := NewRunner(&fakeAgent{tools: []string{"Location", "Weather"}, answers: map[string]string{
+		"1+1": "2",
+		"天气":  "晴",
+		"用户":  `{"user":{"name":"tom","age":18}}`,
+		"介绍":  "AIHub是一个agent框架",
+	}}, scorers...)
+	current.Label = "v2"
+	currentReport := current.Run(context.Background(), ds)
+	if currentReport.Passed != 3 {
+		t.Fatalf("want 3 passed, got %d => %+v", currentReport.Passed, currentReport.GetResult("c1").Scores[1])
+	}
+
+	// 保存后加载对比
+	file := filepath.Join(t.TempDir(), "base.json")
+	if err = baseReport.Save(file); err != nil {
+		t.Fatal(err)
+	}
+	if baseReport, err = LoadReport(file); err != nil {
+		t.Fatal(err)
+	}
+	cmp, err := currentReport.Compare(baseReport)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cmp.Improvements) != 3 || cmp.PassRateDelta != 0.75 {
+		t.Fatalf("unexpected comparison => %s", cmp.String())
+	}
+
+	// 没有适用评分器的用例不计为通过
+	report := NewRunner(&fakeAgent{answers: map[string]string{"1+1": "2"}}, &Regex{}).Run(context.Background(), &Dataset{
+		Name:  "no-scorer",
+		Cases: []*Case{{ID: "n1", Input: "1+1", Expected: "2"}},
+	})
+	if result := report.GetResult("n1"); result.Pass || !result.Skipped || report.Skipped != 1 || report.Passed != 0 {
+		t.Fatalf("want skipped case => %+v", result)
+	}
+}
+
+func Test_ToolSequence_Score(t *testing.T) {
+	rsp := &aihub.Response{}
+	for _, name := range []string{"A", "B", "C"} {
+		rsp.Steps = append(rsp.Steps, &aihub.RunStep{Action: name, StepType: aihub.StepType_Tool})
+	}
+
+	score, _ := (&ToolSequence{}).Score(context.Background(), &Case{ExpectedTools: []string{"A", "C"}}, rsp)
+	if !score.Pass {
+		t.Fatalf("want pass in order => %+v", score)
+	}
+	score, _ = (&ToolSequence{}).Score(context.Background(), &Case{ExpectedTools: []string{"C", "A"}}, rsp)
+	if score.Pass || score.Value != 0.5 {
+		t.Fatalf("want half matched => %+v", score)
+	}
+	score, _ = (&ToolSequence{Strict: true}).Score(context.Background(), &Case{ExpectedTools: []string{"A", "C"}}, rsp)
+	if score.Pass {
+		t.Fatalf("want strict not pass => %+v", score)
+	}
+}
@@ -0,0 +1,195 @@
+/*
+@Project: aihub
+@Module: eval
+@File : report.go
+*/
+package eval
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// CaseResult 单个用例的评测结果
+type CaseResult struct {
+	CaseID    string   `json:"case_id"`
+	Input     string   `json:"input"`
+	Output    string   `json:"output"`
+	ToolCalls []string `json:"tool_calls,omitempty"`
+	Tags      []string `json:"tags,omitempty"`
+	Error     string   `json:"error,omitempty"` // 运行错误，有错误时用例不通过
+	Elapsed   int64    `json:"elapsed"`         // 耗时毫秒数
+	Scores    []*Score `json:"scores"`
+	Pass      bool     `json:"pass"` // 所有适用评分器均通过
+
+	Skipped bool `json:"skipped,omitempty"` // 无适用的评分器，视为未通过
+}
+
+// ScorerSummary 单个评分器的汇总
+type ScorerSummary struct {
+	Count    int     `json:"count"`     // 适用的用例数
+	Passed   int     `json:"passed"`    // 通过的用例数
+	AvgScore float64 `json:"avg_score"` // 平均得分
+}
+
+// Report 评测报告，可保存为JSON并与其他批次报告对比
+type Report struct {
+	Dataset   string                    `json:"dataset"`
+	Agent     string                    `json:"agent"`
+	Label     string                    `json:"label,omitempty"`
+	StartTime time.Time                 `json:"start_time"`
+	Elapsed   int64                     `json:"elapsed"` // 总耗时毫秒数
+	Total     int                       `json:"total"`
+	Passed    int                       `json:"passed"`
+	Errors    int                       `json:"errors"`
+	Skipped   int                       `json:"skipped"` // 无适用评分器的用例数
+	PassRate  float64                   `json:"pass_rate"`
+	AvgTime   int64                     `json:"avg_time"` // 用例平均耗时毫秒数
+	Scorers   map[string]*ScorerSummary `json:"scorers"`
+	Results   []*CaseResult             `json:"results"`
+}
+
+func (r *Report) summarize() {
+	r.Total = len(r.Results)
+	r.Passed, r.Errors, r.Skipped = 0, 0, 0
+	r.Scorers = make(map[string]*ScorerSummary)
+
+	var totalTime int64
+	for _, result := range r.Results {
+		totalTime += result.Elapsed
+		if result.Error != "" {
+			r.Errors++
+		}
+		if result.Skipped {
+			r.Skipped++
+		}
+		if result.Pass {
+			r.Passed++
+		}
+		for _, score := range result.Scores {
+			summary, ok := r.Scorers[score.Scorer]
+			if !ok {
+				summary = &ScorerSummary{}
+				r.Scorers[score.Scorer] = summary
+			}
+			summary.Count++
+			summary.AvgScore += score.Value
+			if score.Pass {
+				summary.Passed++
+			}
+		}
+	}
+
+	for _, summary := range r.Scorers {
+		summary.AvgScore /= float64(summary.Count)
+	}
+	if r.Total > 0 {
+		r.PassRate = float64(r.Passed) / float64(r.Total)
+		r.AvgTime = totalTime / int64(r.Total)
+	}
+}
+
+// GetResult 按用例ID获取结果
+func (r *Report) GetResult(caseID string) *CaseResult {
+	for _, result := range r.Results {
+		if result.CaseID == caseID {
+			return result
+		}
+	}
+	return nil
+}
+
+// Save 保存报告为JSON文件
+func (r *Report) Save(file string) error {
+	bs, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Clean(file), bs, 0644)
+}
+
+// LoadReport 加载JSON报告文件
+func LoadReport(file string) (*Report, error) {
+	bs, err := os.ReadFile(filepath.Clean(file))
+	if err != nil {
+		return nil, err
+	}
+	report := &Report{}
+	if err = json.Unmarshal(bs, report); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// Comparison 两次评测报告的对比结果
+type Comparison struct {
+	Base          string             `json:"base"`            // 基准报告标签
+	Current       string             `json:"current"`         // 当前报告标签
+	PassRateDelta float64            `json:"pass_rate_delta"` // 通过率变化
+	AvgTimeDelta  int64              `json:"avg_time_delta"`  // 平均耗时变化毫秒数
+	ScoreDeltas   map[string]float64 `json:"score_deltas"`    // 各评分器平均得分变化
+	Regressions   []string           `json:"regressions"`     // 基准通过、当前不通过的用例ID
+	Improvements  []string           `json:"improvements"`    // 基准不通过、当前通过的用例ID
+}
+
+// Compare 与基准报告对比，两者需为同一数据集
+func (r *Report) Compare(base *Report) (*Comparison, error) {
+	if r.Dataset != base.Dataset {
+		return nil, fmt.Errorf("%w: dataset %s vs %s", ErrReportIncompatible, base.Dataset, r.Dataset)
+	}
+
+	ret := &Comparison{
+		Base:          base.Label,
+		Current:       r.Label,
+		PassRateDelta: r.PassRate - base.PassRate,
+		AvgTimeDelta:  r.AvgTime - base.AvgTime,
+		ScoreDeltas:   make(map[string]float64),
+		Regressions:   make([]string, 0),
+		Improvements:  make([]string, 0),
+	}
+	for name, summary := range r.Scorers {
+		baseAvg := 0.0
+		if tmp, ok := base.Scorers[name]; ok {
+			baseAvg = tmp.AvgScore
+		}
+		ret.ScoreDeltas[name] = summary.AvgScore - baseAvg
+	}
+
+	for _, result := range r.Results {
+		baseResult := base.GetResult(result.CaseID)
+		if baseResult == nil {
+			continue
+		}
+		if baseResult.Pass && !result.Pass {
+			ret.Regressions = append(ret.Regressions, result.CaseID)
+		} else if !baseResult.Pass && result.Pass {
+			ret.Improvements = append(ret.Improvements, result.CaseID)
+		}
+	}
+	return ret, nil
+}
+
+// String 输出便于阅读的对比摘要
+func (c *Comparison) String() string {
+	names := make([]string, 0, len(c.ScoreDeltas))
+	for name := range c.ScoreDeltas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	lines := []string{
+		fmt.Sprintf("%s => %s", c.Base, c.Current),
+		fmt.Sprintf("pass_rate: %+.2f%%, avg_time: %+dms", c.PassRateDelta*100, c.AvgTimeDelta),
+	}
+	for _, name := range names {
+		lines = append(lines, fmt.Sprintf("%s: %+.4f", name, c.ScoreDeltas[name]))
+	}
+	lines = append(lines, fmt.Sprintf("regressions: %v", c.Regressions))
+	lines = append(lines, fmt.Sprintf("improvements: %v", c.Improvements))
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,128 @@
+/*
+@Project: aihub
+@Module: eval
+@File : runner.go
+*/
+package eval
+
+import (
+	"context"
+	"fmt"
+	"github.com/mvptianyu/aihub"
+	"sync"
+	"time"
+)
+
+// Runner 评测执行器，并发运行数据集用例并评分
+type Runner struct {
+	Agent       aihub.IAgent          // 被评测的agent
+	Scorers     []Scorer              // 评分器列表
+	Concurrency int                   // 并发数，默认5
+	Timeout     time.Duration         // 单个用例超时时间，默认不限制
+	Options     []aihub.RunOptionFunc // 每个用例的运行选项
+	Label       string                // 本次评测标签，例如prompt或模型版本，便于报告对比
+}
+
+func NewRunner(ag aihub.IAgent, scorers ...Scorer) *Runner {
+	return &Runner{
+		Agent:   ag,
+		Scorers: scorers,
+	}
+}
+
+// Run 执行数据集评测，结果顺序与用例顺序一致
+func (r *Runner) Run(ctx context.Context, ds *Dataset) *Report {
+	concurrency := r.Concurrency
+	if concurrency <= 0 {
+		concurrency = 5
+	}
+
+	report := &Report{
+		Dataset:   ds.Name,
+		Agent:     r.Agent.GetBriefInfo().Name,
+		Label:     r.Label,
+		StartTime: time.Now(),
+		Results:   make([]*CaseResult, len(ds.Cases)),
+	}
+
+	sem := make(chan struct{}, concurrency)
+	wg := sync.WaitGroup{}
+	for idx, c := range ds.Cases {
+		wg.Add(1)
+		go func(idx int, c *Case) {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+				report.Results[idx] = r.runCase(ctx, c)
+			case <-ctx.Done():
+				report.Results[idx] = &CaseResult{
+					CaseID: c.ID,
+					Input:  c.Input,
+					Tags:   c.Tags,
+					Error:  context.Cause(ctx).Error(),
+					Scores: make([]*Score, 0),
+				}
+			}
+		}(idx, c)
+	}
+	wg.Wait()
+
+	report.Elapsed = time.Since(report.StartTime).Milliseconds()
+	report.summarize()
+	return report
+}
+
+func (r *Runner) runCase(ctx context.Context, c *Case) (ret *CaseResult) {
+	ret = &CaseResult{
+		CaseID: c.ID,
+		Input:  c.Input,
+		Tags:   c.Tags,
+		Scores: make([]*Score, 0),
+	}
+
+	if r.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.Timeout)
+		defer cancel()
+	}
+
+	opts := make([]aihub.RunOptionFunc, 0)
+	opts = append(opts, r.Options...)
+	if c.SessionData != nil {
+		opts = append(opts, aihub.WithSessionData(c.SessionData))
+	}
+
+	start := time.Now()
+	rsp := r.Agent.Run(ctx, c.Input, opts...)
+	ret.Elapsed = time.Since(start).Milliseconds()
+	ret.Output = getOutput(rsp)
+	ret.ToolCalls = GetToolCalls(rsp)
+	if rsp.Err != nil {
+		ret.Error = rsp.Err.Error()
+		return
+	}
+
+	ret.Pass = true
+	for _, scorer := range r.Scorers {
+		score, err := scorer.Score(ctx, c, rsp)
+		if err != nil {
+			score = &Score{
+				Scorer: scorer.Name(),
+				Reason: fmt.Sprintf("scorer error => %v", err),
+			}
+		}
+		if score == nil {
+			continue // 不适用
+		}
+		ret.Scores = append(ret.Scores, score)
+		ret.Pass = ret.Pass && score.Pass
+	}
+
+	// 没有适用的评分器时无法判定结果，不计为通过
+	if len(ret.Scores) == 0 {
+		ret.Pass = false
+		ret.Skipped = true
+	}
+	return
+}
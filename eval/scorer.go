@@ -0,0 +1,277 @@
+/*
+@Project: aihub
+@Module: eval
+@File : scorer.go
+*/
+package eval
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/mvptianyu/aihub"
+	"github.com/tidwall/gjson"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// Score 单个评分器对单个用例的评分结果
+type Score struct {
+	Scorer string  `json:"scorer"`           // 评分器名称
+	Value  float64 `json:"value"`            // 得分[0~1]
+	Pass   bool    `json:"pass"`             // 是否通过
+	Reason string  `json:"reason,omitempty"` // 评分说明
+}
+
+// Scorer 评分器，用例缺少该评分器所需的期望数据时返回nil表示不适用
+type Scorer interface {
+	Name() string
+	Score(ctx context.Context, c *Case, rsp *aihub.Response) (*Score, error)
+}
+
+// ScorerFunc 函数式评分器
+type ScorerFunc struct {
+	ScorerName string
+	Func       func(ctx context.Context, c *Case, rsp *aihub.Response) (*Score, error)
+}
+
+func (s *ScorerFunc) Name() string {
+	return s.ScorerName
+}
+
+func (s *ScorerFunc) Score(ctx context.Context, c *Case, rsp *aihub.Response) (*Score, error) {
+	return s.Func(ctx, c, rsp)
+}
+
+func newScore(name string, value float64, reason string) *Score {
+	return &Score{
+		Scorer: name,
+		Value:  value,
+		Pass:   value >= 1,
+		Reason: reason,
+	}
+}
+
+func getOutput(rsp *aihub.Response) string {
+	if rsp == nil || rsp.Message == nil {
+		return ""
+	}
+	return rsp.Message.Content
+}
+
+// ExactMatch 输出与期望完全一致
+type ExactMatch struct {
+	IgnoreCase bool // 忽略大小写
+	NoTrim     bool // 不去除首尾空白
+}
+
+func (s *ExactMatch) Name() string {
+	return "exact"
+}
+
+func (s *ExactMatch) Score(ctx context.Context, c *Case, rsp *aihub.Response) (*Score, error) {
+	if c.Expected == "" {
+		return nil, nil
+	}
+
+	output, expected := getOutput(rsp), c.Expected
+	if !s.NoTrim {
+		output, expected = strings.TrimSpace(output), strings.TrimSpace(expected)
+	}
+	if s.IgnoreCase {
+		output, expected = strings.ToLower(output), strings.ToLower(expected)
+	}
+	if output == expected {
+		return newScore(s.Name(), 1, ""), nil
+	}
+	return newScore(s.Name(), 0, "output not equal to expected"), nil
+}
+
+// Regex 输出匹配用例中的正则
+type Regex struct{}
+
+func (s *Regex) Name() string {
+	return "regex"
+}
+
+func (s *Regex) Score(ctx context.Context, c *Case, rsp *aihub.Response) (*Score, error) {
+	if c.Pattern == "" {
+		return nil, nil
+	}
+
+	reg, err := regexp.Compile(c.Pattern)
+	if err != nil {
+		return nil, fmt.Errorf("%w: case %s pattern => %v", ErrScorerConfig, c.ID, err)
+	}
+	if reg.MatchString(getOutput(rsp)) {
+		return newScore(s.Name(), 1, ""), nil
+	}
+	return newScore(s.Name(), 0, "output not match pattern: "+c.Pattern), nil
+}
+
+// JSONField 输出为JSON时，按gjson路径逐个比对字段值，得分为匹配字段占比
+type JSONField struct{}
+
+func (s *JSONField) Name() string {
+	return "json_field"
+}
+
+func (s *JSONField) Score(ctx context.Context, c *Case, rsp *aihub.Response) (*Score, error) {
+	if len(c.ExpectedFields) == 0 {
+		return nil, nil
+	}
+
+	output := aihub.TrimJSONCodeBlock(getOutput(rsp))
+	if !gjson.Valid(output) {
+		return newScore(s.Name(), 0, "output is not valid json"), nil
+	}
+
+	matched := 0
+	mismatch := make([]string, 0)
+	for path, expected := range c.ExpectedFields {
+		actual := gjson.Get(output, path)
+		if actual.Exists() && reflect.DeepEqual(normalizeJSONValue(expected), actual.Value()) {
+			matched++
+			continue
+		}
+		mismatch = append(mismatch, fmt.Sprintf("%s: want %v, got %v", path, expected, actual.Value()))
+	}
+	return newScore(s.Name(), float64(matched)/float64(len(c.ExpectedFields)), strings.Join(mismatch, "; ")), nil
+}
+
+// normalizeJSONValue 统一为JSON反序列化后的类型，便于比较
+func normalizeJSONValue(v interface{}) interface{} {
+	bs, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	var ret interface{}
+	if err = json.Unmarshal(bs, &ret); err != nil {
+		return v
+	}
+	return ret
+}
+
+// ToolSequence 工具及子agent调用顺序比对
+type ToolSequence struct {
+	Strict bool // 严格模式：调用序列须与期望完全一致，否则只需按序包含期望的调用
+}
+
+func (s *ToolSequence) Name() string {
+	return "tool_sequence"
+}
+
+func (s *ToolSequence) Score(ctx context.Context, c *Case, rsp *aihub.Response) (*Score, error) {
+	if c.ExpectedTools == nil {
+		return nil, nil
+	}
+
+	actual := GetToolCalls(rsp)
+	reason := fmt.Sprintf("want %v, got %v", c.ExpectedTools, actual)
+	if s.Strict {
+		if reflect.DeepEqual(actual, c.ExpectedTools) || (len(actual) == 0 && len(c.ExpectedTools) == 0) {
+			return newScore(s.Name(), 1, ""), nil
+		}
+		return newScore(s.Name(), 0, reason), nil
+	}
+
+	if len(c.ExpectedTools) == 0 {
+		return newScore(s.Name(), 1, ""), nil
+	}
+	matched := 0
+	for _, name := range actual {
+		if matched < len(c.ExpectedTools) && name == c.ExpectedTools[matched] {
+			matched++
+		}
+	}
+	if matched == len(c.ExpectedTools) {
+		reason = ""
+	}
+	return newScore(s.Name(), float64(matched)/float64(len(c.ExpectedTools)), reason), nil
+}
+
+// GetToolCalls 按执行顺序获取工具及子agent调用名称
+func GetToolCalls(rsp *aihub.Response) []string {
+	ret := make([]string, 0)
+	if rsp == nil {
+		return ret
+	}
+	for _, step := range rsp.Steps {
+		if step.StepType == aihub.StepType_Tool || step.StepType == aihub.StepType_Agent {
+			ret = append(ret, step.Action)
+		}
+	}
+	return ret
+}
+
+const defaultJudgePrompt = `你是一个严格、公正的评测员，请根据评判标准和参考答案，对回答进行评分。
+只输出标准JSON格式（去除制表、换行符）：{"score":0到1之间的小数,"reason":"评分理由"}`
+
+// LLMJudge 使用LLMHub中的LLM按评判标准打分
+type LLMJudge struct {
+	LLM       string  // LLM名称
+	Prompt    string  // 可选，评测员系统提示词
+	Threshold float64 // 通过阈值，默认0.6
+}
+
+func (s *LLMJudge) Name() string {
+	return "llm_judge"
+}
+
+func (s *LLMJudge) Score(ctx context.Context, c *Case, rsp *aihub.Response) (*Score, error) {
+	if c.Criteria == "" && c.Expected == "" {
+		return nil, nil
+	}
+
+	LLMIns := aihub.GetLLMHub().GetLLM(s.LLM)
+	if LLMIns == nil {
+		return nil, fmt.Errorf("%w: llm not found %s", ErrScorerConfig, s.LLM)
+	}
+	prompt := s.Prompt
+	if prompt == "" {
+		prompt = defaultJudgePrompt
+	}
+	threshold := s.Threshold
+	if threshold <= 0 || threshold > 1 {
+		threshold = 0.6
+	}
+
+	content := fmt.Sprintf("## 问题\n%s\n\n## 回答\n%s", c.Input, getOutput(rsp))
+	if c.Expected != "" {
+		content += fmt.Sprintf("\n\n## 参考答案\n%s", c.Expected)
+	}
+	if c.Criteria != "" {
+		content += fmt.Sprintf("\n\n## 评判标准\n%s", c.Criteria)
+	}
+
+	judgeRsp, err := LLMIns.CreateChatCompletion(ctx, &aihub.CreateChatCompletionReq{
+		Messages: []*aihub.Message{
+			{Role: aihub.MessageRoleSystem, Content: prompt},
+			{Role: aihub.MessageRoleUser, Content: content},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if judgeRsp.Error != nil {
+		return nil, fmt.Errorf("%w: %s", ErrJudgeInvalid, judgeRsp.Error.Message)
+	}
+	if len(judgeRsp.Choices) == 0 || judgeRsp.Choices[0].Message == nil {
+		return nil, fmt.Errorf("%w: empty response", ErrJudgeInvalid)
+	}
+
+	result := &struct {
+		Score  float64 `json:"score"`
+		Reason string  `json:"reason"`
+	}{}
+	if err = json.Unmarshal([]byte(aihub.TrimJSONCodeBlock(judgeRsp.Choices[0].Message.Content)), result); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrJudgeInvalid, err)
+	}
+	return &Score{
+		Scorer: s.Name(),
+		Value:  result.Score,
+		Pass:   result.Score >= threshold,
+		Reason: result.Reason,
+	}, nil
+}
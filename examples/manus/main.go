@@ -11,6 +11,7 @@ func main() {
 	depency.Init() // 初始化
 
 	ctx := context.Background()
+	aihub.GetAgentHub().SetAgentTemplateByYamlFile("base.yaml")
 	aihub.GetAgentHub().SetAgentByYamlFile("weather.yaml")
 	aihub.GetAgentHub().SetAgentByYamlFile("song.yaml")
 
@@ -8,12 +8,15 @@ require (
 	github.com/mark3labs/mcp-go v0.18.0
 	github.com/satori/go.uuid v1.2.0
 	github.com/tidwall/gjson v1.18.0
+	go.etcd.io/bbolt v1.4.0
 	golang.org/x/time v0.10.0
 	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
+	github.com/google/uuid v1.6.0 // indirect
 	github.com/tidwall/match v1.1.1 // indirect
 	github.com/tidwall/pretty v1.2.0 // indirect
 	github.com/yosida95/uritemplate/v3 v3.0.2 // indirect
+	golang.org/x/sys v0.29.0 // indirect
 )
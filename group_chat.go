@@ -0,0 +1,458 @@
+/*
+@Project: aihub
+@Module: aihub
+@File : group_chat.go
+*/
+package aihub
+
+import (
+	"context"
+	"fmt"
+	"github.com/mvptianyu/aihub/ssestream"
+	uuid "github.com/satori/go.uuid"
+	"gopkg.in/yaml.v3"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	GroupChatSelector_RoundRobin = "round_robin" // 轮流发言
+	GroupChatSelector_LLM        = "llm"         // 由LLM根据对话选择下一位发言者
+)
+
+// GroupChatConfig 多agent群聊配置
+type GroupChatConfig struct {
+	BriefInfo `yaml:",inline"` // yaml解析inline结构
+
+	Agents      []string                   `json:"agents" yaml:"agents"`                               // 参与群聊的agent名称
+	Selector    GroupChatSelectorConfig    `json:"selector,omitempty" yaml:"selector,omitempty"`       // 发言者选择策略
+	Termination GroupChatTerminationConfig `json:"termination,omitempty" yaml:"termination,omitempty"` // 结束条件
+	RunTimeout  int64                      `json:"run_timeout,omitempty" yaml:"run_timeout,omitempty"` // 执行超时秒数
+	Claim       string                     `json:"claim,omitempty" yaml:"claim,omitempty"`             // 宣称文案
+	Debug       bool                       `json:"debug,omitempty" yaml:"debug,omitempty"`             // debug输出标志，开启则输出每轮发言过程
+}
+
+// GroupChatSelectorConfig 发言者选择策略配置
+type GroupChatSelectorConfig struct {
+	Type   string `json:"type,omitempty" yaml:"type,omitempty"`     // round_robin|llm|自定义注册名称，默认round_robin
+	LLM    string `json:"llm,omitempty" yaml:"llm,omitempty"`       // llm：用于选择的LLM名称
+	Prompt string `json:"prompt,omitempty" yaml:"prompt,omitempty"` // llm：额外的选择规则说明
+}
+
+// GroupChatTerminationConfig 群聊结束条件配置，任一满足即结束
+type GroupChatTerminationConfig struct {
+	Keyword        string `json:"keyword,omitempty" yaml:"keyword,omitempty"`                 // 发言中出现该关键词时结束
+	MaxRounds      int    `json:"max_rounds,omitempty" yaml:"max_rounds,omitempty"`           // 最大发言轮数
+	JudgeAgent     string `json:"judge_agent,omitempty" yaml:"judge_agent,omitempty"`         // 每轮发言后由该agent评审
+	ApproveKeyword string `json:"approve_keyword,omitempty" yaml:"approve_keyword,omitempty"` // 评审agent回复包含该关键词视为通过，默认APPROVE
+}
+
+func (cfg *GroupChatConfig) AutoFix() error {
+	if cfg.Selector.Type == "" {
+		cfg.Selector.Type = GroupChatSelector_RoundRobin
+	}
+	if cfg.Termination.MaxRounds <= 0 || cfg.Termination.MaxRounds > 50 {
+		cfg.Termination.MaxRounds = 10
+	}
+	if cfg.Termination.ApproveKeyword == "" {
+		cfg.Termination.ApproveKeyword = "APPROVE"
+	}
+	if cfg.RunTimeout <= 0 || cfg.RunTimeout > 60*60 {
+		cfg.RunTimeout = 60 * 60
+	}
+
+	if cfg.Name == "" || len(cfg.Agents) == 0 {
+		return ErrConfiguration
+	}
+	if cfg.Selector.Type == GroupChatSelector_LLM && cfg.Selector.LLM == "" {
+		return ErrConfiguration
+	}
+	return nil
+}
+
+// GroupChatSelectFunc 自定义发言者选择方法，返回下一位发言的agent名称
+type GroupChatSelectFunc func(ctx context.Context, transcript []*Message, agents []BriefInfo, round int) (string, error)
+
+var groupChatSelectors = make(map[string]GroupChatSelectFunc)
+var groupChatSelectorsLock sync.RWMutex
+
+// RegisterGroupChatSelector 注册自定义发言者选择方法，可在GroupChatConfig中按selector.type引用
+func RegisterGroupChatSelector(name string, fn GroupChatSelectFunc) {
+	groupChatSelectorsLock.Lock()
+	defer groupChatSelectorsLock.Unlock()
+	groupChatSelectors[name] = fn
+}
+
+type groupChat struct {
+	cfg *GroupChatConfig
+}
+
+func newGroupChat(cfg *GroupChatConfig) (IAgent, error) {
+	if err := cfg.AutoFix(); err != nil {
+		return nil, err
+	}
+
+	switch cfg.Selector.Type {
+	case GroupChatSelector_RoundRobin, GroupChatSelector_LLM:
+	default:
+		groupChatSelectorsLock.RLock()
+		_, ok := groupChatSelectors[cfg.Selector.Type]
+		groupChatSelectorsLock.RUnlock()
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown group chat selector %s", ErrConfiguration, cfg.Selector.Type)
+		}
+	}
+	return &groupChat{cfg: cfg}, nil
+}
+
+func (g *groupChat) GetBriefInfo() BriefInfo {
+	return g.cfg.BriefInfo
+}
+
+func (g *groupChat) Run(ctx context.Context, input string, opts ...RunOptionFunc) (ret *Response) {
+	ret = &Response{}
+	options := &RunOptions{
+		RunID: uuid.NewV4().String(),
+		RuntimeCfg: AgentRuntimeCfg{
+			MaxStepQuit: g.cfg.Termination.MaxRounds * 2,
+			RunTimeout:  g.cfg.RunTimeout,
+			Claim:       g.cfg.Claim,
+			Debug:       g.cfg.Debug,
+		},
+		Session: newSession(nil),
+	}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	// 检测嵌套调用的循环及深度
+	var err error
+	if ctx, err = enterCallChain(ctx, g.cfg.Name, &options.RuntimeCfg); err != nil {
+		ret.Err = err
+		return
+	}
+
+	ret.RunID = options.RunID
+	ret.Session = options.Session
+	ctx = ContextWithSession(ctx, options.Session)
+	cancelCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	if options.RuntimeCfg.RunTimeout <= 0 {
+		options.RuntimeCfg.RunTimeout = g.cfg.RunTimeout
+	}
+	newCtx, cancelTimeout := context.WithTimeoutCause(cancelCtx, time.Duration(options.RuntimeCfg.RunTimeout)*time.Second, ErrAgentRunTimeout)
+	defer cancelTimeout()
+
+	registry := GetAgentHub().GetRunRegistry().(*runRegistry)
+	if err = registry.register(g, input, options, cancel); err != nil {
+		ret.Err = err
+		return
+	}
+	defer registry.unregister(options.RunID)
+
+	options.AddStep(&RunStep{
+		Question: input,
+		StepType: StepType_Start,
+		State:    RunState_Succeed,
+	})
+	endStep := &RunStep{
+		StepType: StepType_End,
+		State:    RunState_Idle,
+	}
+
+	transcript := []*Message{
+		{Role: MessageRoleUser, Content: input},
+	}
+	answer, err := g.chat(newCtx, options, &transcript)
+	ret.Err = err
+	ret.Transcript = transcript
+
+	if ret.Err != nil {
+		endStep.State = RunState_Failed
+	} else {
+		ret.Message = &Message{
+			Role:    MessageRoleAssistant,
+			Name:    answer.Name,
+			Content: g.trimKeyword(answer.Content),
+		}
+		endStep.Result = ret.Message.Content
+		endStep.State = RunState_Succeed
+	}
+	options.AddStep(endStep)
+	ret.Content = options.RenderFinalAnswer()
+	ret.Steps = options.GetSteps()
+	return
+}
+
+// chat 按选择策略轮流发言，直至满足结束条件，返回最后一位成员的发言（不含评审意见）
+func (g *groupChat) chat(ctx context.Context, opts *RunOptions, transcript *[]*Message) (answer *Message, err error) {
+	members := GetAgentHub().GetAgentList(g.cfg.Agents...)
+	if len(members) == 0 {
+		return nil, ErrConfiguration
+	}
+	briefInfos := make([]BriefInfo, 0)
+	for _, member := range members {
+		briefInfos = append(briefInfos, member.GetBriefInfo())
+	}
+
+	for round := 0; round < g.cfg.Termination.MaxRounds; round++ {
+		if ctx.Err() != nil {
+			return answer, context.Cause(ctx)
+		}
+
+		speaker, err := g.selectSpeaker(ctx, *transcript, briefInfos, round)
+		if err != nil {
+			return answer, err
+		}
+		ag := GetAgentHub().GetAgent(speaker)
+		if ag == nil {
+			return answer, fmt.Errorf("%w: %s", ErrCallNameNotMatch, speaker)
+		}
+
+		step := &RunStep{
+			Action:   speaker,
+			StepType: StepType_Agent,
+			State:    RunState_Running,
+		}
+		question := g.renderTranscript(*transcript, speaker)
+		rsp := g.runMember(ctx, ag, question, opts)
+		if rsp.Err != nil {
+			step.State = RunState_Failed
+			step.Result = rsp.Err.Error()
+			opts.AddStep(step)
+			return answer, rsp.Err
+		}
+
+		content := ""
+		if rsp.Message != nil {
+			content = rsp.Message.Content
+		}
+		answer = &Message{
+			Role:       MessageRoleAssistant,
+			Name:       speaker,
+			Content:    content,
+			CreateTime: time.Now().Unix(),
+		}
+		*transcript = append(*transcript, answer)
+		step.Question = fmt.Sprintf("round %d", round+1)
+		step.Result = content
+		step.State = RunState_Succeed
+		opts.AddStep(step)
+
+		// 结束条件判定
+		if g.cfg.Termination.Keyword != "" && strings.Contains(content, g.cfg.Termination.Keyword) {
+			return answer, nil
+		}
+		if g.cfg.Termination.JudgeAgent != "" {
+			approved, err := g.judge(ctx, opts, transcript)
+			if err != nil {
+				return answer, err
+			}
+			if approved {
+				return answer, nil
+			}
+		}
+	}
+	return answer, nil
+}
+
+// runMember 以独立会话运行成员agent并合并session变更，结束后清理该会话记忆，避免每轮遗留孤立会话
+func (g *groupChat) runMember(ctx context.Context, ag IAgent, question string, opts *RunOptions) *Response {
+	rsp := ag.Run(ctx, question, WithDebug(false), WithSessionData(opts.CopySessionData()))
+	if rsp.Session != nil {
+		opts.MergeSessionData(rsp.Session.CopySessionData())
+		ag.ResetMemory(ctx, WithSessionID(rsp.Session.SessionID))
+	}
+	return rsp
+}
+
+// selectSpeaker 选择下一位发言者
+func (g *groupChat) selectSpeaker(ctx context.Context, transcript []*Message, agents []BriefInfo, round int) (string, error) {
+	roundRobin := agents[round%len(agents)].Name
+
+	switch g.cfg.Selector.Type {
+	case GroupChatSelector_RoundRobin:
+		return roundRobin, nil
+	case GroupChatSelector_LLM:
+		name, err := g.selectByLLM(ctx, transcript, agents)
+		if err != nil {
+			return "", err
+		}
+		for _, item := range agents {
+			if item.Name == name {
+				return name, nil
+			}
+		}
+		log.Printf("groupChat::selectSpeaker unknown speaker => name:%s, fallback:%s\n", name, roundRobin)
+		return roundRobin, nil
+	default:
+		groupChatSelectorsLock.RLock()
+		fn := groupChatSelectors[g.cfg.Selector.Type]
+		groupChatSelectorsLock.RUnlock()
+		return fn(ctx, transcript, agents, round)
+	}
+}
+
+const groupChatSelectorPrompt = `你是一场多人协作对话的主持人，需要根据对话内容选择下一位最合适的发言者。
+
+## 可选发言者：
+%s
+%s
+## 输出格式：
+只输出下一位发言者的名称，不要输出任何其他内容`
+
+func (g *groupChat) selectByLLM(ctx context.Context, transcript []*Message, agents []BriefInfo) (string, error) {
+	LLMIns := GetLLMHub().GetLLM(g.cfg.Selector.LLM)
+	if LLMIns == nil {
+		return "", ErrConfiguration
+	}
+
+	members := ""
+	for _, item := range agents {
+		members += fmt.Sprintf("- %s: %s\n", item.Name, item.Description)
+	}
+	rules := ""
+	if g.cfg.Selector.Prompt != "" {
+		rules = "\n## 选择规则：\n" + g.cfg.Selector.Prompt + "\n"
+	}
+
+	rsp, err := createChatCompletion(ctx, LLMIns, &CreateChatCompletionReq{
+		Messages: []*Message{
+			{Role: MessageRoleSystem, Content: fmt.Sprintf(groupChatSelectorPrompt, members, rules)},
+			{Role: MessageRoleUser, Content: g.renderTranscript(transcript, "")},
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+	if rsp.Error != nil {
+		return "", fmt.Errorf("%s", rsp.Error.Message)
+	}
+	if len(rsp.Choices) == 0 || rsp.Choices[0].Message == nil {
+		return "", ErrToolCallResponseEmpty
+	}
+	return strings.TrimSpace(rsp.Choices[0].Message.Content), nil
+}
+
+// judge 由评审agent判定是否通过，未通过时评审意见加入群聊记录供下一位发言者参考
+func (g *groupChat) judge(ctx context.Context, opts *RunOptions, transcript *[]*Message) (bool, error) {
+	ag := GetAgentHub().GetAgent(g.cfg.Termination.JudgeAgent)
+	if ag == nil {
+		return false, fmt.Errorf("%w: %s", ErrCallNameNotMatch, g.cfg.Termination.JudgeAgent)
+	}
+
+	question := g.renderTranscript(*transcript, "") +
+		fmt.Sprintf("\n请评审以上对话是否已满足用户需求，满足时回复包含%s，否则给出修改意见", g.cfg.Termination.ApproveKeyword)
+	rsp := g.runMember(ctx, ag, question, opts)
+	if rsp.Err != nil {
+		return false, rsp.Err
+	}
+
+	content := ""
+	if rsp.Message != nil {
+		content = rsp.Message.Content
+	}
+	approved := strings.Contains(content, g.cfg.Termination.ApproveKeyword)
+	opts.AddStep(&RunStep{
+		Action:   g.cfg.Termination.JudgeAgent,
+		Question: "judge",
+		Result:   content,
+		State:    RunState_Succeed,
+		StepType: StepType_Agent,
+	})
+	if !approved {
+		*transcript = append(*transcript, &Message{
+			Role:       MessageRoleAssistant,
+			Name:       g.cfg.Termination.JudgeAgent,
+			Content:    content,
+			CreateTime: time.Now().Unix(),
+		})
+	}
+	return approved, nil
+}
+
+// renderTranscript 将群聊记录渲染为发言者的输入
+func (g *groupChat) renderTranscript(transcript []*Message, speaker string) string {
+	output := "## 对话记录：\n"
+	for _, msg := range transcript {
+		name := msg.Name
+		if msg.Role == MessageRoleUser {
+			name = "用户"
+		}
+		output += fmt.Sprintf("[%s]: %s\n", name, msg.Content)
+	}
+	if speaker != "" {
+		output += fmt.Sprintf("\n现在轮到你（%s）发言，请结合对话记录继续推进用户需求", speaker)
+	}
+	return output
+}
+
+func (g *groupChat) trimKeyword(content string) string {
+	if g.cfg.Termination.Keyword == "" {
+		return content
+	}
+	return strings.TrimSpace(strings.Replace(content, g.cfg.Termination.Keyword, "", -1))
+}
+
+func (g *groupChat) RunStream(ctx context.Context, input string, opts ...RunOptionFunc) (stream *ssestream.StreamReader[Response]) {
+	return runStream(ctx, func() *Response {
+		return g.Run(ctx, input, opts...)
+	})
+}
+
+func (g *groupChat) ResetMemory(ctx context.Context, opts ...RunOptionFunc) error {
+	return nil
+}
+
+func (g *groupChat) GetMemory() IMemory {
+	return nil
+}
+
+func (g *groupChat) Close() error {
+	return nil
+}
+
+func (g *groupChat) GetToolFunctions() []ToolFunction {
+	return []ToolFunction{}
+}
+
+func (g *groupChat) InvokeToolCall(ctx context.Context, name string, args string, output *Message) (err error) {
+	return ErrCallNameNotMatch
+}
+
+// ========AgentHub注册==========
+
+func (h *agentHub) SetGroupChat(cfg *GroupChatConfig) (IAgent, error) {
+	ag, err := newGroupChat(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.setAgentIns(ag)
+	return ag, nil
+}
+
+func (h *agentHub) SetGroupChatByYamlData(yamlData []byte) (IAgent, error) {
+	cfg := &GroupChatConfig{}
+	if err := yaml.Unmarshal(yamlData, cfg); err != nil {
+		log.Printf("Error GroupChatConfig Unmarshal YAML data: %s => %v\n", string(yamlData), err)
+		return nil, err
+	}
+	return h.SetGroupChat(cfg)
+}
+
+func (h *agentHub) SetGroupChatByYamlFile(yamlFile string) (IAgent, error) {
+	// 读取 YAML 文件内容
+	yamlData, err := os.ReadFile(filepath.Clean(yamlFile))
+	if err != nil {
+		log.Printf("Error reading YAML file: %s => %v\n", yamlFile, err)
+		return nil, err
+	}
+	return h.SetGroupChatByYamlData(yamlData)
+}
@@ -0,0 +1,131 @@
+/*
+@Project: aihub
+@Module: aihub
+@File : group_chat_test.go
+*/
+package aihub
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func Test_groupChat_Run(t *testing.T) {
+	var drafts int32
+	newTestLLM(t, "test-writer-llm", func(req *CreateChatCompletionReq) *ChatCompletionRspChoice {
+		n := atomic.AddInt32(&drafts, 1)
+		return &ChatCompletionRspChoice{
+			Message:      &Message{Role: MessageRoleAssistant, Content: fmt.Sprintf("draft v%d", n)},
+			FinishReason: ChatCompletionRspFinishReasonStop,
+		}
+	})
+	newTestLLM(t, "test-reviewer-llm", func(req *CreateChatCompletionReq) *ChatCompletionRspChoice {
+		content := "请补充细节"
+		if strings.Contains(req.Messages[len(req.Messages)-1].Content, "draft v2") {
+			content = "APPROVE"
+		}
+		return &ChatCompletionRspChoice{
+			Message:      &Message{Role: MessageRoleAssistant, Content: content},
+			FinishReason: ChatCompletionRspFinishReasonStop,
+		}
+	})
+
+	for name, llm := range map[string]string{"test-writer": "test-writer-llm", "test-reviewer": "test-reviewer-llm"} {
+		if _, err := GetAgentHub().SetAgent(&AgentConfig{
+			BriefInfo:       BriefInfo{Name: name},
+			AgentRuntimeCfg: AgentRuntimeCfg{LLM: llm},
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	gc, err := GetAgentHub().SetGroupChatByYamlData([]byte(`
+name: test-group-chat
+agents:
+  - test-writer
+termination:
+  max_rounds: 5
+  judge_agent: test-reviewer
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if GetAgentHub().GetAgent("test-group-chat") == nil {
+		t.Fatal("group chat not registered")
+	}
+
+	rsp := gc.Run(context.Background(), "写一段产品介绍")
+	if rsp.Err != nil {
+		t.Fatal(rsp.Err)
+	}
+	if rsp.Message.Content != "draft v2" || rsp.Message.Name != "test-writer" {
+		t.Fatalf("unexpected final answer => %+v", rsp.Message)
+	}
+	if len(rsp.Transcript) != 4 {
+		t.Fatalf("want 4 transcript messages, got %d", len(rsp.Transcript))
+	}
+	if feedback := rsp.Transcript[2]; feedback.Name != "test-reviewer" || feedback.Content != "请补充细节" {
+		t.Fatalf("want judge feedback in transcript => %+v", feedback)
+	}
+
+	// 评审未通过时仍以最后一位成员的发言作为结果
+	gc, err = GetAgentHub().SetGroupChatByYamlData([]byte(`
+name: test-group-chat-reject
+agents:
+  - test-writer
+termination:
+  max_rounds: 1
+  judge_agent: test-reviewer
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rsp = gc.Run(context.Background(), "写一段产品介绍"); rsp.Err != nil || rsp.Message.Content != "draft v3" || rsp.Message.Name != "test-writer" {
+		t.Fatalf("want last member answer => %+v, %v", rsp.Message, rsp.Err)
+	}
+
+	// 每轮运行后清理成员的会话记忆
+	for _, name := range []string{"test-writer", "test-reviewer"} {
+		if sessions := GetAgentHub().GetAgent(name).GetMemory().ListSessions(); len(sessions) != 0 {
+			t.Fatalf("want no orphan sessions for %s => %v", name, sessions)
+		}
+	}
+}
+
+func Test_groupChat_RunTimeout(t *testing.T) {
+	newTestLLM(t, "test-slow-writer-llm", func(req *CreateChatCompletionReq) *ChatCompletionRspChoice {
+		time.Sleep(2 * time.Second)
+		return &ChatCompletionRspChoice{
+			Message:      &Message{Role: MessageRoleAssistant, Content: "draft"},
+			FinishReason: ChatCompletionRspFinishReasonStop,
+		}
+	})
+	if _, err := GetAgentHub().SetAgent(&AgentConfig{
+		BriefInfo:       BriefInfo{Name: "test-slow-writer"},
+		AgentRuntimeCfg: AgentRuntimeCfg{LLM: "test-slow-writer-llm"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	gc, err := GetAgentHub().SetGroupChatByYamlData([]byte(`
+name: test-group-chat-timeout
+agents:
+  - test-slow-writer
+termination:
+  max_rounds: 1
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 按本次运行的超时设置执行
+	start := time.Now()
+	rsp := gc.Run(context.Background(), "写一段产品介绍", WithRuntimeCfg(AgentRuntimeCfg{RunTimeout: 1}))
+	if rsp.Err == nil || time.Since(start) > 1800*time.Millisecond {
+		t.Fatalf("want run timeout => err:%v, cost:%v", rsp.Err, time.Since(start))
+	}
+}
@@ -0,0 +1,338 @@
+/*
+@Project: aihub
+@Module: aihub
+@File : guardrail.go
+*/
+package aihub
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/mvptianyu/aihub/jsonschema"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// GuardrailStage 护栏检查阶段
+type GuardrailStage string
+
+const (
+	GuardrailStage_Input  GuardrailStage = "input"  // 用户输入，首次LLM调用前
+	GuardrailStage_Output GuardrailStage = "output" // 最终回答，返回前
+	GuardrailStage_Both   GuardrailStage = "both"   // 输入输出均检查
+)
+
+// GuardrailAction 护栏检查结果动作
+type GuardrailAction int
+
+const (
+	GuardrailAction_Pass    GuardrailAction = iota // 通过
+	GuardrailAction_Block                          // 拦截
+	GuardrailAction_Rewrite                        // 改写
+)
+
+// GuardrailResult 护栏检查结果
+type GuardrailResult struct {
+	Action  GuardrailAction
+	Message string // 拦截原因
+	Content string // 改写后的内容
+}
+
+// GuardrailConfig 护栏配置
+type GuardrailConfig struct {
+	Name    string         `json:"name,omitempty" yaml:"name,omitempty"`       // 名称，为空时取类型名
+	Type    string         `json:"type" yaml:"type"`                           // 类型：regex|max_length|json_schema|llm_judge，或自定义注册类型
+	Stage   GuardrailStage `json:"stage,omitempty" yaml:"stage,omitempty"`     // 检查阶段：input|output|both，默认both
+	Message string         `json:"message,omitempty" yaml:"message,omitempty"` // 拦截时返回的提示文案
+
+	Patterns  []string               `json:"patterns,omitempty" yaml:"patterns,omitempty"`     // regex：禁止匹配的正则列表
+	Replace   *string                `json:"replace,omitempty" yaml:"replace,omitempty"`       // regex：非空时将匹配内容替换改写，而非拦截
+	MaxLength int                    `json:"max_length,omitempty" yaml:"max_length,omitempty"` // max_length：最大字符数
+	Truncate  bool                   `json:"truncate,omitempty" yaml:"truncate,omitempty"`     // max_length：超长时截断改写，而非拦截
+	Schema    *jsonschema.Definition `json:"schema,omitempty" yaml:"schema,omitempty"`         // json_schema：内容需满足的JSON Schema
+	LLM       string                 `json:"llm,omitempty" yaml:"llm,omitempty"`               // llm_judge：审核用的LLM名称
+	Criteria  string                 `json:"criteria,omitempty" yaml:"criteria,omitempty"`     // llm_judge：审核标准
+}
+
+func (cfg *GuardrailConfig) AutoFix() error {
+	if cfg.Type == "" {
+		return ErrConfiguration
+	}
+	if cfg.Name == "" {
+		cfg.Name = cfg.Type
+	}
+	if cfg.Stage == "" {
+		cfg.Stage = GuardrailStage_Both
+	}
+	return nil
+}
+
+// Match 是否需要在该阶段检查
+func (cfg *GuardrailConfig) Match(stage GuardrailStage) bool {
+	return cfg.Stage == GuardrailStage_Both || cfg.Stage == stage
+}
+
+// GuardrailError 护栏拦截错误
+type GuardrailError struct {
+	Name    string         `json:"name"`
+	Stage   GuardrailStage `json:"stage"`
+	Message string         `json:"message"`
+}
+
+func (e *GuardrailError) Error() string {
+	return fmt.Sprintf("%s => name:%s, stage:%s, message:%s", ErrGuardrailTripped.Error(), e.Name, e.Stage, e.Message)
+}
+
+func (e *GuardrailError) Is(target error) bool {
+	return target == ErrGuardrailTripped
+}
+
+// GuardrailFactory 护栏构造方法
+type GuardrailFactory func(cfg *GuardrailConfig) (IGuardrail, error)
+
+var guardrailFactorys = map[string]GuardrailFactory{
+	"regex":       newRegexGuardrail,
+	"max_length":  newMaxLengthGuardrail,
+	"json_schema": newJSONSchemaGuardrail,
+	"llm_judge":   newLLMJudgeGuardrail,
+}
+var guardrailFactorysLock sync.RWMutex
+
+// RegisterGuardrail 注册自定义护栏类型，可在AgentConfig中按type引用
+func RegisterGuardrail(typ string, factory GuardrailFactory) {
+	guardrailFactorysLock.Lock()
+	defer guardrailFactorysLock.Unlock()
+	guardrailFactorys[typ] = factory
+}
+
+type guardrailIns struct {
+	cfg *GuardrailConfig
+	IGuardrail
+}
+
+// newGuardrails 按配置构造护栏列表
+func newGuardrails(cfgs []*GuardrailConfig) ([]*guardrailIns, error) {
+	ret := make([]*guardrailIns, 0)
+	for _, cfg := range cfgs {
+		if err := cfg.AutoFix(); err != nil {
+			return nil, err
+		}
+
+		guardrailFactorysLock.RLock()
+		factory, ok := guardrailFactorys[cfg.Type]
+		guardrailFactorysLock.RUnlock()
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown guardrail type %s", ErrConfiguration, cfg.Type)
+		}
+
+		ins, err := factory(cfg)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, &guardrailIns{cfg: cfg, IGuardrail: ins})
+	}
+	return ret, nil
+}
+
+// checkGuardrails 依次执行对应阶段的护栏，改写结果直接作用于content
+func (a *agent) checkGuardrails(ctx context.Context, stage GuardrailStage, content *string, opts *RunOptions) error {
+	for _, item := range a.guardrails {
+		if !item.cfg.Match(stage) {
+			continue
+		}
+
+		result, err := item.Check(ctx, *content, opts)
+		if err != nil {
+			return err
+		}
+
+		switch result.Action {
+		case GuardrailAction_Block:
+			message := item.cfg.Message
+			if message == "" {
+				message = result.Message
+			}
+			return &GuardrailError{
+				Name:    item.cfg.Name,
+				Stage:   stage,
+				Message: message,
+			}
+		case GuardrailAction_Rewrite:
+			*content = result.Content
+		}
+	}
+	return nil
+}
+
+// ========内置护栏============
+
+type regexGuardrail struct {
+	regexps []*regexp.Regexp
+	replace *string
+}
+
+func newRegexGuardrail(cfg *GuardrailConfig) (IGuardrail, error) {
+	ret := &regexGuardrail{
+		regexps: make([]*regexp.Regexp, 0),
+		replace: cfg.Replace,
+	}
+	for _, pattern := range cfg.Patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("%w: guardrail %s invalid pattern %s", ErrConfiguration, cfg.Name, pattern)
+		}
+		ret.regexps = append(ret.regexps, re)
+	}
+	return ret, nil
+}
+
+func (g *regexGuardrail) Check(ctx context.Context, content string, opts *RunOptions) (*GuardrailResult, error) {
+	matched := false
+	for _, re := range g.regexps {
+		if !re.MatchString(content) {
+			continue
+		}
+		if g.replace == nil {
+			return &GuardrailResult{
+				Action:  GuardrailAction_Block,
+				Message: "内容命中禁止规则 => " + re.String(),
+			}, nil
+		}
+		matched = true
+		content = re.ReplaceAllString(content, *g.replace)
+	}
+
+	if matched {
+		return &GuardrailResult{Action: GuardrailAction_Rewrite, Content: content}, nil
+	}
+	return &GuardrailResult{Action: GuardrailAction_Pass}, nil
+}
+
+type maxLengthGuardrail struct {
+	maxLength int
+	truncate  bool
+}
+
+func newMaxLengthGuardrail(cfg *GuardrailConfig) (IGuardrail, error) {
+	if cfg.MaxLength <= 0 {
+		return nil, fmt.Errorf("%w: guardrail %s max_length must be positive", ErrConfiguration, cfg.Name)
+	}
+	return &maxLengthGuardrail{
+		maxLength: cfg.MaxLength,
+		truncate:  cfg.Truncate,
+	}, nil
+}
+
+func (g *maxLengthGuardrail) Check(ctx context.Context, content string, opts *RunOptions) (*GuardrailResult, error) {
+	runes := []rune(content)
+	if len(runes) <= g.maxLength {
+		return &GuardrailResult{Action: GuardrailAction_Pass}, nil
+	}
+
+	if g.truncate {
+		return &GuardrailResult{Action: GuardrailAction_Rewrite, Content: string(runes[:g.maxLength])}, nil
+	}
+	return &GuardrailResult{
+		Action:  GuardrailAction_Block,
+		Message: fmt.Sprintf("内容长度%d超出限制%d", len(runes), g.maxLength),
+	}, nil
+}
+
+type jsonSchemaGuardrail struct {
+	schema jsonschema.Definition
+}
+
+func newJSONSchemaGuardrail(cfg *GuardrailConfig) (IGuardrail, error) {
+	if cfg.Schema == nil {
+		return nil, fmt.Errorf("%w: guardrail %s schema is empty", ErrConfiguration, cfg.Name)
+	}
+	return &jsonSchemaGuardrail{schema: *cfg.Schema}, nil
+}
+
+func (g *jsonSchemaGuardrail) Check(ctx context.Context, content string, opts *RunOptions) (*GuardrailResult, error) {
+	var data any
+	if err := json.Unmarshal([]byte(TrimJSONCodeBlock(content)), &data); err != nil {
+		return &GuardrailResult{
+			Action:  GuardrailAction_Block,
+			Message: "内容不是合法JSON => " + err.Error(),
+		}, nil
+	}
+
+	if errs := jsonschema.ValidateWithErrors(g.schema, data); len(errs) > 0 {
+		reasons := make([]string, 0)
+		for _, e := range errs {
+			reasons = append(reasons, e.Error())
+		}
+		return &GuardrailResult{
+			Action:  GuardrailAction_Block,
+			Message: "内容不满足JSON Schema => " + strings.Join(reasons, "; "),
+		}, nil
+	}
+	return &GuardrailResult{Action: GuardrailAction_Pass}, nil
+}
+
+const llmJudgeGuardrailPrompt = `你是一名严格的内容审核员，请根据以下审核标准判断用户提供的内容是否合规。
+
+## 审核标准：
+%s
+
+## 输出格式：
+只输出标准JSON（去除制表、换行符），包含如下属性：
+- pass: 是否合规，true或false
+- reason: 不合规时的原因描述
+
+## 输出示例：
+{"pass":false,"reason":"内容包含敏感信息"}`
+
+type llmJudgeGuardrail struct {
+	llm      string
+	criteria string
+}
+
+func newLLMJudgeGuardrail(cfg *GuardrailConfig) (IGuardrail, error) {
+	if cfg.LLM == "" || cfg.Criteria == "" {
+		return nil, fmt.Errorf("%w: guardrail %s llm and criteria required", ErrConfiguration, cfg.Name)
+	}
+	return &llmJudgeGuardrail{
+		llm:      cfg.LLM,
+		criteria: cfg.Criteria,
+	}, nil
+}
+
+func (g *llmJudgeGuardrail) Check(ctx context.Context, content string, opts *RunOptions) (*GuardrailResult, error) {
+	LLMIns := GetLLMHub().GetLLM(g.llm)
+	if LLMIns == nil {
+		return nil, ErrConfiguration
+	}
+
+	rsp, err := createChatCompletion(ctx, LLMIns, &CreateChatCompletionReq{
+		Messages: []*Message{
+			{Role: MessageRoleSystem, Content: fmt.Sprintf(llmJudgeGuardrailPrompt, g.criteria)},
+			{Role: MessageRoleUser, Content: content},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if rsp.Error != nil {
+		return nil, fmt.Errorf("%s", rsp.Error.Message)
+	}
+	if len(rsp.Choices) == 0 || rsp.Choices[0].Message == nil {
+		return nil, ErrGuardrailJudgeInvalid
+	}
+
+	verdict := struct {
+		Pass   bool   `json:"pass"`
+		Reason string `json:"reason"`
+	}{}
+	if err = json.Unmarshal([]byte(TrimJSONCodeBlock(rsp.Choices[0].Message.Content)), &verdict); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrGuardrailJudgeInvalid, rsp.Choices[0].Message.Content)
+	}
+
+	if verdict.Pass {
+		return &GuardrailResult{Action: GuardrailAction_Pass}, nil
+	}
+	return &GuardrailResult{Action: GuardrailAction_Block, Message: verdict.Reason}, nil
+}
@@ -0,0 +1,123 @@
+/*
+@Project: aihub
+@Module: aihub
+@File : guardrail_test.go
+*/
+package aihub
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func Test_agent_Guardrails(t *testing.T) {
+	newTestLLM(t, "test-guardrail-llm", func(req *CreateChatCompletionReq) *ChatCompletionRspChoice {
+		return &ChatCompletionRspChoice{
+			Message:      &Message{Role: MessageRoleAssistant, Content: "我的手机号是13800138000，请联系我"},
+			FinishReason: ChatCompletionRspFinishReasonStop,
+		}
+	})
+
+	replace := "***"
+	ag, err := GetAgentHub().SetAgent(&AgentConfig{
+		BriefInfo:       BriefInfo{Name: "test-guardrail"},
+		AgentRuntimeCfg: AgentRuntimeCfg{LLM: "test-guardrail-llm"},
+		Guardrails: []*GuardrailConfig{
+			{Type: "regex", Name: "deny", Stage: GuardrailStage_Input, Patterns: []string{"(?i)drop\\s+table"}, Message: "禁止的请求"},
+			{Type: "regex", Name: "mask", Stage: GuardrailStage_Output, Patterns: []string{"1\\d{10}"}, Replace: &replace},
+			{Type: "max_length", Stage: GuardrailStage_Input, MaxLength: 10},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 输入拦截
+	rsp := ag.Run(context.Background(), "drop table users")
+	gErr := &GuardrailError{}
+	if !errors.As(rsp.Err, &gErr) || gErr.Name != "deny" || gErr.Message != "禁止的请求" {
+		t.Fatalf("want deny guardrail error, got %v", rsp.Err)
+	}
+	if !errors.Is(rsp.Err, ErrGuardrailTripped) || rsp.Guardrail == nil {
+		t.Fatalf("want ErrGuardrailTripped in response, got %v", rsp.Err)
+	}
+
+	// 输入超长拦截
+	rsp = ag.Run(context.Background(), "这是一个超过了十个字符长度的问题")
+	if !errors.As(rsp.Err, &gErr) || gErr.Name != "max_length" {
+		t.Fatalf("want max_length guardrail error, got %v", rsp.Err)
+	}
+
+	// 输出改写
+	rsp = ag.Run(context.Background(), "联系方式")
+	if rsp.Err != nil {
+		t.Fatal(rsp.Err)
+	}
+	if rsp.Message.Content != "我的手机号是***，请联系我" {
+		t.Fatalf("want masked content, got %s", rsp.Message.Content)
+	}
+
+	// 输出拦截的内容不写入记忆
+	blocker, err := GetAgentHub().SetAgent(&AgentConfig{
+		BriefInfo:       BriefInfo{Name: "test-guardrail-output"},
+		AgentRuntimeCfg: AgentRuntimeCfg{LLM: "test-guardrail-llm"},
+		Guardrails:      []*GuardrailConfig{{Type: "regex", Name: "deny-phone", Stage: GuardrailStage_Output, Patterns: []string{"1\\d{10}"}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsp = blocker.Run(context.Background(), "联系方式", WithSessionID("test-guardrail-output"))
+	if !errors.As(rsp.Err, &gErr) || gErr.Name != "deny-phone" {
+		t.Fatalf("want deny-phone guardrail error, got %v", rsp.Err)
+	}
+	for _, msg := range blocker.(*agent).memory.GetLatest(newTestMemoryOptions("test-guardrail-output", 10)) {
+		if msg.Role == MessageRoleAssistant {
+			t.Fatalf("blocked output pushed to memory => %s", msg.Content)
+		}
+	}
+
+	// 配置错误在注册时返回
+	_, err = GetAgentHub().SetAgent(&AgentConfig{
+		BriefInfo:       BriefInfo{Name: "test-guardrail-invalid"},
+		AgentRuntimeCfg: AgentRuntimeCfg{LLM: "test-guardrail-llm"},
+		Guardrails:      []*GuardrailConfig{{Type: "unknown"}},
+	})
+	if !errors.Is(err, ErrConfiguration) {
+		t.Fatalf("want ErrConfiguration, got %v", err)
+	}
+}
+
+func Test_llmJudgeGuardrail_Budget(t *testing.T) {
+	newTestLLM(t, "test-judge-llm", func(req *CreateChatCompletionReq) *ChatCompletionRspChoice {
+		content := "ok"
+		if msg := req.Messages[0]; msg != nil && strings.Contains(msg.Content, "不得包含广告") {
+			content = `{"pass":true}`
+		}
+		return &ChatCompletionRspChoice{
+			Message:      &Message{Role: MessageRoleAssistant, Content: content},
+			FinishReason: ChatCompletionRspFinishReasonStop,
+		}
+	})
+
+	ag, err := GetAgentHub().SetAgent(&AgentConfig{
+		BriefInfo:       BriefInfo{Name: "test-judge-guardrail"},
+		AgentRuntimeCfg: AgentRuntimeCfg{LLM: "test-judge-llm"},
+		Guardrails:      []*GuardrailConfig{{Type: "llm_judge", Stage: GuardrailStage_Input, LLM: "test-judge-llm", Criteria: "不得包含广告"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rsp := ag.Run(context.Background(), "你好")
+	if rsp.Err != nil || rsp.Message.Content != "ok" {
+		t.Fatalf("judge guardrail failed => %v", rsp.Err)
+	}
+
+	// 审核调用计入调用树预算
+	rsp = ag.Run(context.Background(), "你好", WithRuntimeCfg(AgentRuntimeCfg{LLM: "test-judge-llm", MaxTreeSteps: 1}))
+	if !errors.Is(rsp.Err, ErrCallBudgetExceeded) {
+		t.Fatalf("want ErrCallBudgetExceeded, got %v", rsp.Err)
+	}
+}
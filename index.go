@@ -1,6 +1,8 @@
 package aihub
 
 import (
+	"context"
+	"errors"
 	"sync"
 )
 
@@ -11,8 +13,11 @@ var defaultAgentHubOnce sync.Once
 func GetAgentHub() IAgentHub {
 	defaultAgentHubOnce.Do(func() {
 		defaultAgentHub = &agentHub{
-			agents: make(map[string]IAgent),
-			mcpSrv: newMCPServer("agent"),
+			agents:      make(map[string]IAgent),
+			templates:   make(map[string]map[string]interface{}),
+			fragments:   make(map[string]string),
+			mcpSrv:      newMCPServer("agent"),
+			runRegistry: newRunRegistry(),
 		}
 	})
 	return defaultAgentHub
@@ -41,6 +46,7 @@ func GetMCPHub() IMCPHub {
 		defaultMCPHub = &mcpHub{
 			clientMaps: make(map[string]*mcpClient),
 			fnMaps:     make(map[string]*mcpClient),
+			policies:   make(map[string]*mcpToolPolicy),
 		}
 	})
 	return defaultMCPHub
@@ -72,3 +78,19 @@ func GetMiddlewareHub() IMiddlewareHub {
 	})
 	return defaultMiddlewareHub
 }
+
+// ================Shutdown================
+
+// Shutdown 全局关闭：取消运行中的请求并等待其退出（至多等到ctx结束），关闭所有agent（停止后台任务、关闭持久化存储）、MCP客户端及MCPServer
+func Shutdown(ctx context.Context) error {
+	errs := []error{
+		GetAgentHub().(*agentHub).shutdown(ctx),
+		GetMCPHub().Close(),
+	}
+	for _, srv := range []IMCPServer{GetAgentHub().GetMCPServer(), GetLLMHub().GetMCPServer(), GetToolHub().GetMCPServer()} {
+		if srv != nil {
+			errs = append(errs, srv.Shutdown(ctx))
+		}
+	}
+	return errors.Join(errs...)
+}
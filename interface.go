@@ -6,6 +6,7 @@ import (
 	"github.com/mark3labs/mcp-go/server"
 	"github.com/mvptianyu/aihub/ssestream"
 	"net/http"
+	"time"
 )
 
 type IBriefInfo interface {
@@ -22,6 +23,18 @@ type ILLM interface {
 	CreateChatCompletionStream(ctx context.Context, request *CreateChatCompletionReq) (stream *ssestream.StreamReader[CreateChatCompletionRsp])
 }
 
+// IEmbedder 文本向量化能力
+type IEmbedder interface {
+	// Embed 批量将文本转换为向量，返回顺序与输入一致
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// IKnowledgeBase 知识库检索能力
+type IKnowledgeBase interface {
+	// Search 检索与query最相关的前topK条内容，按相关度降序返回
+	Search(ctx context.Context, query string, topK int) ([]*KnowledgeHit, error)
+}
+
 // IAgent 智能体
 type IAgent interface {
 	IBriefInfo
@@ -30,8 +43,12 @@ type IAgent interface {
 	Run(ctx context.Context, input string, opts ...RunOptionFunc) *Response
 	// RunStream 执行Agent请求，支持流式返回
 	RunStream(ctx context.Context, input string, opts ...RunOptionFunc) (stream *ssestream.StreamReader[Response])
-	// ResetMemory 重置会话记忆
+	// ResetMemory 重置会话记忆，仅清理WithSessionID指定的会话
 	ResetMemory(ctx context.Context, opts ...RunOptionFunc) error
+	// GetMemory 获取会话记忆，无会话记忆时返回nil
+	GetMemory() IMemory
+	// Close 释放资源：停止会话记忆的后台任务、关闭持久化存储
+	Close() error
 	// GetToolFunctions 获取工具配置
 	GetToolFunctions() []ToolFunction
 	// InvokeToolCall 调度指定工具命令
@@ -44,8 +61,18 @@ type IMemory interface {
 	Push(opts *RunOptions, msg ...*Message)
 	// GetLatest 获取最近会话消息记录
 	GetLatest(opts *RunOptions) []*Message
-	// Clear 清理指定消息记录
+	// Clear 清理指定会话的消息记录，会话不存在时不做处理
 	Clear(opts *RunOptions)
+	// ClearAll 清理所有会话的消息记录
+	ClearAll()
+	// ListSessions 列出所有未过期的会话ID
+	ListSessions() []string
+	// GetHistory 获取会话的全部消息记录，消息中包含创建时间及会话ID
+	GetHistory(sessionID string) []*Message
+	// DeleteMessage 删除会话中指定下标（GetHistory返回列表）的消息，工具调用消息与其结果整组删除
+	DeleteMessage(sessionID string, index int) error
+	// Close 停止后台任务并释放存储资源
+	Close() error
 }
 
 // ISession 会话session数据
@@ -60,6 +87,16 @@ type ISession interface {
 	GetSessionID() string
 }
 
+// ISessionStore 会话数据存储
+type ISessionStore interface {
+	// Load 读取会话数据，不存在或已过期时返回nil
+	Load(sessionID string) (map[string]interface{}, error)
+	// Save 保存会话数据，ttl>0时到期后失效
+	Save(sessionID string, data map[string]interface{}, ttl time.Duration) error
+	// Delete 删除会话数据
+	Delete(sessionID string) error
+}
+
 // IMiddleware 调用拦截器
 type IMiddleware interface {
 	// BeforeProcessing 前处理
@@ -68,6 +105,12 @@ type IMiddleware interface {
 	AfterProcessing(ctx context.Context, req *Message, rsp []*Message, opts *RunOptions) error
 }
 
+// IGuardrail 输入输出护栏
+type IGuardrail interface {
+	// Check 检查内容，返回通过、拦截或改写结果
+	Check(ctx context.Context, content string, opts *RunOptions) (*GuardrailResult, error)
+}
+
 // IMCPServer MCP服务定义
 type IMCPServer interface {
 	Start(listenAddr string) error
@@ -79,6 +122,18 @@ type IMCPServer interface {
 	ServeHTTP(w http.ResponseWriter, r *http.Request)
 }
 
+// IRunRegistry 运行中的Agent请求登记
+type IRunRegistry interface {
+	// GetAllRunIDList 获取所有运行中的请求ID
+	GetAllRunIDList() []string
+	// GetRunInfo 获取指定请求的状态快照
+	GetRunInfo(runID string) *RunInfo
+	// GetRunInfoList 获取所有运行中请求的状态快照
+	GetRunInfoList() []*RunInfo
+	// Cancel 取消指定请求
+	Cancel(runID string) error
+}
+
 // ========HUB定义============
 
 type IMiddlewareHub interface {
@@ -106,7 +161,10 @@ type IMCPHub interface {
 	SetClient(addrs ...string) error
 	ProxyCall(ctx context.Context, name string, input string, output *Message) (err error)
 	GetToolFunctions(addrs []string, names []string) []ToolFunction
+	// SetToolPolicy 设置MCP工具的调用策略：超时、并发、重试
+	SetToolPolicy(name string, policy ToolPolicy)
 	ConvertToOPENAPIConfig() string
+	Close() error
 }
 
 type ILLMHub interface {
@@ -128,5 +186,19 @@ type IAgentHub interface {
 	SetAgent(cfg *AgentConfig) (IAgent, error)
 	SetAgentByYamlData(yamlData []byte) (IAgent, error)
 	SetAgentByYamlFile(yamlFile string) (IAgent, error)
+	SetAgentTemplate(cfg *AgentConfig) error
+	SetAgentTemplateByYamlData(yamlData []byte) error
+	SetAgentTemplateByYamlFile(yamlFile string) error
+	DelAgentTemplate(name string) error
+	SetPromptFragment(name string, content string)
+	SetGroupChat(cfg *GroupChatConfig) (IAgent, error)
+	SetGroupChatByYamlData(yamlData []byte) (IAgent, error)
+	SetGroupChatByYamlFile(yamlFile string) (IAgent, error)
+	SetWorkflow(cfg *WorkflowConfig) (IAgent, error)
+	SetWorkflowByYamlData(yamlData []byte) (IAgent, error)
+	SetWorkflowByYamlFile(yamlFile string) (IAgent, error)
+	SetManus(cfg *AgentConfig) (IAgent, error)
 	GetMCPServer() IMCPServer
+	GetRunRegistry() IRunRegistry
+	Close() error
 }
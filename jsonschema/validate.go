@@ -3,6 +3,8 @@ package jsonschema
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
+	"strings"
 )
 
 func VerifySchemaAndUnmarshal(schema Definition, content []byte, v any) error {
@@ -91,3 +93,75 @@ func contains[S ~[]E, E comparable](s S, v E) bool {
 	}
 	return false
 }
+
+// ValidationError 字段级校验错误
+type ValidationError struct {
+	Field  string `json:"field"`  // 字段路径，例如 user.tags[0]
+	Reason string `json:"reason"` // 错误原因
+}
+
+func (e ValidationError) Error() string {
+	if e.Field == "" {
+		return e.Reason
+	}
+	return e.Field + ": " + e.Reason
+}
+
+// ValidateWithErrors 校验数据并返回所有字段级错误，无错误时返回空
+func ValidateWithErrors(schema Definition, data any) []ValidationError {
+	errs := make([]ValidationError, 0)
+	validateWithErrors(schema, data, "", &errs)
+	return errs
+}
+
+func validateWithErrors(schema Definition, data any, path string, errs *[]ValidationError) {
+	switch schema.Type {
+	case Object:
+		dataMap, ok := data.(map[string]any)
+		if !ok {
+			*errs = append(*errs, ValidationError{Field: path, Reason: "expected object"})
+			return
+		}
+		for _, field := range schema.Required {
+			if _, exists := dataMap[field]; !exists {
+				*errs = append(*errs, ValidationError{Field: joinPath(path, field), Reason: "required field is missing"})
+			}
+		}
+		for key, valueSchema := range schema.Properties {
+			if value, exists := dataMap[key]; exists {
+				validateWithErrors(valueSchema, value, joinPath(path, key), errs)
+			}
+		}
+	case Array:
+		dataArray, ok := data.([]any)
+		if !ok {
+			*errs = append(*errs, ValidationError{Field: path, Reason: "expected array"})
+			return
+		}
+		if schema.Items == nil {
+			return
+		}
+		for idx, item := range dataArray {
+			validateWithErrors(*schema.Items, item, fmt.Sprintf("%s[%d]", path, idx), errs)
+		}
+	case "":
+		// 未声明类型不校验
+	default:
+		if !Validate(schema, data) {
+			*errs = append(*errs, ValidationError{Field: path, Reason: fmt.Sprintf("expected %s", schema.Type)})
+			return
+		}
+		if len(schema.Enum) > 0 {
+			if str, ok := data.(string); ok && !contains(schema.Enum, str) {
+				*errs = append(*errs, ValidationError{Field: path, Reason: fmt.Sprintf("must be one of [%s]", strings.Join(schema.Enum, ","))})
+			}
+		}
+	}
+}
+
+func joinPath(path string, field string) string {
+	if path == "" {
+		return field
+	}
+	return path + "." + field
+}
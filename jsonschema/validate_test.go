@@ -162,3 +162,44 @@ func TestUnmarshal(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateWithErrors(t *testing.T) {
+	schema := jsonschema.Definition{
+		Type: jsonschema.Object,
+		Properties: map[string]jsonschema.Definition{
+			"city":  {Type: jsonschema.String, Enum: []string{"深圳", "北京"}},
+			"days":  {Type: jsonschema.Integer},
+			"tags":  {Type: jsonschema.Array, Items: &jsonschema.Definition{Type: jsonschema.String}},
+			"extra": {Type: jsonschema.Object, Properties: map[string]jsonschema.Definition{"id": {Type: jsonschema.Integer}}, Required: []string{"id"}},
+		},
+		Required: []string{"city", "days"},
+	}
+	tests := []struct {
+		name       string
+		data       any
+		wantFields []string
+	}{
+		{"valid", map[string]any{"city": "深圳", "days": float64(3)}, []string{}},
+		{"missing required", map[string]any{"city": "深圳"}, []string{"days"}},
+		{"enum and type", map[string]any{"city": "上海", "days": 1.5}, []string{"city", "days"}},
+		{"nested", map[string]any{"city": "北京", "days": float64(1), "tags": []any{"a", 1}, "extra": map[string]any{}}, []string{"tags[1]", "extra.id"}},
+		{"not object", "abc", []string{""}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := jsonschema.ValidateWithErrors(schema, tt.data)
+			if len(errs) != len(tt.wantFields) {
+				t.Fatalf("ValidateWithErrors() = %v, want fields %v", errs, tt.wantFields)
+			}
+			got := make(map[string]bool)
+			for _, e := range errs {
+				got[e.Field] = true
+			}
+			for _, field := range tt.wantFields {
+				if !got[field] {
+					t.Errorf("ValidateWithErrors() = %v, missing field %s", errs, field)
+				}
+			}
+		})
+	}
+}
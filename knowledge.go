@@ -0,0 +1,200 @@
+/*
+@Project: aihub
+@Module: aihub
+@File : knowledge.go
+*/
+package aihub
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/mvptianyu/aihub/jsonschema"
+)
+
+const (
+	KnowledgeMode_Inject = "inject" // 每次运行按用户输入检索，注入系统提示词
+	KnowledgeMode_Tool   = "tool"   // 作为检索工具提供给模型按需调用
+)
+
+const knowledgeToolName = "knowledge_search"
+
+const knowledgeInjectTpl = `
+
+## 参考资料
+以下是与当前问题相关的知识库内容，回答时请优先依据参考资料，并以[编号]标注引用来源：
+%s`
+
+// KnowledgeHit 知识库检索结果，同时作为回答的引用来源
+type KnowledgeHit struct {
+	SourceID string                 `json:"source_id"`       // 来源文档ID
+	ChunkID  string                 `json:"chunk_id"`        // 分块ID
+	Title    string                 `json:"title,omitempty"` // 来源文档标题
+	Content  string                 `json:"content"`         // 分块内容
+	Score    float64                `json:"score"`           // 相关度
+	Meta     map[string]interface{} `json:"meta,omitempty"`  // 来源文档元数据
+	Index    int                    `json:"index,omitempty"` // 本次运行内的引用编号，从1开始
+	Base     string                 `json:"base,omitempty"`  // 所属知识库名称
+}
+
+// KnowledgeConfig agent关联知识库配置
+type KnowledgeConfig struct {
+	Bases           []string `json:"bases" yaml:"bases"`                                           // 知识库名称，RegisterKnowledgeBase注册
+	Mode            string   `json:"mode,omitempty" yaml:"mode,omitempty"`                         // 使用方式：inject(默认)|tool
+	TopK            int      `json:"top_k,omitempty" yaml:"top_k,omitempty"`                       // 每次检索返回的条数，默认3
+	MinScore        float64  `json:"min_score,omitempty" yaml:"min_score,omitempty"`               // 可选，最低相关度，低于该值的结果丢弃
+	ToolName        string   `json:"tool_name,omitempty" yaml:"tool_name,omitempty"`               // tool：工具名，默认knowledge_search
+	ToolDescription string   `json:"tool_description,omitempty" yaml:"tool_description,omitempty"` // tool：工具描述
+}
+
+func (cfg *KnowledgeConfig) AutoFix() error {
+	if len(cfg.Bases) == 0 {
+		return fmt.Errorf("%w: knowledge bases empty", ErrConfiguration)
+	}
+	if cfg.Mode != KnowledgeMode_Tool {
+		cfg.Mode = KnowledgeMode_Inject
+	}
+	if cfg.TopK <= 0 || cfg.TopK > 20 {
+		cfg.TopK = 3
+	}
+	if cfg.ToolName == "" {
+		cfg.ToolName = knowledgeToolName
+	}
+	if cfg.ToolDescription == "" {
+		cfg.ToolDescription = "检索知识库，返回与查询相关的资料片段及引用编号，回答时以[编号]标注引用来源"
+	}
+	return nil
+}
+
+var (
+	knowledgeBases     = make(map[string]IKnowledgeBase)
+	knowledgeBasesLock sync.RWMutex
+)
+
+// RegisterKnowledgeBase 注册知识库，供agent配置按名称引用，同名覆盖
+func RegisterKnowledgeBase(name string, kb IKnowledgeBase) {
+	knowledgeBasesLock.Lock()
+	defer knowledgeBasesLock.Unlock()
+	knowledgeBases[name] = kb
+}
+
+// GetKnowledgeBase 按名称获取知识库，不存在时返回nil
+func GetKnowledgeBase(name string) IKnowledgeBase {
+	knowledgeBasesLock.RLock()
+	defer knowledgeBasesLock.RUnlock()
+	return knowledgeBases[name]
+}
+
+// searchKnowledge 检索配置的所有知识库，按相关度合并取前TopK条
+func searchKnowledge(ctx context.Context, cfg *KnowledgeConfig, query string) ([]*KnowledgeHit, error) {
+	ret := make([]*KnowledgeHit, 0)
+	for _, name := range cfg.Bases {
+		kb := GetKnowledgeBase(name)
+		if kb == nil {
+			return nil, fmt.Errorf("%w: knowledge base %s not found", ErrConfiguration, name)
+		}
+		hits, err := kb.Search(ctx, query, cfg.TopK)
+		if err != nil {
+			return nil, err
+		}
+		for _, hit := range hits {
+			if hit.Score < cfg.MinScore {
+				continue
+			}
+			hit.Base = name
+			ret = append(ret, hit)
+		}
+	}
+
+	sort.SliceStable(ret, func(i, j int) bool {
+		return ret[i].Score > ret[j].Score
+	})
+	if len(ret) > cfg.TopK {
+		ret = ret[:cfg.TopK]
+	}
+	return ret, nil
+}
+
+// newKnowledgeToolFunction 知识库检索工具定义
+func newKnowledgeToolFunction(cfg *KnowledgeConfig) ToolFunction {
+	return ToolFunction{
+		BriefInfo: BriefInfo{
+			Name:        cfg.ToolName,
+			Description: cfg.ToolDescription,
+		},
+		Parameters: &jsonschema.Definition{
+			Type: jsonschema.Object,
+			Properties: map[string]jsonschema.Definition{
+				"query": {
+					Type:        jsonschema.String,
+					Description: "检索内容，使用完整的问题或关键词",
+				},
+			},
+			Required: []string{"query"},
+		},
+	}
+}
+
+// retrieveKnowledge inject模式下按用户输入检索知识库，记录为本次运行的引用来源
+func (a *agent) retrieveKnowledge(ctx context.Context, opts *RunOptions, input string) {
+	if a.cfg.Knowledge == nil || a.cfg.Knowledge.Mode != KnowledgeMode_Inject {
+		return
+	}
+
+	hits, err := searchKnowledge(ctx, a.cfg.Knowledge, input)
+	if err != nil {
+		log.Printf("agent::retrieveKnowledge failed => runID:%s, err:%v\n", opts.RunID, err)
+		return
+	}
+	opts.setKnowledge(opts.addCitations(hits))
+}
+
+// invokeKnowledgeTool tool模式下处理模型发起的知识库检索
+func (a *agent) invokeKnowledgeTool(ctx context.Context, args string, output *Message) error {
+	params := struct {
+		Query string `json:"query"`
+	}{}
+	if err := json.Unmarshal([]byte(args), &params); err != nil {
+		return err
+	}
+
+	hits, err := searchKnowledge(ctx, a.cfg.Knowledge, params.Query)
+	if err != nil {
+		return err
+	}
+	if opts := runOptionsFromContext(ctx); opts != nil {
+		hits = opts.addCitations(hits)
+	}
+
+	output.Content = formatKnowledgeHits(hits)
+	if output.Content == "" {
+		output.Content = "知识库中未检索到相关内容"
+	}
+	return nil
+}
+
+// formatKnowledgeHits 将检索结果格式化为带引用编号的文本
+func formatKnowledgeHits(hits []*KnowledgeHit) string {
+	builder := strings.Builder{}
+	for _, hit := range hits {
+		source := hit.SourceID
+		if hit.Title != "" {
+			source += " " + hit.Title
+		}
+		builder.WriteString(fmt.Sprintf("[%d] 来源：%s\n%s\n\n", hit.Index, source, hit.Content))
+	}
+	return strings.TrimRight(builder.String(), "\n")
+}
+
+// formatKnowledge 将注入的检索结果格式化为系统提示词片段
+func formatKnowledge(hits []*KnowledgeHit) string {
+	if len(hits) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(knowledgeInjectTpl, formatKnowledgeHits(hits))
+}
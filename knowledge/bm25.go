@@ -0,0 +1,138 @@
+/*
+@Project: aihub
+@Module: knowledge
+@File : bm25.go
+*/
+package knowledge
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// bm25Index BM25关键词倒排索引，非并发安全，由KnowledgeBase加锁
+type bm25Index struct {
+	postings map[string]map[string]int // term => chunkID => 词频
+	terms    map[string][]string       // chunkID => 去重后的term，用于删除
+	lens     map[string]int            // chunkID => 文本term数
+	totalLen int
+}
+
+func newBM25Index() *bm25Index {
+	return &bm25Index{
+		postings: make(map[string]map[string]int),
+		terms:    make(map[string][]string),
+		lens:     make(map[string]int),
+	}
+}
+
+func (idx *bm25Index) add(id string, text string) {
+	idx.remove(id)
+
+	tokens := tokenize(text)
+	tf := make(map[string]int)
+	for _, token := range tokens {
+		tf[token]++
+	}
+	terms := make([]string, 0, len(tf))
+	for term, cnt := range tf {
+		if idx.postings[term] == nil {
+			idx.postings[term] = make(map[string]int)
+		}
+		idx.postings[term][id] = cnt
+		terms = append(terms, term)
+	}
+	idx.terms[id] = terms
+	idx.lens[id] = len(tokens)
+	idx.totalLen += len(tokens)
+}
+
+func (idx *bm25Index) remove(id string) {
+	terms, ok := idx.terms[id]
+	if !ok {
+		return
+	}
+	for _, term := range terms {
+		delete(idx.postings[term], id)
+		if len(idx.postings[term]) == 0 {
+			delete(idx.postings, term)
+		}
+	}
+	idx.totalLen -= idx.lens[id]
+	delete(idx.terms, id)
+	delete(idx.lens, id)
+}
+
+// search 计算包含查询词的分块得分，chunkID => BM25分
+func (idx *bm25Index) search(query string) map[string]float64 {
+	ret := make(map[string]float64)
+	n := float64(len(idx.lens))
+	if n == 0 {
+		return ret
+	}
+	avgLen := float64(idx.totalLen) / n
+
+	seen := make(map[string]bool)
+	for _, term := range tokenize(query) {
+		if seen[term] {
+			continue
+		}
+		seen[term] = true
+
+		postings := idx.postings[term]
+		df := float64(len(postings))
+		if df == 0 {
+			continue
+		}
+		idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+		for id, cnt := range postings {
+			tf := float64(cnt)
+			norm := bm25K1 * (1 - bm25B + bm25B*float64(idx.lens[id])/avgLen)
+			ret[id] += idf * tf * (bm25K1 + 1) / (tf + norm)
+		}
+	}
+	return ret
+}
+
+// tokenize 分词：英文数字按单词切分并转小写，中日韩文字按单字及相邻二元组切分，忽略标点
+func tokenize(text string) []string {
+	ret := make([]string, 0)
+	word := strings.Builder{}
+	var prevHan rune
+	flushWord := func() {
+		if word.Len() > 0 {
+			ret = append(ret, word.String())
+			word.Reset()
+		}
+	}
+
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case isCJK(r):
+			flushWord()
+			ret = append(ret, string(r))
+			if prevHan != 0 {
+				ret = append(ret, string([]rune{prevHan, r}))
+			}
+			prevHan = r
+			continue
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			word.WriteRune(r)
+		default:
+			flushWord()
+		}
+		prevHan = 0
+	}
+	flushWord()
+	return ret
+}
+
+func isCJK(r rune) bool {
+	return unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) || unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r)
+}
@@ -0,0 +1,121 @@
+/*
+@Project: aihub
+@Module: knowledge
+@File : chunk.go
+*/
+package knowledge
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// ChunkConfig 分块配置，长度均按字符数计
+type ChunkConfig struct {
+	Size       int      `json:"size,omitempty" yaml:"size,omitempty"`             // 每块最大字符数，默认500
+	Overlap    int      `json:"overlap,omitempty" yaml:"overlap,omitempty"`       // 相邻块重叠字符数，默认Size的1/10，负数表示不重叠
+	Separators []string `json:"separators,omitempty" yaml:"separators,omitempty"` // 切分符，按优先级依次尝试，默认段落、换行、句子、空格
+}
+
+func (cfg *ChunkConfig) AutoFix() {
+	if cfg.Size <= 0 {
+		cfg.Size = 500
+	}
+	if cfg.Overlap == 0 || cfg.Overlap >= cfg.Size {
+		cfg.Overlap = cfg.Size / 10
+	}
+	if len(cfg.Separators) == 0 {
+		cfg.Separators = []string{"\n\n", "\n", "。", "！", "？", "；", ". ", "! ", "? ", " "}
+	}
+}
+
+// Chunk 文档分块，检索的最小单元
+type Chunk struct {
+	ID      string                 `json:"id"`     // 文档ID#序号
+	DocID   string                 `json:"doc_id"` // 所属文档ID
+	Title   string                 `json:"title,omitempty"`
+	Content string                 `json:"content"`
+	Index   int                    `json:"index"` // 文档内序号
+	Meta    map[string]interface{} `json:"meta,omitempty"`
+}
+
+// SplitDocument 按切分符优先级递归切分文档，再合并为不超过Size的分块，相邻分块保留Overlap重叠
+func SplitDocument(doc *Document, cfg ChunkConfig) []*Chunk {
+	cfg.AutoFix()
+
+	pieces := splitText(doc.Content, cfg.Separators, cfg.Size)
+	ret := make([]*Chunk, 0)
+	appendChunk := func(content string) {
+		content = strings.TrimSpace(content)
+		if content == "" {
+			return
+		}
+		ret = append(ret, &Chunk{
+			ID:      fmt.Sprintf("%s#%d", doc.ID, len(ret)),
+			DocID:   doc.ID,
+			Title:   doc.Title,
+			Content: content,
+			Index:   len(ret),
+			Meta:    doc.Meta,
+		})
+	}
+
+	cur := ""
+	for _, piece := range pieces {
+		if cur != "" && utf8.RuneCountInString(cur)+utf8.RuneCountInString(piece) > cfg.Size {
+			appendChunk(cur)
+			overlap := tailRunes(cur, cfg.Overlap)
+			if utf8.RuneCountInString(overlap)+utf8.RuneCountInString(piece) > cfg.Size {
+				overlap = ""
+			}
+			cur = overlap
+		}
+		cur += piece
+	}
+	appendChunk(cur)
+	return ret
+}
+
+// splitText 按切分符切分文本，超长片段使用下一级切分符，无切分符可用时按长度硬切
+func splitText(text string, separators []string, size int) []string {
+	if utf8.RuneCountInString(text) <= size {
+		return []string{text}
+	}
+
+	for i, sep := range separators {
+		if !strings.Contains(text, sep) {
+			continue
+		}
+		ret := make([]string, 0)
+		for _, part := range strings.SplitAfter(text, sep) {
+			if part == "" {
+				continue
+			}
+			ret = append(ret, splitText(part, separators[i+1:], size)...)
+		}
+		return ret
+	}
+
+	runes := []rune(text)
+	ret := make([]string, 0, len(runes)/size+1)
+	for start := 0; start < len(runes); start += size {
+		end := start + size
+		if end > len(runes) {
+			end = len(runes)
+		}
+		ret = append(ret, string(runes[start:end]))
+	}
+	return ret
+}
+
+func tailRunes(text string, n int) string {
+	if n <= 0 {
+		return ""
+	}
+	runes := []rune(text)
+	if len(runes) <= n {
+		return text
+	}
+	return string(runes[len(runes)-n:])
+}
@@ -0,0 +1,268 @@
+/*
+@Project: aihub
+@Module: knowledge
+@File : document.go
+*/
+package knowledge
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+const (
+	Format_Text     = "text"
+	Format_Markdown = "markdown"
+	Format_HTML     = "html"
+	Format_JSON     = "json"
+)
+
+// Document 知识库文档，ID作为引用来源标识
+type Document struct {
+	ID      string                 `json:"id"`
+	Title   string                 `json:"title,omitempty"`
+	Content string                 `json:"content"`
+	Meta    map[string]interface{} `json:"meta,omitempty"`
+}
+
+// formatByExt 按文件扩展名识别文档格式
+func formatByExt(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".md", ".markdown":
+		return Format_Markdown
+	case ".html", ".htm":
+		return Format_HTML
+	case ".json":
+		return Format_JSON
+	case ".txt", ".text":
+		return Format_Text
+	}
+	return ""
+}
+
+// Load 按格式读取文档，JSON数组时每个元素为一个文档
+func Load(format string, id string, r io.Reader) ([]*Document, error) {
+	switch format {
+	case Format_JSON:
+		return LoadJSON(id, r)
+	case Format_Markdown, Format_HTML, Format_Text:
+		loader := LoadText
+		if format == Format_Markdown {
+			loader = LoadMarkdown
+		} else if format == Format_HTML {
+			loader = LoadHTML
+		}
+		doc, err := loader(id, r)
+		if err != nil {
+			return nil, err
+		}
+		return []*Document{doc}, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrFormatUnsupported, format)
+}
+
+// LoadFile 读取本地文件，按扩展名识别格式，文档ID为文件路径
+func LoadFile(path string) ([]*Document, error) {
+	format := formatByExt(path)
+	if format == "" {
+		return nil, fmt.Errorf("%w: %s", ErrFormatUnsupported, path)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	docs, err := Load(format, filepath.ToSlash(path), f)
+	if err != nil {
+		return nil, err
+	}
+	for _, doc := range docs {
+		if doc.Meta == nil {
+			doc.Meta = make(map[string]interface{})
+		}
+		doc.Meta["path"] = path
+	}
+	return docs, nil
+}
+
+// LoadDir 递归读取目录下支持格式的文件，跳过隐藏文件
+func LoadDir(dir string) ([]*Document, error) {
+	ret := make([]*Document, 0)
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if strings.HasPrefix(d.Name(), ".") && path != dir {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() || formatByExt(path) == "" {
+			return nil
+		}
+
+		docs, err := LoadFile(path)
+		if err != nil {
+			return err
+		}
+		ret = append(ret, docs...)
+		return nil
+	})
+	return ret, err
+}
+
+// LoadText 读取纯文本，标题取首个非空行
+func LoadText(id string, r io.Reader) (*Document, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	content := strings.TrimSpace(strings.ReplaceAll(string(data), "\r\n", "\n"))
+	title, _, _ := strings.Cut(content, "\n")
+	return &Document{ID: id, Title: strings.TrimSpace(title), Content: content}, nil
+}
+
+// LoadMarkdown 读取Markdown，标题取首个标题行，正文保留原格式
+func LoadMarkdown(id string, r io.Reader) (*Document, error) {
+	doc, err := LoadText(id, r)
+	if err != nil {
+		return nil, err
+	}
+
+	doc.Title = ""
+	for _, line := range strings.Split(doc.Content, "\n") {
+		if strings.HasPrefix(line, "#") {
+			doc.Title = strings.TrimSpace(strings.TrimLeft(line, "#"))
+			break
+		}
+	}
+	return doc, nil
+}
+
+var (
+	htmlIgnoreReg = regexp.MustCompile(`(?is)<(script|style|noscript|head)\b.*?</(script|style|noscript|head)>|<!--.*?-->`)
+	htmlTitleReg  = regexp.MustCompile(`(?is)<title\b[^>]*>(.*?)</title>|<h1\b[^>]*>(.*?)</h1>`)
+	htmlBlockReg  = regexp.MustCompile(`(?i)<(br|/p|/div|/li|/tr|/h[1-6]|/section|/article|/table|/ul|/ol)\b[^>]*>`)
+	htmlTagReg    = regexp.MustCompile(`(?s)<[^>]*>`)
+	spacesReg     = regexp.MustCompile(`[ \t\f\v\x{00a0}]+`)
+	blankLinesReg = regexp.MustCompile(`\n\s*\n+`)
+)
+
+// LoadHTML 读取HTML，去除脚本样式及标签提取正文，标题取title或首个h1
+func LoadHTML(id string, r io.Reader) (*Document, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	raw := string(data)
+	title := ""
+	if match := htmlTitleReg.FindStringSubmatch(raw); match != nil {
+		title = match[1] + match[2]
+		title = strings.TrimSpace(html.UnescapeString(htmlTagReg.ReplaceAllString(title, "")))
+	}
+
+	text := htmlIgnoreReg.ReplaceAllString(raw, "")
+	text = htmlBlockReg.ReplaceAllString(text, "\n")
+	text = htmlTagReg.ReplaceAllString(text, "")
+	text = html.UnescapeString(text)
+	text = spacesReg.ReplaceAllString(text, " ")
+	lines := strings.Split(text, "\n")
+	for i := range lines {
+		lines[i] = strings.TrimSpace(lines[i])
+	}
+	text = blankLinesReg.ReplaceAllString(strings.Join(lines, "\n"), "\n\n")
+
+	return &Document{ID: id, Title: title, Content: strings.TrimSpace(text)}, nil
+}
+
+// LoadJSON 读取JSON，数组时每个元素为一个文档；
+// fields为正文字段的gjson路径，为空时展开所有字段为"路径: 值"的文本；元素的id、title字段作为文档ID和标题
+func LoadJSON(id string, r io.Reader, fields ...string) ([]*Document, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if !gjson.ValidBytes(data) {
+		return nil, fmt.Errorf("%w: invalid json %s", ErrDocumentInvalid, id)
+	}
+
+	items := []gjson.Result{gjson.ParseBytes(data)}
+	if items[0].IsArray() {
+		items = items[0].Array()
+	}
+
+	ret := make([]*Document, 0, len(items))
+	for i, item := range items {
+		doc := &Document{ID: id}
+		if len(items) > 1 || item.Get("id").Exists() {
+			doc.ID = fmt.Sprintf("%s#%d", id, i)
+			if tmp := item.Get("id"); tmp.Exists() {
+				doc.ID = fmt.Sprintf("%s#%s", id, tmp.String())
+			}
+		}
+		doc.Title = item.Get("title").String()
+
+		lines := make([]string, 0)
+		if len(fields) > 0 {
+			for _, field := range fields {
+				if tmp := item.Get(field); tmp.Exists() {
+					lines = append(lines, tmp.String())
+				}
+			}
+		} else {
+			var value interface{}
+			json.Unmarshal([]byte(item.Raw), &value)
+			flattenJSON("", value, &lines)
+		}
+		doc.Content = strings.TrimSpace(strings.Join(lines, "\n"))
+		if doc.Content == "" {
+			continue
+		}
+		ret = append(ret, doc)
+	}
+	return ret, nil
+}
+
+// flattenJSON 按key排序展开JSON叶子节点
+func flattenJSON(prefix string, value interface{}, lines *[]string) {
+	switch tmp := value.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(tmp))
+		for key := range tmp {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			path := key
+			if prefix != "" {
+				path = prefix + "." + key
+			}
+			flattenJSON(path, tmp[key], lines)
+		}
+	case []interface{}:
+		for i, item := range tmp {
+			flattenJSON(fmt.Sprintf("%s.%d", prefix, i), item, lines)
+		}
+	case nil:
+	default:
+		if prefix == "" {
+			*lines = append(*lines, fmt.Sprint(tmp))
+		} else {
+			*lines = append(*lines, fmt.Sprintf("%s: %v", prefix, tmp))
+		}
+	}
+}
@@ -0,0 +1,15 @@
+/*
+@Project: aihub
+@Module: knowledge
+@File : error.go
+*/
+package knowledge
+
+import "errors"
+
+var (
+	ErrDocumentInvalid   = errors.New("knowledge document invalid")
+	ErrFormatUnsupported = errors.New("knowledge document format unsupported")
+	ErrEmbedderNotFound  = errors.New("knowledge embedder not found")
+	ErrEmbeddingInvalid  = errors.New("knowledge embedding response invalid")
+)
@@ -0,0 +1,226 @@
+/*
+@Project: aihub
+@Module: knowledge
+@File : knowledge.go
+*/
+package knowledge
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/mvptianyu/aihub"
+)
+
+// Config 知识库配置
+type Config struct {
+	Chunk        ChunkConfig `json:"chunk,omitempty" yaml:"chunk,omitempty"`                 // 分块配置
+	Embedder     string      `json:"embedder,omitempty" yaml:"embedder,omitempty"`           // 可选，向量化实现名称，aihub.RegisterEmbedder注册的名称或支持embeddings接口的LLM名称，为空时仅使用BM25
+	VectorWeight float64     `json:"vector_weight,omitempty" yaml:"vector_weight,omitempty"` // 混合排序中向量相似度的权重(0,1]，配置Embedder时默认0.5，1表示仅使用向量检索
+}
+
+func (cfg *Config) AutoFix() error {
+	cfg.Chunk.AutoFix()
+	if cfg.Embedder == "" {
+		cfg.VectorWeight = 0
+		return nil
+	}
+	if cfg.VectorWeight <= 0 || cfg.VectorWeight > 1 {
+		cfg.VectorWeight = 0.5
+	}
+	return nil
+}
+
+// KnowledgeBase 进程内知识库：文档分块后建立BM25索引，配置Embedder时同时建立向量索引，检索结果按权重混合排序
+type KnowledgeBase struct {
+	cfg     *Config
+	chunks  map[string]*Chunk   // chunkID => chunk
+	docs    map[string][]string // docID => chunkIDs
+	bm25    *bm25Index
+	vectors *aihub.VectorIndex
+	lock    sync.RWMutex
+}
+
+// New 创建知识库，可通过aihub.RegisterKnowledgeBase注册后在agent配置中引用
+func New(cfg *Config) (*KnowledgeBase, error) {
+	if cfg == nil {
+		cfg = &Config{}
+	}
+	if err := cfg.AutoFix(); err != nil {
+		return nil, err
+	}
+
+	kb := &KnowledgeBase{
+		cfg:    cfg,
+		chunks: make(map[string]*Chunk),
+		docs:   make(map[string][]string),
+		bm25:   newBM25Index(),
+	}
+	if cfg.Embedder != "" {
+		kb.vectors, _ = aihub.OpenVectorIndex("")
+	}
+	return kb, nil
+}
+
+// Len 分块数
+func (kb *KnowledgeBase) Len() int {
+	kb.lock.RLock()
+	defer kb.lock.RUnlock()
+	return len(kb.chunks)
+}
+
+// Add 分块并索引文档，同ID文档覆盖
+func (kb *KnowledgeBase) Add(ctx context.Context, docs ...*Document) error {
+	chunks := make([]*Chunk, 0)
+	for _, doc := range docs {
+		if doc == nil || doc.ID == "" {
+			return fmt.Errorf("%w: document id empty", ErrDocumentInvalid)
+		}
+		chunks = append(chunks, SplitDocument(doc, kb.cfg.Chunk)...)
+	}
+
+	// 向量化放在锁外，避免阻塞检索
+	var vectors [][]float32
+	if kb.vectors != nil && len(chunks) > 0 {
+		texts := make([]string, 0, len(chunks))
+		for _, chunk := range chunks {
+			texts = append(texts, chunk.Title+"\n"+chunk.Content)
+		}
+		var err error
+		if vectors, err = kb.embed(ctx, texts); err != nil {
+			return err
+		}
+	}
+
+	kb.lock.Lock()
+	defer kb.lock.Unlock()
+
+	for _, doc := range docs {
+		if err := kb.deleteDoc(doc.ID); err != nil {
+			return err
+		}
+		kb.docs[doc.ID] = make([]string, 0)
+	}
+	items := make([]*aihub.VectorItem, 0, len(vectors))
+	for i, chunk := range chunks {
+		kb.chunks[chunk.ID] = chunk
+		kb.docs[chunk.DocID] = append(kb.docs[chunk.DocID], chunk.ID)
+		kb.bm25.add(chunk.ID, chunk.Title+"\n"+chunk.Content)
+		if vectors != nil {
+			items = append(items, &aihub.VectorItem{ID: chunk.ID, Scope: chunk.DocID, Text: chunk.Content, Vector: vectors[i]})
+		}
+	}
+	if len(items) > 0 {
+		return kb.vectors.Add(items...)
+	}
+	return nil
+}
+
+// Delete 删除文档及其分块
+func (kb *KnowledgeBase) Delete(docIDs ...string) error {
+	kb.lock.Lock()
+	defer kb.lock.Unlock()
+
+	for _, docID := range docIDs {
+		if err := kb.deleteDoc(docID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (kb *KnowledgeBase) deleteDoc(docID string) error {
+	chunkIDs, ok := kb.docs[docID]
+	if !ok {
+		return nil
+	}
+	for _, chunkID := range chunkIDs {
+		kb.bm25.remove(chunkID)
+		delete(kb.chunks, chunkID)
+	}
+	delete(kb.docs, docID)
+
+	if kb.vectors != nil {
+		if _, err := kb.vectors.Delete(func(item *aihub.VectorItem) bool { return item.Scope == docID }); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Search 混合检索：BM25得分按s/(s+1)归一化，与向量余弦相似度按VectorWeight加权求和
+func (kb *KnowledgeBase) Search(ctx context.Context, query string, topK int) ([]*aihub.KnowledgeHit, error) {
+	if topK <= 0 {
+		topK = 3
+	}
+
+	var queryVector []float32
+	if kb.vectors != nil {
+		vectors, err := kb.embed(ctx, []string{query})
+		if err != nil {
+			return nil, err
+		}
+		queryVector = vectors[0]
+	}
+
+	kb.lock.RLock()
+	defer kb.lock.RUnlock()
+
+	scores := make(map[string]float64)
+	if kb.cfg.VectorWeight < 1 {
+		for id, score := range kb.bm25.search(query) {
+			scores[id] += (1 - kb.cfg.VectorWeight) * score / (score + 1)
+		}
+	}
+	if queryVector != nil {
+		// 仅召回一定数量的向量候选，避免所有分块都带上微小的相似度
+		for _, hit := range kb.vectors.Search(queryVector, topK*4, nil) {
+			if hit.Score > 0 {
+				scores[hit.ID] += kb.cfg.VectorWeight * hit.Score
+			}
+		}
+	}
+
+	ret := make([]*aihub.KnowledgeHit, 0, len(scores))
+	for id, score := range scores {
+		chunk, ok := kb.chunks[id]
+		if !ok {
+			continue
+		}
+		ret = append(ret, &aihub.KnowledgeHit{
+			SourceID: chunk.DocID,
+			ChunkID:  chunk.ID,
+			Title:    chunk.Title,
+			Content:  chunk.Content,
+			Score:    score,
+			Meta:     chunk.Meta,
+		})
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].Score != ret[j].Score {
+			return ret[i].Score > ret[j].Score
+		}
+		return ret[i].ChunkID < ret[j].ChunkID
+	})
+	if len(ret) > topK {
+		ret = ret[:topK]
+	}
+	return ret, nil
+}
+
+func (kb *KnowledgeBase) embed(ctx context.Context, texts []string) ([][]float32, error) {
+	embedder := aihub.GetEmbedder(kb.cfg.Embedder)
+	if embedder == nil {
+		return nil, fmt.Errorf("%w: %s", ErrEmbedderNotFound, kb.cfg.Embedder)
+	}
+	vectors, err := embedder.Embed(ctx, texts)
+	if err != nil {
+		return nil, err
+	}
+	if len(vectors) != len(texts) {
+		return nil, fmt.Errorf("%w: embeddings count %d, want %d", ErrEmbeddingInvalid, len(vectors), len(texts))
+	}
+	return vectors, nil
+}
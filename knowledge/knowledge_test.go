@@ -0,0 +1,148 @@
+/*
+@Project: aihub
+@Module: knowledge
+@File : knowledge_test.go
+*/
+package knowledge
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mvptianyu/aihub"
+)
+
+// charEmbedder 按字符计数的测试向量化实现
+type charEmbedder struct{}
+
+func (e *charEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	ret := make([][]float32, 0, len(texts))
+	for _, text := range texts {
+		vector := make([]float32, 64)
+		for _, r := range text {
+			vector[int(r)%64]++
+		}
+		ret = append(ret, vector)
+	}
+	return ret, nil
+}
+
+func TestLoadDir(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"guide.md":    "# 退货指南\n\n商品签收后7天内可申请无理由退货。",
+		"about.html":  "<html><head><title>关于我们</title><style>p{}</style></head><body><p>成立于2010年</p><script>x()</script><p>总部&amp;研发中心位于杭州</p></body></html>",
+		"faq.json":    `[{"id":"q1","title":"运费","answer":"满99元包邮"},{"id":"q2","title":"发票","answer":"支持电子发票"}]`,
+		"notes.txt":   "营业时间\n每天9点到21点",
+		"ignore.csv":  "a,b",
+		".hidden.txt": "hidden",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	docs, err := LoadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]*Document)
+	for _, doc := range docs {
+		got[filepath.Base(doc.ID)] = doc
+	}
+	if len(got) != 5 {
+		t.Fatalf("want 5 documents, got %d => %v", len(got), got)
+	}
+	if doc := got["guide.md"]; doc.Title != "退货指南" {
+		t.Fatalf("unexpected markdown title => %s", doc.Title)
+	}
+	if doc := got["about.html"]; doc.Title != "关于我们" || doc.Content != "成立于2010年\n总部&研发中心位于杭州" {
+		t.Fatalf("unexpected html document => %q %q", doc.Title, doc.Content)
+	}
+	if doc := got["faq.json#q1"]; doc.Title != "运费" || !strings.Contains(doc.Content, "answer: 满99元包邮") {
+		t.Fatalf("unexpected json document => %v", doc)
+	}
+	if doc := got["notes.txt"]; doc.Title != "营业时间" {
+		t.Fatalf("unexpected text title => %s", doc.Title)
+	}
+}
+
+func TestSplitDocument(t *testing.T) {
+	doc := &Document{ID: "doc", Content: strings.Repeat("这是一个句子。", 30)}
+	chunks := SplitDocument(doc, ChunkConfig{Size: 50, Overlap: 7})
+	if len(chunks) < 5 {
+		t.Fatalf("want at least 5 chunks, got %d", len(chunks))
+	}
+	for i, chunk := range chunks {
+		if n := len([]rune(chunk.Content)); n > 50 {
+			t.Fatalf("chunk %d too long => %d", i, n)
+		}
+		if i > 0 && !strings.HasPrefix(chunk.Content, "这是一个句子。") {
+			t.Fatalf("chunk %d should start with overlap => %s", i, chunk.Content)
+		}
+	}
+	if chunks[1].ID != "doc#1" || chunks[1].DocID != "doc" {
+		t.Fatalf("unexpected chunk id => %s", chunks[1].ID)
+	}
+}
+
+func TestKnowledgeBase_Search(t *testing.T) {
+	ctx := context.Background()
+	docs := []*Document{
+		{ID: "refund", Title: "退货政策", Content: "商品签收后7天内可申请无理由退货，退款原路返回。"},
+		{ID: "shipping", Title: "配送说明", Content: "订单满99元包邮，偏远地区除外。"},
+		{ID: "invoice", Title: "发票", Content: "支持开具电子发票，可在订单详情页申请。"},
+	}
+
+	kb, err := New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = kb.Add(ctx, docs...); err != nil {
+		t.Fatal(err)
+	}
+	hits, err := kb.Search(ctx, "怎么退货", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hits) == 0 || hits[0].SourceID != "refund" || hits[0].ChunkID != "refund#0" {
+		t.Fatalf("unexpected bm25 hits => %v", hits)
+	}
+
+	// 覆盖与删除
+	kb.Add(ctx, &Document{ID: "refund", Content: "不支持退货"})
+	if hits, _ = kb.Search(ctx, "退货", 3); len(hits) != 1 || hits[0].Content != "不支持退货" {
+		t.Fatalf("want replaced document, got %v", hits)
+	}
+	kb.Delete("refund")
+	if hits, _ = kb.Search(ctx, "退货", 3); len(hits) != 0 || kb.Len() != 2 {
+		t.Fatalf("want deleted document, got %v", hits)
+	}
+
+	// 混合检索
+	aihub.RegisterEmbedder("test-knowledge-embedder", &charEmbedder{})
+	hybrid, err := New(&Config{Embedder: "test-knowledge-embedder"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = hybrid.Add(ctx, docs...); err != nil {
+		t.Fatal(err)
+	}
+	hits, err = hybrid.Search(ctx, "电子发票", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hits) != 1 || hits[0].SourceID != "invoice" || hits[0].Score <= 0 || hits[0].Score > 1 {
+		t.Fatalf("unexpected hybrid hits => %v", hits)
+	}
+
+	missing, _ := New(&Config{Embedder: "missing"})
+	if err = missing.Add(ctx, docs[0]); !errors.Is(err, ErrEmbedderNotFound) {
+		t.Fatalf("want ErrEmbedderNotFound, got %v", err)
+	}
+}
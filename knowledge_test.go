@@ -0,0 +1,107 @@
+/*
+@Project: aihub
+@Module: aihub
+@File : knowledge_test.go
+*/
+package aihub
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// fakeKnowledgeBase 按关键词匹配的测试知识库
+type fakeKnowledgeBase struct {
+	docs map[string]string
+}
+
+func (kb *fakeKnowledgeBase) Search(ctx context.Context, query string, topK int) ([]*KnowledgeHit, error) {
+	ret := make([]*KnowledgeHit, 0)
+	for id, content := range kb.docs {
+		if strings.Contains(query, id) {
+			ret = append(ret, &KnowledgeHit{SourceID: id, ChunkID: id + "#0", Content: content, Score: 1})
+		}
+	}
+	return ret, nil
+}
+
+func Test_agent_KnowledgeInject(t *testing.T) {
+	RegisterKnowledgeBase("test-kb", &fakeKnowledgeBase{docs: map[string]string{"退货": "7天内可无理由退货"}})
+
+	system := ""
+	newTestLLM(t, "test-knowledge-inject-llm", func(req *CreateChatCompletionReq) *ChatCompletionRspChoice {
+		system = req.Messages[0].Content
+		return &ChatCompletionRspChoice{
+			Message:      &Message{Role: MessageRoleAssistant, Content: "可以退货[1]"},
+			FinishReason: ChatCompletionRspFinishReasonStop,
+		}
+	})
+
+	ag, err := GetAgentHub().SetAgent(&AgentConfig{
+		BriefInfo:       BriefInfo{Name: "test-knowledge-inject"},
+		AgentRuntimeCfg: AgentRuntimeCfg{LLM: "test-knowledge-inject-llm"},
+		Knowledge:       &KnowledgeConfig{Bases: []string{"test-kb"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rsp := ag.Run(context.Background(), "怎么退货")
+	if rsp.Err != nil {
+		t.Fatal(rsp.Err)
+	}
+	if !strings.Contains(system, "[1] 来源：退货\n7天内可无理由退货") {
+		t.Fatalf("unexpected system prompt => %s", system)
+	}
+	if len(rsp.Citations) != 1 || rsp.Citations[0].Index != 1 || rsp.Citations[0].Base != "test-kb" {
+		t.Fatalf("unexpected citations => %v", rsp.Citations)
+	}
+}
+
+func Test_agent_KnowledgeTool(t *testing.T) {
+	RegisterKnowledgeBase("test-kb-tool", &fakeKnowledgeBase{docs: map[string]string{"发票": "支持电子发票"}})
+
+	var toolReq *CreateChatCompletionReq
+	newTestLLM(t, "test-knowledge-tool-llm", func(req *CreateChatCompletionReq) *ChatCompletionRspChoice {
+		last := req.Messages[len(req.Messages)-1]
+		if last.Role == MessageRoleTool {
+			return &ChatCompletionRspChoice{
+				Message:      &Message{Role: MessageRoleAssistant, Content: last.Content},
+				FinishReason: ChatCompletionRspFinishReasonStop,
+			}
+		}
+
+		toolReq = req
+		toolCall := &MessageToolCall{Id: "call_1", Type: ToolTypeFunction}
+		toolCall.Function.Name = "search_docs"
+		toolCall.Function.Arguments = `{"query":"发票怎么开"}`
+		return &ChatCompletionRspChoice{
+			Message:      &Message{Role: MessageRoleAssistant, ToolCalls: []*MessageToolCall{toolCall}},
+			FinishReason: ChatCompletionRspFinishReasonToolCalls,
+		}
+	})
+
+	ag, err := GetAgentHub().SetAgent(&AgentConfig{
+		BriefInfo:       BriefInfo{Name: "test-knowledge-tool"},
+		AgentRuntimeCfg: AgentRuntimeCfg{LLM: "test-knowledge-tool-llm"},
+		Knowledge:       &KnowledgeConfig{Bases: []string{"test-kb-tool"}, Mode: KnowledgeMode_Tool, ToolName: "search_docs"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rsp := ag.Run(context.Background(), "发票")
+	if rsp.Err != nil {
+		t.Fatal(rsp.Err)
+	}
+	if len(toolReq.Tools) != 1 || toolReq.Tools[0].Function.Name != "search_docs" {
+		t.Fatalf("want knowledge tool, got %v", toolReq.Tools)
+	}
+	if !strings.Contains(rsp.Content, "[1] 来源：发票") {
+		t.Fatalf("unexpected content => %s", rsp.Content)
+	}
+	if len(rsp.Citations) != 1 || rsp.Citations[0].SourceID != "发票" {
+		t.Fatalf("unexpected citations => %v", rsp.Citations)
+	}
+}
@@ -0,0 +1,207 @@
+/*
+@Project: aihub
+@Module: aihub
+@File : lifecycle_test.go
+*/
+package aihub
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func isMemoryClosed(mem IMemory) bool {
+	select {
+	case <-mem.(*memory).done:
+		return true
+	default:
+		return false
+	}
+}
+
+func Test_agentHub_CloseAgents(t *testing.T) {
+	cfg := func() *AgentConfig {
+		return &AgentConfig{
+			BriefInfo:       BriefInfo{Name: "test-lifecycle"},
+			AgentRuntimeCfg: AgentRuntimeCfg{LLM: "test-lifecycle-llm"},
+		}
+	}
+
+	old, err := GetAgentHub().SetAgent(cfg())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cur, err := GetAgentHub().SetAgent(cfg())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !isMemoryClosed(old.GetMemory()) || isMemoryClosed(cur.GetMemory()) {
+		t.Fatal("want replaced agent closed")
+	}
+
+	if err = GetAgentHub().DelAgent("test-lifecycle"); err != nil {
+		t.Fatal(err)
+	}
+	if !isMemoryClosed(cur.GetMemory()) {
+		t.Fatal("want deleted agent closed")
+	}
+	// 重复关闭
+	if err = cur.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	hub := &agentHub{agents: make(map[string]IAgent), runRegistry: newRunRegistry()}
+	ag, err := newAgent(cfg())
+	if err != nil {
+		t.Fatal(err)
+	}
+	hub.setAgentIns(ag)
+	if err = hub.Close(); err != nil || !isMemoryClosed(ag.GetMemory()) || len(hub.GetAllNameList()) != 0 {
+		t.Fatalf("hub close failed => %v", err)
+	}
+}
+
+func Test_agentHub_ReplaceAgentAfterDrain(t *testing.T) {
+	release := make(chan struct{})
+	newTestLLM(t, "test-drain-llm", func(req *CreateChatCompletionReq) *ChatCompletionRspChoice {
+		<-release
+		return &ChatCompletionRspChoice{
+			Message:      &Message{Role: MessageRoleAssistant, Content: "done"},
+			FinishReason: ChatCompletionRspFinishReasonStop,
+		}
+	})
+	cfg := &AgentConfig{
+		BriefInfo:       BriefInfo{Name: "test-drain"},
+		AgentRuntimeCfg: AgentRuntimeCfg{LLM: "test-drain-llm"},
+	}
+
+	old, err := GetAgentHub().SetAgent(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doneCh := make(chan *Response, 1)
+	go func() {
+		doneCh <- old.Run(context.Background(), "hello", WithRunID("test-drain-run"))
+	}()
+	for GetAgentHub().GetRunRegistry().GetRunInfo("test-drain-run") == nil {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// 运行中的请求结束前不关闭旧实例
+	if _, err = GetAgentHub().SetAgent(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if isMemoryClosed(old.GetMemory()) {
+		t.Fatal("replaced agent closed before runs drained")
+	}
+
+	close(release)
+	if rsp := <-doneCh; rsp.Err != nil {
+		t.Fatal(rsp.Err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for !isMemoryClosed(old.GetMemory()) && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !isMemoryClosed(old.GetMemory()) {
+		t.Fatal("want replaced agent closed after runs drained")
+	}
+}
+
+func Test_boltMemoryStore_Close(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "memory.db")
+	store1, err := newBoltMemoryStore(path, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	store2, err := newBoltMemoryStore(path, "b")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 共用数据库，最后一个引用释放时才关闭
+	store1.Close()
+	store1.Close()
+	if err = store2.Save("s1", []*Message{{Role: MessageRoleUser, Content: "hi"}}, 0); err != nil {
+		t.Fatal(err)
+	}
+	store2.Close()
+	if _, ok := boltDBs[path]; ok {
+		t.Fatal("want db closed")
+	}
+
+	// 关闭后可重新打开
+	store3, err := newBoltMemoryStore(path, "b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store3.Close()
+	if msgs, err := store3.Load("s1"); err != nil || len(msgs) != 1 {
+		t.Fatalf("unexpected load => %v, %v", msgs, err)
+	}
+}
+
+func Test_agentHub_CloseAfterDrain(t *testing.T) {
+	release := make(chan struct{})
+	newTestLLM(t, "test-close-drain-llm", func(req *CreateChatCompletionReq) *ChatCompletionRspChoice {
+		<-release
+		return &ChatCompletionRspChoice{
+			Message:      &Message{Role: MessageRoleAssistant, Content: "done"},
+			FinishReason: ChatCompletionRspFinishReasonStop,
+		}
+	})
+	cfg := &AgentConfig{
+		BriefInfo:       BriefInfo{Name: "test-close-drain"},
+		AgentRuntimeCfg: AgentRuntimeCfg{LLM: "test-close-drain-llm"},
+	}
+
+	// 删除时运行中的请求结束前不关闭
+	ag, err := GetAgentHub().SetAgent(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go ag.Run(context.Background(), "hello", WithRunID("test-del-drain-run"))
+	for GetAgentHub().GetRunRegistry().GetRunInfo("test-del-drain-run") == nil {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err = GetAgentHub().DelAgent("test-close-drain"); err != nil {
+		t.Fatal(err)
+	}
+	if isMemoryClosed(ag.GetMemory()) {
+		t.Fatal("deleted agent closed before runs drained")
+	}
+	release <- struct{}{}
+	deadline := time.Now().Add(time.Second)
+	for !isMemoryClosed(ag.GetMemory()) && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !isMemoryClosed(ag.GetMemory()) {
+		t.Fatal("want deleted agent closed after runs drained")
+	}
+
+	// 关闭时等待已取消的请求退出
+	hub := &agentHub{agents: make(map[string]IAgent), runRegistry: GetAgentHub().GetRunRegistry().(*runRegistry)}
+	if ag, err = newAgent(cfg); err != nil {
+		t.Fatal(err)
+	}
+	hub.setAgentIns(ag)
+	go ag.Run(context.Background(), "hello", WithRunID("test-close-drain-run"))
+	for GetAgentHub().GetRunRegistry().GetRunInfo("test-close-drain-run") == nil {
+		time.Sleep(10 * time.Millisecond)
+	}
+	closed := make(chan error, 1)
+	go func() {
+		closed <- hub.Close()
+	}()
+	select {
+	case <-closed:
+		t.Fatal("hub closed before canceled runs drained")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	if err = <-closed; err != nil || !isMemoryClosed(ag.GetMemory()) {
+		t.Fatalf("hub close failed => %v", err)
+	}
+}
@@ -3,6 +3,7 @@ package aihub
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/mvptianyu/aihub/ssestream"
 	"golang.org/x/time/rate"
@@ -11,7 +12,10 @@ import (
 	"net/url"
 )
 
-const chatCompletionsAPI = "/chat/completions"
+const (
+	chatCompletionsAPI = "/chat/completions"
+	embeddingsAPI      = "/embeddings"
+)
 
 // LLM提供商
 type llm struct {
@@ -109,3 +113,55 @@ func (p *llm) CreateChatCompletionStream(ctx context.Context, request *CreateCha
 
 	return ssestream.NewStreamReader[CreateChatCompletionRsp](ssestream.NewDecoder(rsp.Body), err)
 }
+
+// Embed 调用兼容OpenAI的embeddings接口，模型名称为LLM配置名称
+func (p *llm) Embed(ctx context.Context, texts []string) (ret [][]float32, err error) {
+	if err = p.checkRateLimit(); err != nil {
+		return
+	}
+
+	surl, _ := url.JoinPath(p.cfg.BaseURL, p.cfg.Version, embeddingsAPI)
+	headers := &http.Header{
+		"Content-Type": {"application/json"},
+	}
+	if p.cfg.APIKey != "" {
+		headers.Set("Authorization", fmt.Sprintf("Bearer %s", p.cfg.APIKey))
+	}
+
+	req := map[string]interface{}{
+		"model": p.cfg.Name,
+		"input": texts,
+	}
+	rsp, err := HTTPCall(surl, http.MethodPost, req, headers, HTTPWithTimeOut(30))
+	if err != nil {
+		return
+	}
+
+	bs, _ := io.ReadAll(rsp.Body)
+	defer rsp.Body.Close()
+	tmp := &struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+		Error *ChatCompletionRspError `json:"error,omitempty"`
+	}{}
+	if err = json.Unmarshal(bs, tmp); err != nil {
+		return
+	}
+	if tmp.Error != nil {
+		return nil, errors.New(tmp.Error.Message)
+	}
+	if len(tmp.Data) != len(texts) {
+		return nil, fmt.Errorf("%w: embeddings count %d, want %d", ErrEmbeddingInvalid, len(tmp.Data), len(texts))
+	}
+
+	ret = make([][]float32, len(texts))
+	for _, item := range tmp.Data {
+		if item.Index < 0 || item.Index >= len(texts) {
+			return nil, fmt.Errorf("%w: embedding index %d", ErrEmbeddingInvalid, item.Index)
+		}
+		ret[item.Index] = item.Embedding
+	}
+	return ret, nil
+}
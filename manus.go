@@ -7,7 +7,13 @@ package aihub
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"github.com/mvptianyu/aihub/ssestream"
+	"gopkg.in/yaml.v3"
+	"log"
 	"sync"
+	"time"
 )
 
 var defaultManus IAgent
@@ -17,99 +23,472 @@ name: _MANUS_
 description: AIHub Manus，一个全能的人工智能助手，旨在根据用户需求拆解高效、合理的任务步骤，执行并最终完用户需求目标
 llm: gpt-4o
 system_prompt: |
-  你是AIHub Manus，一个全能的人工智能助手，旨在根据用户需求拆解高效、合理的任务步骤，执行并最终完用户需求目标。
+  你是AIHub Manus，一个全能的人工智能助手，旨在根据用户需求拆解高效、合理的任务计划，调度执行并最终完成用户需求目标。
   你拥有这些子agent能力，帮助你完成目标：
   {{agents}}
   
   ## 工作职责：
-  1. 接收和理解用户请求，
-  2. 若用户问的是能做什么、你有什么能力时，可做自我介绍后，再根据子agent名称及能力以Markdown条理和美观化形式直接返回并退出
-  3. 将用户需求拆解为清晰可执行的具体任务列表，其中每个任务匹配0到1个agent名称的调用
-  4. 按任务列表一步接一步的调度执行，判定是否需要选用agentTool工具请求或可以直接得到该步骤执行结果
-  5. 若中途就足以满足用户请求时可提前返回退出
-  6. 确保最终输出结果符合用户预期
+  1. 接收和理解用户请求
+  2. 若用户问的是能做什么、你有什么能力时，可做自我介绍后，再根据子agent名称及能力以Markdown条理和美观化形式在answer中直接回复
+  3. 将用户需求拆解为清晰可执行的任务计划，其中每个任务匹配0到1个agent名称的调用
+  4. 任务执行失败时，根据失败原因调整计划，已成功的任务保留原ID
   
   ## 注意事项：
-  1. 任务列表生成时，确保是合理的依赖顺序结构，以及整体调度过程步骤准确
-  2. 知道什么时候应该继续或结束，一旦用户需求目标已达成，就不要继续思考，快速退出和总结回复
+  1. 任务间存在依赖时通过depends_on声明，依赖任务的结果会作为参考信息提供给后续任务
+  2. 相互独立的任务不要声明依赖，它们将被并行执行
+  3. 任务数量尽量精简，不要拆解与用户需求无关的任务
   
   ## 输出格式：
-  所有响应的文本字段必须是标准、结构化的JSON格式（去除制表、换行符），包含如下属性：
-  - _think_: 本次步骤需要做什么的推理思考描述
-  - _action_: 本次步骤需要调用到的agent名称
-  - _question_: 本次步骤需要调用agent的输入描述提示词
-  - _result_: 本次步骤执行的返回结果描述，以Markdown条理和美观化输出
-  - _state_: 本次任务步骤的状态结果：0-初始化（默认），1-执行中，2-成功退出，3-失败退出，4-提前终止
+  所有响应必须是标准、结构化的JSON格式（去除制表、换行符），包含如下属性：
+  - goal: 用户需求目标概述
+  - tasks: 任务列表，每个任务包含：id-任务ID，title-任务概述，agent-调用的agent名称（无需调用agent时为空），question-调用agent的输入描述提示词，depends_on-依赖的任务ID列表
+  - answer: 无需拆解任务即可直接回复时的回复内容，否则为空
   
   ## 输出示例：
-  1.用户请求可拆解出任务步骤时：
-  {"_think_":"这里是根据用户需求和上下文得出的本次步骤推理思考","_action_":"这里是根据用户需求和上下文得出的本次步骤需要调用的agent名称","_question_":"这里是根据用户需求和上下文得出的本次步骤调用agent的输入描述提示词","_result_":"这里是本次步骤执行的返回结果描述","_state_":1}
+  1.用户请求可拆解出任务计划时：
+  {"goal":"这里是用户需求目标","tasks":[{"id":"t1","title":"这里是任务概述","agent":"这里是调用的agent名称","question":"这里是调用agent的输入描述提示词","depends_on":[]}],"answer":""}
   
-  2.用户请求无匹配可拆解任务步骤时回复：
-  {"_think_":"发现无匹配可用agent能力，无法拆解任务步骤解决用户需求问题","_action_":"","_question_":"","_result_":"发现无匹配可用agent能力，无法拆解任务步骤解决用户需求问题","_state_":4}
+  2.用户请求无匹配可拆解任务时回复：
+  {"goal":"这里是用户需求目标","tasks":[],"answer":"发现无匹配可用agent能力，无法拆解任务步骤解决用户需求问题"}
   
   现在开始，去友好、耐心和专业的响应用户需求任务吧
 run_timeout: 3600
 claim: 本结果由AiHub Manus自动生成
 debug: true
-tools:
-  - AgentCall
 `
 
+const manusSummaryPrompt = `你是AIHub Manus，请根据用户需求和各任务的执行结果，整理出最终回复内容，以Markdown条理和美观化输出，不要输出JSON。`
+
 func GetManus() IAgent {
 	defaultManusOnce.Do(func() {
-		GetToolHub().SetTool(
-			ToolEntry{
-				Function:    AgentCall,
-				Description: "根据对应agent名称和请求信息调用agent能力，获取对应返回结果",
-			},
-		)
-		defaultManus, _ = GetAgentHub().SetAgentByYamlData([]byte(defaultManusYamlConf))
+		cfg := &AgentConfig{}
+		if err := yaml.Unmarshal([]byte(defaultManusYamlConf), cfg); err != nil {
+			log.Printf("Error Manus Unmarshal YAML data => %v\n", err)
+			return
+		}
+		defaultManus, _ = GetAgentHub().SetManus(cfg)
 	})
 	return defaultManus
 }
 
-// =======AgentCall注册==========
-const AgentCallFuncName = "AgentCall"
+// manus 先规划后执行的调度agent：生成任务计划 => 按依赖执行任务 => 失败时重新规划 => 汇总结果
+type manus struct {
+	*agent
+}
 
-type AgentCallReq struct {
-	ToolInputBase
-	RunStep `yaml:",inline"`
+func newManus(cfg *AgentConfig) (IAgent, error) {
+	ag, err := newAgent(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &manus{agent: ag.(*agent)}, nil
 }
 
-func AgentCall(ctx context.Context, input *AgentCallReq, output *Message) (err error) {
-	ag := GetAgentHub().GetAgent(input.Action)
-	if ag == nil {
-		output.Content = "无可用匹配的Agent能力 => " + input.Action
-		return ErrCallNameNotMatch
+func (m *manus) Run(ctx context.Context, input string, opts ...RunOptionFunc) (ret *Response) {
+	ret = &Response{}
+	options := m.newRunOptions()
+	for _, opt := range opts {
+		opt(options)
 	}
+	if err := m.fixRunOptions(options); err != nil {
+		ret.Err = err
+		return
+	}
+
+	LLMIns := GetLLMHub().GetLLM(options.RuntimeCfg.LLM)
+	if LLMIns == nil {
+		ret.Err = ErrConfiguration
+		return
+	}
+
+	// 检测嵌套调用的循环及深度
+	var err error
+	if ctx, err = enterCallChain(ctx, m.cfg.Name, &options.RuntimeCfg); err != nil {
+		ret.Err = err
+		return
+	}
+
+	ret.RunID = options.RunID
+	ret.Session = options.Session
+	ctx = ContextWithSession(ctx, options.Session) // 绑定重设ctx
+	ctx = contextWithRunOptions(ctx, options)
+	cancelCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	newCtx, cancelTimeout := context.WithTimeoutCause(cancelCtx, time.Duration(options.RuntimeCfg.RunTimeout)*time.Second, ErrAgentRunTimeout)
+	defer cancelTimeout()
+	options.ctx = newCtx
+
+	// 登记运行状态，支持外部查询和取消
+	registry := GetAgentHub().GetRunRegistry().(*runRegistry)
+	if err = registry.register(m, input, options, cancel); err != nil {
+		ret.Err = err
+		return
+	}
+	defer registry.unregister(options.RunID)
+	finish := registry.detach(options.RunID)
+
+	var doneCh = make(chan *Response, 1) // 带缓冲，超时或取消后协程仍可正常退出
+	var endStep = &RunStep{
+		StepType: StepType_End,
+		State:    RunState_Idle,
+	}
+	options.AddStep(&RunStep{
+		Question: input,
+		StepType: StepType_Start,
+		State:    RunState_Succeed,
+	})
+
+	go func() {
+		defer finish() // 超时或取消后协程仍在执行时，关闭agent需等待其退出
+		inner := m.runPlan(newCtx, input, options, LLMIns)
+		m.saveSession(options) // 主循环结束后再保存，超时或取消时不写入执行中的会话快照
+		doneCh <- inner
+	}()
+
+	select {
+	case <-newCtx.Done():
+		ret.Err = context.Cause(newCtx)
+	case inner := <-doneCh:
+		ret.Message = inner.Message
+		ret.Err = inner.Err
+	}
+
+	if plan := options.GetPlan(); plan != nil {
+		ret.Plan = plan.Clone()
+	}
+	if ret.Err != nil {
+		endStep.State = RunState_Failed
+		errors.As(ret.Err, &ret.Guardrail)
+	} else if ret.Message != nil {
+		endStep.Result = ret.Message.Content
+	}
+	options.AddStep(endStep)
+	ret.Content = options.RenderFinalAnswer()
+	ret.Steps = options.GetSteps()
+	ret.Citations = options.GetCitations()
+	return ret
+}
 
-	session := SessionFromContext(ctx)
-	optionFuncs := []RunOptionFunc{
-		WithDebug(false),
+// runPlan 生成计划并按依赖顺序执行任务，任务失败时重新规划
+func (m *manus) runPlan(ctx context.Context, input string, options *RunOptions, LLMIns ILLM) (ret *Response) {
+	ret = &Response{}
+
+	// 输入护栏检查
+	if ret.Err = m.checkGuardrails(ctx, GuardrailStage_Input, &input, options); ret.Err != nil {
+		return
 	}
-	if session != nil {
-		optionFuncs = append(optionFuncs, WithSessionData(session.GetAllSessionData()))
+
+	// 历史对话作为规划上下文，本次输入写入记忆
+	history := m.memory.GetLatest(options)
+	m.memory.Push(options, &Message{
+		Role:    MessageRoleUser,
+		Content: input,
+	})
+
+	// 检索长期记忆，注入规划的系统提示词
+	if m.longTerm != nil {
+		recalls, err := m.longTerm.Recall(ctx, options, input)
+		if err != nil {
+			log.Printf("manus::runPlan recall long term memory failed => runID:%s, err:%v\n", options.RunID, err)
+		}
+		options.setRecalls(recalls)
 	}
+	m.retrieveKnowledge(ctx, options, input)
 
-	// 调用执行
-	rsp := ag.Run(ctx, input.Question,
-		optionFuncs...,
-	)
-	if rsp.Err != nil {
-		output.Content = rsp.Err.Error()
-		err = rsp.Err
+	plan, err := m.makePlan(ctx, LLMIns, options, history, "用户需求："+input)
+	if err != nil {
+		ret.Err = err
 		return
 	}
+	options.setPlan(plan.Clone())
+
+	replans := 0
+	for len(plan.Tasks) > 0 && !plan.IsDone() {
+		// 已超时或被取消跳出
+		if ctx.Err() != nil {
+			ret.Err = context.Cause(ctx)
+			return
+		}
+
+		// 超过最大步数跳出
+		if options.CheckStepQuit() {
+			ret.Err = ErrChatCompletionOverMaxStep
+			return
+		}
 
-	if rsp.Message != nil {
-		output.Content = rsp.Message.Content
+		tasks := plan.NextTasks()
+		if len(tasks) == 0 {
+			ret.Err = fmt.Errorf("%w: no runnable task", ErrPlanInvalid)
+			return
+		}
+
+		failed := m.runTasks(ctx, LLMIns, tasks, plan, options)
+		options.setPlan(plan.Clone())
+		if len(failed) == 0 {
+			continue
+		}
+
+		// 任务失败重新规划
+		reasons := ""
+		for _, task := range failed {
+			reasons += fmt.Sprintf("\n任务%s执行失败：%s", task.ID, task.Result)
+		}
+		if replans++; replans > options.RuntimeCfg.MaxReplan {
+			ret.Err = fmt.Errorf("%w:%s", ErrPlanReplanExceeded, reasons)
+			return
+		}
+		newPlan, err1 := m.makePlan(ctx, LLMIns, options, history, fmt.Sprintf(
+			"用户需求：%s\n\n当前计划及执行状态（state：0-未执行，2-成功，3-失败）：\n%s\n%s\n\n请根据失败原因调整计划，已成功的任务保留原ID，输出完整的计划JSON",
+			input, plan.String(), reasons))
+		if err1 != nil {
+			ret.Err = err1
+			return
+		}
+		newPlan.mergeDone(plan)
+		plan = newPlan
+		options.setPlan(plan.Clone())
+	}
+
+	answer := plan.Answer
+	if len(plan.Tasks) > 0 {
+		if answer, err = m.summarize(ctx, LLMIns, options, input, plan); err != nil {
+			ret.Err = err
+			return
+		}
 	}
 
-	if output.Content == "" {
-		err = ErrToolCallResponseEmpty
-		output.Content = ErrToolCallResponseEmpty.Error()
+	// 反思评审，不通过时附带评审意见重新整理回复
+	if answer, ret.Err = m.reflect(ctx, LLMIns, options, input, plan, answer); ret.Err != nil {
+		return
 	}
 
+	// 输出护栏检查，通过后才写入记忆
+	if ret.Err = m.checkGuardrails(ctx, GuardrailStage_Output, &answer, options); ret.Err != nil {
+		return
+	}
+	ret.Message = &Message{
+		Role:    MessageRoleAssistant,
+		Content: answer,
+	}
+	m.memory.Push(options, ret.Message)
+
+	// 写入长期记忆
+	if m.longTerm != nil {
+		if err = m.longTerm.Remember(ctx, options, input, answer); err != nil {
+			log.Printf("manus::runPlan remember long term memory failed => runID:%s, err:%v\n", options.RunID, err)
+		}
+	}
 	return
 }
+
+// makePlan 请求模型生成计划，history为本会话的历史对话
+func (m *manus) makePlan(ctx context.Context, LLMIns ILLM, options *RunOptions, history []*Message, prompt string) (*Plan, error) {
+	system := ""
+	if msg := m.getSystemMsg(options); msg != nil {
+		system = msg.Content
+	}
+
+	content, err := m.chat(ctx, LLMIns, options, system, history, prompt)
+	if err != nil {
+		return nil, err
+	}
+	plan, err := parsePlan(content)
+	if err != nil {
+		return nil, err
+	}
+
+	result := ""
+	for idx, task := range plan.Tasks {
+		result += fmt.Sprintf("%d. [%s] %s\n", idx+1, task.Agent, task.Title)
+	}
+	if result == "" {
+		result = plan.Answer
+	}
+	options.AddStep(&RunStep{
+		Think:    plan.Goal,
+		Result:   result,
+		State:    RunState_Succeed,
+		StepType: StepType_Plan,
+	})
+	return plan, nil
+}
+
+// runTasks 并行执行无相互依赖的任务，步骤及session变更按计划顺序合并，返回失败的任务
+func (m *manus) runTasks(ctx context.Context, LLMIns ILLM, tasks []*PlanTask, plan *Plan, options *RunOptions) []*PlanTask {
+	steps := make([]*RunStep, len(tasks))
+	sessions := make([]map[string]interface{}, len(tasks))
+	sem := make(chan struct{}, options.RuntimeCfg.MaxAgentConcurrency)
+	wg := sync.WaitGroup{}
+	for idx, task := range tasks {
+		task.State = RunState_Running
+		wg.Add(1)
+		go func(idx int, task *PlanTask) {
+			defer wg.Done()
+			if err := acquireSemaphore(ctx, sem); err != nil {
+				task.State = RunState_Failed
+				task.Result = err.Error()
+				steps[idx] = &RunStep{Action: task.Agent, Think: task.Title, Result: task.Result, State: task.State, StepType: StepType_Agent}
+				return
+			}
+			defer releaseSemaphore(sem)
+			steps[idx], sessions[idx] = m.runTask(ctx, LLMIns, task, plan, options)
+		}(idx, task)
+	}
+	wg.Wait()
+
+	failed := make([]*PlanTask, 0)
+	for idx, task := range tasks {
+		options.AddStep(steps[idx])
+		if sessions[idx] != nil {
+			options.MergeSessionData(sessions[idx])
+		}
+		if task.State == RunState_Failed {
+			failed = append(failed, task)
+		}
+	}
+	return failed
+}
+
+// runTask 以子session执行单个任务，依赖任务的结果作为参考信息传入，返回执行步骤及session变更
+func (m *manus) runTask(ctx context.Context, LLMIns ILLM, task *PlanTask, plan *Plan, options *RunOptions) (*RunStep, map[string]interface{}) {
+	question := task.Question
+	for _, dep := range task.DependsOn {
+		if tmp := plan.GetTask(dep); tmp != nil {
+			question += fmt.Sprintf("\n\n参考信息【%s】：%s", tmp.Title, tmp.Result)
+		}
+	}
+
+	step := &RunStep{
+		Action:   task.Agent,
+		Question: question,
+		Think:    task.Title,
+		StepType: StepType_Agent,
+	}
+
+	var err error
+	var session map[string]interface{}
+	if task.Agent == "" {
+		// 无需调用agent时由Manus自身完成
+		step.StepType = StepType_LLM
+		task.Result, err = m.chat(ctx, LLMIns, options, "", nil, question)
+	} else if !m.checkAgentAllowed(task.Agent, options) {
+		err = fmt.Errorf("%w: %s", ErrCallNameNotMatch, task.Agent)
+	} else {
+		ret := runSubAgent(ctx, task.Agent, question, options.Session)
+		task.Result, err, session = ret.content, ret.err, ret.session
+	}
+
+	task.State = RunState_Succeed
+	if err != nil {
+		task.State = RunState_Failed
+		task.Result = err.Error()
+		session = nil
+	}
+	step.Result = task.Result
+	step.State = task.State
+	return step, session
+}
+
+// summarize 汇总各任务结果生成最终回复
+func (m *manus) summarize(ctx context.Context, LLMIns ILLM, options *RunOptions, input string, plan *Plan) (string, error) {
+	return m.chat(ctx, LLMIns, options, manusSummaryPrompt, nil, summaryContent(input, plan))
+}
+
+// reflect 按反思配置评审最终回复，不通过时附带评审意见重新整理，超出轮数后返回最后一版
+func (m *manus) reflect(ctx context.Context, LLMIns ILLM, options *RunOptions, input string, plan *Plan, answer string) (string, error) {
+	if m.cfg.Reflection == nil {
+		return answer, nil
+	}
+
+	for round := 1; round <= m.cfg.Reflection.MaxRounds; round++ {
+		critique, err := m.critique(ctx, input, answer, options, round)
+		if err != nil {
+			return "", err
+		}
+		if critique.Pass {
+			break
+		}
+
+		content := summaryContent(input, plan) + "\n\n回答草稿：\n" + answer + "\n\n" + fmt.Sprintf(reflectionFeedbackTpl, critique.Feedback)
+		if answer, err = m.chat(ctx, LLMIns, options, manusSummaryPrompt, nil, content); err != nil {
+			return "", err
+		}
+	}
+	return answer, nil
+}
+
+func summaryContent(input string, plan *Plan) string {
+	content := fmt.Sprintf("用户需求：%s", input)
+	if len(plan.Tasks) == 0 {
+		return content
+	}
+
+	content += "\n\n任务执行结果："
+	for _, task := range plan.Tasks {
+		content += fmt.Sprintf("\n\n### %s\n%s", task.Title, task.Result)
+	}
+	return content
+}
+
+// checkAgentAllowed 指定了WithAgents时，仅可调度其中的agent
+func (m *manus) checkAgentAllowed(name string, options *RunOptions) bool {
+	if name == m.cfg.Name {
+		return false
+	}
+	if len(options.Agents) == 0 {
+		return true
+	}
+	for _, item := range options.Agents {
+		if item.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *manus) chat(ctx context.Context, LLMIns ILLM, options *RunOptions, system string, history []*Message, input string) (string, error) {
+	messages := make([]*Message, 0)
+	if system != "" {
+		messages = append(messages, &Message{Role: MessageRoleSystem, Content: system})
+	}
+	messages = append(messages, history...)
+	messages = append(messages, &Message{Role: MessageRoleUser, Content: input})
+
+	rsp, err := createChatCompletion(ctx, LLMIns, &CreateChatCompletionReq{
+		Messages:         messages,
+		MaxTokens:        options.RuntimeCfg.MaxTokens,
+		FrequencyPenalty: options.RuntimeCfg.FrequencyPenalty,
+		PresencePenalty:  options.RuntimeCfg.PresencePenalty,
+		Temperature:      options.RuntimeCfg.Temperature,
+	})
+	if err != nil {
+		return "", err
+	}
+	if rsp.Error != nil {
+		return "", errors.New(rsp.Error.Message)
+	}
+	if len(rsp.Choices) == 0 || rsp.Choices[0].Message == nil {
+		return "", ErrToolCallResponseEmpty
+	}
+	return rsp.Choices[0].Message.Content, nil
+}
+
+func (m *manus) RunStream(ctx context.Context, input string, opts ...RunOptionFunc) (stream *ssestream.StreamReader[Response]) {
+	return runStream(ctx, func() *Response {
+		return m.Run(ctx, input, opts...)
+	})
+}
+
+// ========AgentHub注册==========
+
+func (h *agentHub) SetManus(cfg *AgentConfig) (IAgent, error) {
+	ag, err := newManus(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.setAgentIns(ag)
+	return ag, nil
+}
@@ -0,0 +1,218 @@
+/*
+@Project: aihub
+@Module: aihub
+@File : manus_test.go
+*/
+package aihub
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func Test_manus_RunReplan(t *testing.T) {
+	newTestLLM(t, "test-manus-llm", func(req *CreateChatCompletionReq) *ChatCompletionRspChoice {
+		input := req.Messages[len(req.Messages)-1].Content
+		content := `{"goal":"查天气并推荐歌曲","tasks":[{"id":"t1","title":"查天气","agent":"test-manus-weather","question":"深圳天气"},{"id":"t2","title":"推荐歌曲","agent":"test-manus-broken","question":"推荐歌曲","depends_on":["t1"]}]}`
+		switch {
+		case strings.Contains(input, "任务t2执行失败"):
+			content = `{"goal":"查天气并推荐歌曲","tasks":[{"id":"t1","title":"查天气","agent":"test-manus-weather","question":"深圳天气"},{"id":"t3","title":"推荐歌曲","agent":"","question":"推荐歌曲","depends_on":["t1"]}]}`
+		case strings.Contains(input, "任务执行结果"):
+			content = "汇总完成"
+		case strings.Contains(input, "推荐歌曲"):
+			content = "晴天-周杰伦"
+		}
+		return &ChatCompletionRspChoice{
+			Message:      &Message{Role: MessageRoleAssistant, Content: content},
+			FinishReason: ChatCompletionRspFinishReasonStop,
+		}
+	})
+	newTestLLM(t, "test-manus-weather-llm", func(req *CreateChatCompletionReq) *ChatCompletionRspChoice {
+		return &ChatCompletionRspChoice{
+			Message:      &Message{Role: MessageRoleAssistant, Content: "深圳晴"},
+			FinishReason: ChatCompletionRspFinishReasonStop,
+		}
+	})
+
+	for name, llm := range map[string]string{"test-manus-weather": "test-manus-weather-llm", "test-manus-broken": "not-exist-llm"} {
+		if _, err := GetAgentHub().SetAgent(&AgentConfig{
+			BriefInfo:       BriefInfo{Name: name},
+			AgentRuntimeCfg: AgentRuntimeCfg{LLM: llm},
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	m, err := GetAgentHub().SetManus(&AgentConfig{
+		BriefInfo:       BriefInfo{Name: "test-manus"},
+		AgentRuntimeCfg: AgentRuntimeCfg{LLM: "test-manus-llm", SystemPrompt: "可用agent：{{agents}}"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rsp := m.Run(context.Background(), "深圳天气如何，推荐一首歌", WithAgents([]string{"test-manus-weather", "test-manus-broken"}))
+	if rsp.Err != nil {
+		t.Fatal(rsp.Err)
+	}
+	if rsp.Message.Content != "汇总完成" {
+		t.Fatalf("unexpected final answer => %s", rsp.Message.Content)
+	}
+	if rsp.Plan == nil || rsp.Plan.Version != 1 || len(rsp.Plan.Tasks) != 2 {
+		t.Fatalf("want replanned plan with 2 tasks, got %+v", rsp.Plan)
+	}
+	for _, task := range rsp.Plan.Tasks {
+		if task.State != RunState_Succeed {
+			t.Fatalf("task %s not succeed => %+v", task.ID, task)
+		}
+	}
+	if rsp.Plan.GetTask("t3").Result != "晴天-周杰伦" {
+		t.Fatalf("unexpected task result => %+v", rsp.Plan.GetTask("t3"))
+	}
+}
+
+func Test_parsePlan(t *testing.T) {
+	if _, err := parsePlan("```json\n{\"goal\":\"g\",\"tasks\":[{\"id\":\"a\",\"depends_on\":[\"b\"]},{\"id\":\"b\",\"depends_on\":[\"a\"]}]}\n```"); err == nil {
+		t.Fatal("want cycle error")
+	}
+	if _, err := parsePlan(`{"goal":"g","tasks":[{"id":"a","depends_on":["x"]}]}`); err == nil {
+		t.Fatal("want unknown depends error")
+	}
+	plan, err := parsePlan(`{"goal":"g","tasks":[],"answer":"hi"}`)
+	if err != nil || plan.Answer != "hi" {
+		t.Fatalf("unexpected => %+v, %v", plan, err)
+	}
+}
+
+func Test_manus_RunParallel(t *testing.T) {
+	var running, maxRunning int32
+	newTestLLM(t, "test-manus-city-llm", func(req *CreateChatCompletionReq) *ChatCompletionRspChoice {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			old := atomic.LoadInt32(&maxRunning)
+			if n <= old || atomic.CompareAndSwapInt32(&maxRunning, old, n) {
+				break
+			}
+		}
+		time.Sleep(100 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		return &ChatCompletionRspChoice{
+			Message:      &Message{Role: MessageRoleAssistant, Content: req.Messages[len(req.Messages)-1].Content + ":晴"},
+			FinishReason: ChatCompletionRspFinishReasonStop,
+		}
+	})
+	newTestLLM(t, "test-manus-parallel-llm", func(req *CreateChatCompletionReq) *ChatCompletionRspChoice {
+		input := req.Messages[len(req.Messages)-1].Content
+		content := `{"goal":"查三城天气","tasks":[{"id":"t1","title":"深圳","agent":"test-manus-city","question":"深圳"},{"id":"t2","title":"香港","agent":"test-manus-city","question":"香港"},{"id":"t3","title":"北京","agent":"test-manus-city","question":"北京"}]}`
+		if strings.Contains(input, "任务执行结果") {
+			content = input[strings.Index(input, "###"):]
+		}
+		return &ChatCompletionRspChoice{
+			Message:      &Message{Role: MessageRoleAssistant, Content: content},
+			FinishReason: ChatCompletionRspFinishReasonStop,
+		}
+	})
+	if _, err := GetAgentHub().SetAgent(&AgentConfig{
+		BriefInfo:       BriefInfo{Name: "test-manus-city"},
+		AgentRuntimeCfg: AgentRuntimeCfg{LLM: "test-manus-city-llm"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := GetAgentHub().SetManus(&AgentConfig{
+		BriefInfo:       BriefInfo{Name: "test-manus-parallel"},
+		AgentRuntimeCfg: AgentRuntimeCfg{LLM: "test-manus-parallel-llm", MaxAgentConcurrency: 2},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rsp := m.Run(context.Background(), "深圳、香港、北京天气")
+	if rsp.Err != nil {
+		t.Fatal(rsp.Err)
+	}
+	if rsp.Message.Content != "### 深圳\n深圳:晴\n\n### 香港\n香港:晴\n\n### 北京\n北京:晴" {
+		t.Fatalf("results not in plan order => %s", rsp.Message.Content)
+	}
+	if maxRunning != 2 {
+		t.Fatalf("want max concurrency 2, got %d", maxRunning)
+	}
+
+	// AgentCall并行子任务
+	output := &Message{}
+	ctx := ContextWithSession(context.Background(), newSession(map[string]interface{}{"user_id": "u1"}))
+	if len(GetToolHub().GetTool(AgentCallFuncName)) == 0 {
+		GetToolHub().SetTool(ToolEntry{Function: AgentCall})
+	}
+	err = GetToolHub().ProxyCall(ctx, AgentCallFuncName, `{"_tasks_":[{"_action_":"test-manus-city","_question_":"上海"},{"_action_":"not-exist-agent","_question_":"广州"}]}`, output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	results := make([]*AgentCallResult, 0)
+	if err = json.Unmarshal([]byte(output.Content), &results); err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[0].Result != "上海:晴" || results[1].Error == "" {
+		t.Fatalf("unexpected results => %s", output.Content)
+	}
+}
+
+func Test_manus_RunHistory(t *testing.T) {
+	var histories int32
+	newTestLLM(t, "test-manus-history-llm", func(req *CreateChatCompletionReq) *ChatCompletionRspChoice {
+		input := req.Messages[len(req.Messages)-1].Content
+		content := `{"goal":"问候","tasks":[],"answer":"你好，小明"}`
+		switch {
+		case strings.Contains(input, "评审意见"):
+			content = "你好，小明！"
+		case strings.Contains(input, "回答草稿"):
+			content = `{"pass":` + fmt.Sprint(!strings.HasSuffix(input, "你好，小明")) + `,"feedback":"语气再热情些"}`
+		case strings.Contains(input, "我叫什么"):
+			for _, msg := range req.Messages {
+				if msg.Role == MessageRoleAssistant && msg.Content == "你好，小明！" {
+					atomic.AddInt32(&histories, 1)
+				}
+			}
+			content = `{"goal":"回答名字","tasks":[],"answer":"你叫小明"}`
+		}
+		return &ChatCompletionRspChoice{
+			Message:      &Message{Role: MessageRoleAssistant, Content: content},
+			FinishReason: ChatCompletionRspFinishReasonStop,
+		}
+	})
+
+	m, err := GetAgentHub().SetManus(&AgentConfig{
+		BriefInfo:       BriefInfo{Name: "test-manus-history"},
+		AgentRuntimeCfg: AgentRuntimeCfg{LLM: "test-manus-history-llm"},
+		Reflection:      &ReflectionConfig{Criteria: "语气热情"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 反思评审不通过时修正回复
+	rsp := m.Run(context.Background(), "我是小明", WithSessionID("test-manus-history"))
+	if rsp.Err != nil {
+		t.Fatal(rsp.Err)
+	}
+	if rsp.Message.Content != "你好，小明！" {
+		t.Fatalf("want revised answer => %s", rsp.Message.Content)
+	}
+
+	// 历史对话参与规划
+	rsp = m.Run(context.Background(), "我叫什么", WithSessionID("test-manus-history"))
+	if rsp.Err != nil {
+		t.Fatal(rsp.Err)
+	}
+	if histories != 1 {
+		t.Fatalf("want history in planning request, got %d", histories)
+	}
+	if msgs := m.GetMemory().GetLatest(newTestMemoryOptions("test-manus-history", 10)); len(msgs) != 4 || msgs[3].Content != "你叫小明" {
+		t.Fatalf("unexpected memory => %d", len(msgs))
+	}
+}
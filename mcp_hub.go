@@ -3,6 +3,8 @@ package aihub
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/mark3labs/mcp-go/client"
 	"github.com/mark3labs/mcp-go/mcp"
 	"sync"
@@ -12,9 +14,34 @@ type mcpHub struct {
 	clientMaps map[string]*mcpClient // svraddr => client
 	fnMaps     map[string]*mcpClient // funcName => client
 
+	policies map[string]*mcpToolPolicy // funcName => 调用策略
+
 	lock sync.RWMutex
 }
 
+// mcpToolPolicy MCP工具调用策略及按其创建的并发限制
+type mcpToolPolicy struct {
+	policy ToolPolicy
+	sem    chan struct{}
+}
+
+// SetToolPolicy 设置MCP工具的调用策略：超时、并发、重试，同名覆盖
+func (m *mcpHub) SetToolPolicy(name string, policy ToolPolicy) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.policies[name] = &mcpToolPolicy{policy: policy, sem: newSemaphore(policy.MaxConcurrency)}
+}
+
+// getToolPolicy 获取MCP工具的调用策略及并发限制
+func (m *mcpHub) getToolPolicy(name string) (*ToolPolicy, chan struct{}) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	if tmp, ok := m.policies[name]; ok {
+		return &tmp.policy, tmp.sem
+	}
+	return nil, nil
+}
+
 func (m *mcpHub) GetAllNameList() []string {
 	m.lock.RLock()
 	defer m.lock.RUnlock()
@@ -67,6 +94,19 @@ func (m *mcpHub) ProxyCall(ctx context.Context, name string, input string, outpu
 		return
 	}
 
+	// 按参数定义校验入参
+	cli.lock.RLock()
+	toolFunction, ok := cli.toolFuncMaps[name]
+	cli.lock.RUnlock()
+	if ok {
+		if err = validateToolArguments(name, toolFunction.Parameters, input); err != nil {
+			return
+		}
+	}
+	if input == "" {
+		input = "{}"
+	}
+
 	args := make(map[string]interface{})
 	if err = json.Unmarshal([]byte(input), &args); err != nil {
 		return
@@ -145,6 +185,10 @@ func (m *mcpHub) SetClient(addrs ...string) error {
 			continue
 		}
 
+		// 关闭同地址的旧客户端
+		if old, ok := m.clientMaps[addr]; ok {
+			m.closeClient(old)
+		}
 		m.clientMaps[addr] = cli
 		for _, toolFunction := range cli.GetToolFunctions() {
 			m.fnMaps[toolFunction.Name] = cli
@@ -159,15 +203,38 @@ func (m *mcpHub) DelClient(addrs ...string) error {
 	defer m.lock.Unlock()
 	for _, addr := range addrs {
 		if cli, ok := m.clientMaps[addr]; ok {
-			for _, toolFunction := range cli.GetToolFunctions() {
-				delete(m.fnMaps, toolFunction.Name)
-			}
+			m.closeClient(cli)
 		}
 		delete(m.clientMaps, addr)
 	}
 	return nil
 }
 
+// Close 关闭所有MCP客户端
+func (m *mcpHub) Close() error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	errs := make([]error, 0)
+	for addr, cli := range m.clientMaps {
+		if err := m.closeClient(cli); err != nil {
+			errs = append(errs, fmt.Errorf("mcp client %s => %w", addr, err))
+		}
+	}
+	m.clientMaps = make(map[string]*mcpClient)
+	return errors.Join(errs...)
+}
+
+// closeClient 移除客户端的工具映射并关闭连接，调用方需持有锁
+func (m *mcpHub) closeClient(cli *mcpClient) error {
+	for _, toolFunction := range cli.GetToolFunctions() {
+		if m.fnMaps[toolFunction.Name] == cli {
+			delete(m.fnMaps, toolFunction.Name)
+		}
+	}
+	return cli.Close()
+}
+
 func (m *mcpHub) ConvertToOPENAPIConfig() string {
 	m.lock.RLock()
 	defer m.lock.RUnlock()
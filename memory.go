@@ -1,6 +1,8 @@
 package aihub
 
 import (
+	"fmt"
+	"sort"
 	"sync"
 	"time"
 )
@@ -10,6 +12,9 @@ type memory struct {
 	limit    int
 	timeout  int64
 	lock     sync.RWMutex
+
+	done      chan struct{} // 关闭时通知cronClean退出
+	closeOnce sync.Once
 }
 
 func newMemory(cfg *AgentRuntimeCfg) IMemory {
@@ -17,6 +22,7 @@ func newMemory(cfg *AgentRuntimeCfg) IMemory {
 		messages: make(map[string][]*Message),
 		limit:    cfg.MaxStoreMemory,
 		timeout:  cfg.MemoryTimeout,
+		done:     make(chan struct{}),
 	}
 
 	go ret.cronClean()
@@ -25,7 +31,14 @@ func newMemory(cfg *AgentRuntimeCfg) IMemory {
 
 func (h *memory) cronClean() {
 	ticker := time.NewTicker(time.Minute)
-	for range ticker.C {
+	defer ticker.Stop()
+	for {
+		select {
+		case <-h.done:
+			return
+		case <-ticker.C:
+		}
+
 		// 过期判定和清理
 		now := time.Now().Unix()
 
@@ -48,7 +61,6 @@ func (h *memory) cronClean() {
 }
 
 func (h *memory) Push(opts *RunOptions, msg ...*Message) {
-	toAddCnt := len(msg)
 	now := time.Now().Unix()
 
 	h.lock.Lock()
@@ -64,16 +76,8 @@ func (h *memory) Push(opts *RunOptions, msg ...*Message) {
 		}
 	}
 
-	if _, ok := h.messages[sessionId]; !ok {
-		h.messages[sessionId] = make([]*Message, 0)
-	}
-
-	oralLen := len(h.messages[sessionId])
-	if oralLen+toAddCnt > h.limit {
-		shiftIdx := toAddCnt - (h.limit - oralLen)
-		h.messages[sessionId] = h.messages[sessionId][shiftIdx:]
-	}
-	h.messages[sessionId] = append(h.messages[sessionId], msg...)
+	list := append(h.messages[sessionId], msg...)
+	h.messages[sessionId] = list[memoryWindowStart(list, h.limit):]
 }
 
 func (h *memory) GetLatest(opts *RunOptions) []*Message {
@@ -84,21 +88,106 @@ func (h *memory) GetLatest(opts *RunOptions) []*Message {
 		return []*Message{}
 	}
 
-	idx := 0
-	if opts.RuntimeCfg.MaxUseMemory > 0 && opts.RuntimeCfg.MaxUseMemory < len(target) {
-		idx = len(target) - opts.RuntimeCfg.MaxUseMemory
-	}
-	return target[idx:]
+	return target[memoryWindowStart(target, opts.RuntimeCfg.MaxUseMemory):]
 }
 
 func (h *memory) Clear(opts *RunOptions) {
 	h.lock.Lock()
 	defer h.lock.Unlock()
+	delete(h.messages, opts.GetSessionID())
+}
 
-	sessionId := opts.GetSessionID()
-	if _, ok := h.messages[sessionId]; ok {
-		delete(h.messages, sessionId)
+// Close 停止过期清理任务，关闭后仍可读写
+func (h *memory) Close() error {
+	h.closeOnce.Do(func() {
+		close(h.done)
+	})
+	return nil
+}
+
+func (h *memory) ClearAll() {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.messages = make(map[string][]*Message)
+}
+
+func (h *memory) ListSessions() []string {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+
+	ret := make([]string, 0, len(h.messages))
+	for sessionId := range h.messages {
+		ret = append(ret, sessionId)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+func (h *memory) GetHistory(sessionID string) []*Message {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+	return append([]*Message{}, h.messages[sessionID]...)
+}
+
+func (h *memory) DeleteMessage(sessionID string, index int) error {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	list, err := deleteMemoryMessage(h.messages[sessionID], index)
+	if err != nil {
+		return err
+	}
+	if len(list) > 0 {
+		h.messages[sessionID] = list
 	} else {
-		h.messages = make(map[string][]*Message) // 删除所有
+		delete(h.messages, sessionID)
+	}
+	return nil
+}
+
+// memoryWindowStart 计算截取最近最多limit条消息的起始位置（limit<=0不限制条数）
+// 带ToolCalls的assistant消息与其后的tool消息视为一组，起始位置不落在tool消息上，避免请求中出现孤立的tool消息；
+// 最后一组超过limit条时仍完整保留该组
+func memoryWindowStart(msgs []*Message, limit int) int {
+	idx := 0
+	if limit > 0 && limit < len(msgs) {
+		idx = len(msgs) - limit
+	}
+	for idx < len(msgs) && msgs[idx].Role == MessageRoleTool {
+		idx++
+	}
+	if idx < len(msgs) {
+		return idx
 	}
+
+	// 向前回退到最后一组的起始位置
+	for idx = len(msgs) - 1; idx >= 0; idx-- {
+		if msgs[idx].Role != MessageRoleTool {
+			return idx
+		}
+	}
+	return len(msgs) // 全部为孤立的tool消息
+}
+
+// deleteMemoryMessage 删除指定下标的消息，返回新列表；工具调用消息与其结果视为一组整组删除
+func deleteMemoryMessage(msgs []*Message, index int) ([]*Message, error) {
+	if index < 0 || index >= len(msgs) {
+		return nil, fmt.Errorf("%w: index %d of %d", ErrMemoryNotFound, index, len(msgs))
+	}
+
+	start := index
+	for start > 0 && msgs[start].Role == MessageRoleTool {
+		start--
+	}
+	end := start + 1
+	for end < len(msgs) && msgs[end].Role == MessageRoleTool {
+		end++
+	}
+	if msgs[start].Role != MessageRoleTool && len(msgs[start].ToolCalls) == 0 {
+		start, end = index, index+1 // 普通消息仅删除自身
+	}
+
+	ret := make([]*Message, 0, len(msgs)-(end-start))
+	ret = append(ret, msgs[:start]...)
+	return append(ret, msgs[end:]...), nil
 }
@@ -0,0 +1,149 @@
+/*
+@Project: aihub
+@Module: aihub
+@File : memory_bolt.go
+*/
+package aihub
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+type boltDBRef struct {
+	db   *bolt.DB
+	refs int
+}
+
+var (
+	boltDBs     = make(map[string]*boltDBRef) // path => db，bbolt文件独占打开，同一路径的agent共用
+	boltDBsLock sync.Mutex
+)
+
+func openBoltDB(path string) (*bolt.DB, error) {
+	boltDBsLock.Lock()
+	defer boltDBsLock.Unlock()
+
+	if ref, ok := boltDBs[path]; ok {
+		ref.refs++
+		return ref.db, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 3 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+	boltDBs[path] = &boltDBRef{db: db, refs: 1}
+	return db, nil
+}
+
+// releaseBoltDB 释放引用，最后一个引用释放时关闭数据库
+func releaseBoltDB(path string) error {
+	boltDBsLock.Lock()
+	defer boltDBsLock.Unlock()
+
+	ref, ok := boltDBs[path]
+	if !ok {
+		return nil
+	}
+	if ref.refs--; ref.refs > 0 {
+		return nil
+	}
+	delete(boltDBs, path)
+	return ref.db.Close()
+}
+
+// boltMemoryStore 嵌入式KV存储，每个agent一个bucket，会话ID为key
+type boltMemoryStore struct {
+	db     *bolt.DB
+	path   string
+	bucket []byte
+	closed sync.Once
+}
+
+func newBoltMemoryStore(path string, bucket string) (*boltMemoryStore, error) {
+	db, err := openBoltDB(path)
+	if err != nil {
+		return nil, err
+	}
+	return &boltMemoryStore{db: db, path: path, bucket: []byte(bucket)}, nil
+}
+
+// Close 释放数据库引用
+func (s *boltMemoryStore) Close() (err error) {
+	s.closed.Do(func() {
+		err = releaseBoltDB(s.path)
+	})
+	return
+}
+
+func (s *boltMemoryStore) Load(sessionID string) (ret []*Message, err error) {
+	ret = []*Message{}
+	err = s.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(s.bucket)
+		if bucket == nil {
+			return nil
+		}
+		data := bucket.Get([]byte(sessionID))
+		if data == nil {
+			return nil
+		}
+		ret, err = decodeMemoryRecords(data)
+		return err
+	})
+	return
+}
+
+func (s *boltMemoryStore) Save(sessionID string, msgs []*Message, ttl time.Duration) error {
+	data, err := encodeMemoryRecords(msgs)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(s.bucket)
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte(sessionID), data)
+	})
+}
+
+func (s *boltMemoryStore) Delete(sessionID string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(s.bucket)
+		if bucket == nil {
+			return nil
+		}
+		return bucket.Delete([]byte(sessionID))
+	})
+}
+
+func (s *boltMemoryStore) List() ([]string, error) {
+	ret := make([]string, 0)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(s.bucket)
+		if bucket == nil {
+			return nil
+		}
+		return bucket.ForEach(func(k, v []byte) error {
+			ret = append(ret, string(k))
+			return nil
+		})
+	})
+	return ret, err
+}
+
+func (s *boltMemoryStore) DeleteAll() error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if tx.Bucket(s.bucket) == nil {
+			return nil
+		}
+		return tx.DeleteBucket(s.bucket)
+	})
+}
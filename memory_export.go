@@ -0,0 +1,80 @@
+/*
+@Project: aihub
+@Module: aihub
+@File : memory_export.go
+*/
+package aihub
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// MemorySession 会话导出记录，每行一个会话，messages为OpenAI消息格式
+type MemorySession struct {
+	SessionID string     `json:"session_id"`
+	Messages  []*Message `json:"messages"`
+}
+
+// ExportMemory 以JSONL格式导出会话记录，未指定会话ID时导出全部会话
+func ExportMemory(mem IMemory, w io.Writer, sessionIDs ...string) error {
+	if len(sessionIDs) == 0 {
+		sessionIDs = mem.ListSessions()
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	for _, sessionID := range sessionIDs {
+		msgs := mem.GetHistory(sessionID)
+		if len(msgs) == 0 {
+			continue
+		}
+		if err := encoder.Encode(&MemorySession{SessionID: sessionID, Messages: msgs}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ImportMemory 导入ExportMemory导出的JSONL会话记录，同ID会话的已有记录被覆盖，返回导入的会话数
+// 导入消息的创建时间重置为当前时间，超过MaxStoreMemory的较早消息按存储规则淘汰
+func ImportMemory(mem IMemory, r io.Reader) (int, error) {
+	sessions := make([]*MemorySession, 0)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		content := strings.TrimSpace(scanner.Text())
+		if content == "" {
+			continue
+		}
+
+		item := &MemorySession{}
+		if err := json.Unmarshal([]byte(content), item); err != nil {
+			return 0, fmt.Errorf("%w: line %d => %v", ErrMemoryImportInvalid, line, err)
+		}
+		if item.SessionID == "" {
+			return 0, fmt.Errorf("%w: line %d session_id empty", ErrMemoryImportInvalid, line)
+		}
+		sessions = append(sessions, item)
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+
+	// 全部校验通过后再写入
+	for _, item := range sessions {
+		opts := &RunOptions{Session: newSession(nil)}
+		opts.SessionID = item.SessionID
+		for _, msg := range item.Messages {
+			msg.CreateTime = 0
+			msg.SessionID = item.SessionID
+		}
+
+		mem.Clear(opts)
+		mem.Push(opts, item.Messages...)
+	}
+	return len(sessions), nil
+}
@@ -0,0 +1,169 @@
+/*
+@Project: aihub
+@Module: aihub
+@File : memory_kv.go
+*/
+package aihub
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// IKVStore Redis等外部KV存储适配接口
+type IKVStore interface {
+	// Get 读取key，不存在时返回nil, nil
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Set 写入key，ttl>0时设置过期时间
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Del 删除key
+	Del(ctx context.Context, keys ...string) error
+	// Keys 列出指定前缀的所有key，Redis可通过SCAN prefix*实现
+	Keys(ctx context.Context, prefix string) ([]string, error)
+}
+
+var (
+	kvStores     = make(map[string]IKVStore)
+	kvStoresLock sync.RWMutex
+)
+
+// RegisterKVStore 注册外部KV存储，供memory配置的kv类型按名称引用，同名覆盖
+func RegisterKVStore(name string, store IKVStore) {
+	kvStoresLock.Lock()
+	defer kvStoresLock.Unlock()
+	kvStores[name] = store
+}
+
+func getKVStore(name string) IKVStore {
+	kvStoresLock.RLock()
+	defer kvStoresLock.RUnlock()
+	return kvStores[name]
+}
+
+// kvMemoryStore 基于IKVStore的会话记忆存储，key为前缀+会话ID
+type kvMemoryStore struct {
+	kv     IKVStore
+	prefix string
+}
+
+func newKVMemoryStore(kv IKVStore, prefix string) *kvMemoryStore {
+	return &kvMemoryStore{kv: kv, prefix: prefix}
+}
+
+func (s *kvMemoryStore) Load(sessionID string) ([]*Message, error) {
+	data, err := s.kv.Get(context.Background(), s.prefix+sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if data == nil {
+		return []*Message{}, nil
+	}
+	return decodeMemoryRecords(data)
+}
+
+func (s *kvMemoryStore) Save(sessionID string, msgs []*Message, ttl time.Duration) error {
+	data, err := encodeMemoryRecords(msgs)
+	if err != nil {
+		return err
+	}
+	return s.kv.Set(context.Background(), s.prefix+sessionID, data, ttl)
+}
+
+func (s *kvMemoryStore) Delete(sessionID string) error {
+	return s.kv.Del(context.Background(), s.prefix+sessionID)
+}
+
+func (s *kvMemoryStore) DeleteAll() error {
+	keys, err := s.kv.Keys(context.Background(), s.prefix)
+	if err != nil || len(keys) == 0 {
+		return err
+	}
+	return s.kv.Del(context.Background(), keys...)
+}
+
+func (s *kvMemoryStore) List() ([]string, error) {
+	keys, err := s.kv.Keys(context.Background(), s.prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]string, 0, len(keys))
+	for _, key := range keys {
+		ret = append(ret, strings.TrimPrefix(key, s.prefix))
+	}
+	return ret, nil
+}
+
+// memKVStore 进程内KV存储，支持过期时间，用于测试或单机场景
+type memKVStore struct {
+	values map[string]*memKVItem
+	lock   sync.RWMutex
+}
+
+type memKVItem struct {
+	value    []byte
+	expireAt time.Time // 零值表示不过期
+}
+
+// NewMemKVStore 创建进程内KV存储
+func NewMemKVStore() IKVStore {
+	return &memKVStore{values: make(map[string]*memKVItem)}
+}
+
+func (s *memKVStore) get(key string) *memKVItem {
+	item, ok := s.values[key]
+	if !ok || (!item.expireAt.IsZero() && time.Now().After(item.expireAt)) {
+		return nil
+	}
+	return item
+}
+
+func (s *memKVStore) Get(ctx context.Context, key string) ([]byte, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	item := s.get(key)
+	if item == nil {
+		return nil, nil
+	}
+	return append([]byte{}, item.value...), nil
+}
+
+func (s *memKVStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	item := &memKVItem{value: append([]byte{}, value...)}
+	if ttl > 0 {
+		item.expireAt = time.Now().Add(ttl)
+	}
+	s.values[key] = item
+	return nil
+}
+
+func (s *memKVStore) Del(ctx context.Context, keys ...string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, key := range keys {
+		delete(s.values, key)
+	}
+	return nil
+}
+
+func (s *memKVStore) Keys(ctx context.Context, prefix string) ([]string, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	ret := make([]string, 0)
+	for key := range s.values {
+		if strings.HasPrefix(key, prefix) && s.get(key) != nil {
+			ret = append(ret, key)
+		}
+	}
+	sort.Strings(ret)
+	return ret, nil
+}
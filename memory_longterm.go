@@ -0,0 +1,228 @@
+/*
+@Project: aihub
+@Module: aihub
+@File : memory_longterm.go
+*/
+package aihub
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	LongTermScope_Session = "session" // 按会话ID隔离
+	LongTermScope_User    = "user"    // 按会话数据中的用户ID隔离，可跨会话召回
+)
+
+const longTermExtractPrompt = `你是一个记忆整理助手，负责从一轮对话中抽取值得长期记住的事实，例如用户身份、偏好、约定及重要结论。
+## 要求：
+1. 每条事实独立完整、简洁明确，不依赖上下文即可理解
+2. 没有值得记住的事实时输出空数组
+
+## 输出格式：
+只输出标准JSON字符串数组（去除制表、换行符）：["事实1","事实2"]`
+
+const longTermRecallTpl = `
+
+## 长期记忆
+以下是与当前问题相关的历史信息，仅供参考：
+%s`
+
+// LongTermMemoryConfig 长期记忆配置：将选定消息或抽取的事实向量化存入本地向量索引，每次运行前检索相关条目注入系统提示词
+type LongTermMemoryConfig struct {
+	Embedder   string            `json:"embedder,omitempty" yaml:"embedder,omitempty"`       // 向量化实现名称，RegisterEmbedder注册的名称或支持embeddings接口的LLM名称
+	Path       string            `json:"path,omitempty" yaml:"path,omitempty"`               // 可选，向量索引持久化文件路径，为空时仅保存在内存
+	Scope      string            `json:"scope,omitempty" yaml:"scope,omitempty"`             // 隔离范围：session(默认)|user
+	UserKey    string            `json:"user_key,omitempty" yaml:"user_key,omitempty"`       // scope=user时会话数据中的用户ID键名，默认user_id，缺失时退化为按会话隔离
+	TopK       int               `json:"top_k,omitempty" yaml:"top_k,omitempty"`             // 每次检索注入的条数，默认3
+	MinScore   float64           `json:"min_score,omitempty" yaml:"min_score,omitempty"`     // 最低相似度，默认0.5
+	Roles      []MessageRoleType `json:"roles,omitempty" yaml:"roles,omitempty"`             // 直接写入的消息角色，默认user，配置ExtractLLM时不生效
+	MinLength  int               `json:"min_length,omitempty" yaml:"min_length,omitempty"`   // 写入内容的最小字符数，默认4
+	ExtractLLM string            `json:"extract_llm,omitempty" yaml:"extract_llm,omitempty"` // 可选，由该LLM从每轮问答中抽取事实写入，替代直接写入消息原文
+}
+
+func (cfg *LongTermMemoryConfig) AutoFix() error {
+	if cfg.Embedder == "" {
+		return fmt.Errorf("%w: long term memory embedder empty", ErrConfiguration)
+	}
+	if cfg.Scope != LongTermScope_User {
+		cfg.Scope = LongTermScope_Session
+	}
+	if cfg.UserKey == "" {
+		cfg.UserKey = "user_id"
+	}
+	if cfg.TopK <= 0 || cfg.TopK > 20 {
+		cfg.TopK = 3
+	}
+	if cfg.MinScore <= 0 || cfg.MinScore >= 1 {
+		cfg.MinScore = 0.5
+	}
+	if len(cfg.Roles) == 0 {
+		cfg.Roles = []MessageRoleType{MessageRoleUser}
+	}
+	if cfg.MinLength <= 0 {
+		cfg.MinLength = 4
+	}
+	return nil
+}
+
+// longTermMemory 长期记忆
+type longTermMemory struct {
+	cfg   *LongTermMemoryConfig
+	index *VectorIndex
+}
+
+func newLongTermMemory(cfg *LongTermMemoryConfig) (*longTermMemory, error) {
+	index, err := OpenVectorIndex(cfg.Path)
+	if err != nil {
+		return nil, err
+	}
+	return &longTermMemory{cfg: cfg, index: index}, nil
+}
+
+// scope 计算隔离范围
+func (m *longTermMemory) scope(opts *RunOptions) string {
+	if m.cfg.Scope == LongTermScope_User {
+		if userID := fmt.Sprintf("%v", opts.GetSessionData(m.cfg.UserKey)); userID != "" && userID != "<nil>" {
+			return LongTermScope_User + ":" + userID
+		}
+	}
+	return LongTermScope_Session + ":" + opts.GetSessionID()
+}
+
+func (m *longTermMemory) embed(ctx context.Context, texts []string) ([][]float32, error) {
+	embedder := GetEmbedder(m.cfg.Embedder)
+	if embedder == nil {
+		return nil, fmt.Errorf("%w: embedder %s", ErrConfiguration, m.cfg.Embedder)
+	}
+	return embedder.Embed(ctx, texts)
+}
+
+// Recall 检索与输入相关的长期记忆
+func (m *longTermMemory) Recall(ctx context.Context, opts *RunOptions, input string) ([]*VectorHit, error) {
+	if m.index.Len() == 0 || strings.TrimSpace(input) == "" {
+		return []*VectorHit{}, nil
+	}
+
+	vectors, err := m.embed(ctx, []string{input})
+	if err != nil {
+		return nil, err
+	}
+
+	scope := m.scope(opts)
+	hits := m.index.Search(vectors[0], m.cfg.TopK, func(item *VectorItem) bool {
+		return item.Scope == scope
+	})
+	ret := make([]*VectorHit, 0, len(hits))
+	for _, hit := range hits {
+		if hit.Score >= m.cfg.MinScore {
+			ret = append(ret, hit)
+		}
+	}
+	return ret, nil
+}
+
+// Remember 将本轮问答写入长期记忆：配置ExtractLLM时写入抽取的事实，否则写入选定角色的消息原文，已存在的相同内容不重复写入
+func (m *longTermMemory) Remember(ctx context.Context, opts *RunOptions, input string, answer string) error {
+	var texts []string
+	if m.cfg.ExtractLLM != "" {
+		facts, err := m.extract(ctx, input, answer)
+		if err != nil {
+			return err
+		}
+		texts = facts
+	} else {
+		for _, role := range m.cfg.Roles {
+			switch role {
+			case MessageRoleUser:
+				texts = append(texts, input)
+			case MessageRoleAssistant:
+				texts = append(texts, answer)
+			}
+		}
+	}
+
+	scope := m.scope(opts)
+	exists := make(map[string]bool)
+	for _, item := range m.index.Find(func(item *VectorItem) bool { return item.Scope == scope }) {
+		exists[item.Text] = true
+	}
+	toAdd := make([]string, 0, len(texts))
+	for _, text := range texts {
+		text = strings.TrimSpace(text)
+		if utf8.RuneCountInString(text) < m.cfg.MinLength || exists[text] {
+			continue
+		}
+		exists[text] = true
+		toAdd = append(toAdd, text)
+	}
+	if len(toAdd) == 0 {
+		return nil
+	}
+
+	vectors, err := m.embed(ctx, toAdd)
+	if err != nil {
+		return err
+	}
+	if len(vectors) != len(toAdd) {
+		return fmt.Errorf("%w: embeddings count %d, want %d", ErrEmbeddingInvalid, len(vectors), len(toAdd))
+	}
+
+	items := make([]*VectorItem, 0, len(toAdd))
+	for i, text := range toAdd {
+		items = append(items, &VectorItem{
+			Scope:  scope,
+			Text:   text,
+			Vector: vectors[i],
+			Meta:   map[string]interface{}{"session_id": opts.GetSessionID(), "run_id": opts.RunID},
+		})
+	}
+	return m.index.Add(items...)
+}
+
+// extract 由LLM从本轮问答中抽取事实
+func (m *longTermMemory) extract(ctx context.Context, input string, answer string) ([]string, error) {
+	LLMIns := GetLLMHub().GetLLM(m.cfg.ExtractLLM)
+	if LLMIns == nil {
+		return nil, fmt.Errorf("%w: llm %s", ErrConfiguration, m.cfg.ExtractLLM)
+	}
+
+	rsp, err := createChatCompletion(ctx, LLMIns, &CreateChatCompletionReq{
+		Messages: []*Message{
+			{Role: MessageRoleSystem, Content: longTermExtractPrompt},
+			{Role: MessageRoleUser, Content: fmt.Sprintf("## 用户\n%s\n\n## 助手\n%s", input, answer)},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if rsp.Error != nil {
+		return nil, errors.New(rsp.Error.Message)
+	}
+	if len(rsp.Choices) == 0 || rsp.Choices[0].Message == nil {
+		return nil, ErrToolCallResponseEmpty
+	}
+
+	facts := make([]string, 0)
+	if err = json.Unmarshal([]byte(TrimJSONCodeBlock(rsp.Choices[0].Message.Content)), &facts); err != nil {
+		return nil, err
+	}
+	return facts, nil
+}
+
+// formatRecalls 将检索结果格式化为系统提示词片段
+func formatRecalls(hits []*VectorHit) string {
+	if len(hits) == 0 {
+		return ""
+	}
+	builder := strings.Builder{}
+	for _, hit := range hits {
+		builder.WriteString("- " + hit.Text + "\n")
+	}
+	return fmt.Sprintf(longTermRecallTpl, strings.TrimRight(builder.String(), "\n"))
+}
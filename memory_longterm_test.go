@@ -0,0 +1,90 @@
+/*
+@Project: aihub
+@Module: aihub
+@File : memory_longterm_test.go
+*/
+package aihub
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// charEmbedder 按字符计数的测试向量化实现
+type charEmbedder struct{}
+
+func (e *charEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	ret := make([][]float32, 0, len(texts))
+	for _, text := range texts {
+		vector := make([]float32, 64)
+		for _, r := range text {
+			vector[int(r)%64]++
+		}
+		ret = append(ret, vector)
+	}
+	return ret, nil
+}
+
+func Test_agent_RunLongTermMemory(t *testing.T) {
+	RegisterEmbedder("test-char-embedder", &charEmbedder{})
+	systemPrompts := make([]string, 0)
+	newTestLLM(t, "test-longterm-llm", func(req *CreateChatCompletionReq) *ChatCompletionRspChoice {
+		if req.Messages[0] != nil && req.Messages[0].Role == MessageRoleSystem {
+			systemPrompts = append(systemPrompts, req.Messages[0].Content)
+		} else {
+			systemPrompts = append(systemPrompts, "")
+		}
+		return &ChatCompletionRspChoice{
+			Message:      &Message{Role: MessageRoleAssistant, Content: "好的"},
+			FinishReason: ChatCompletionRspFinishReasonStop,
+		}
+	})
+
+	path := filepath.Join(t.TempDir(), "longterm.jsonl")
+	ag, err := GetAgentHub().SetAgent(&AgentConfig{
+		BriefInfo:       BriefInfo{Name: "test-longterm"},
+		AgentRuntimeCfg: AgentRuntimeCfg{LLM: "test-longterm-llm", SystemPrompt: "你是助手"},
+		Memory: &MemoryConfig{LongTerm: &LongTermMemoryConfig{
+			Embedder: "test-char-embedder",
+			Path:     path,
+			Scope:    LongTermScope_User,
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	runAs := func(userID string, input string) {
+		if rsp := ag.Run(context.Background(), input, WithSessionData(map[string]interface{}{"user_id": userID})); rsp.Err != nil {
+			t.Fatal(rsp.Err)
+		}
+	}
+	runAs("u1", "我最喜欢的颜色是蓝色")
+	runAs("u1", "我喜欢什么颜色")
+	runAs("u2", "我喜欢什么颜色")
+
+	if strings.Contains(systemPrompts[0], "长期记忆") {
+		t.Fatalf("unexpected recall in first run => %s", systemPrompts[0])
+	}
+	if !strings.HasPrefix(systemPrompts[1], "你是助手") || !strings.Contains(systemPrompts[1], "- 我最喜欢的颜色是蓝色") {
+		t.Fatalf("want recall for same user in new session => %s", systemPrompts[1])
+	}
+	if strings.Contains(systemPrompts[2], "蓝色") {
+		t.Fatalf("recall leaked to other user => %s", systemPrompts[2])
+	}
+
+	// 重新加载持久化的索引
+	index := &VectorIndex{path: path}
+	if err = index.load(); err != nil {
+		t.Fatal(err)
+	}
+	if index.Len() != 3 {
+		t.Fatalf("want 3 persisted items, got %d", index.Len())
+	}
+	hits := index.Search(index.items[0].Vector, 1, func(item *VectorItem) bool { return item.Scope == "user:u1" })
+	if len(hits) != 1 || hits[0].Text != "我最喜欢的颜色是蓝色" {
+		t.Fatalf("unexpected hits => %v", hits)
+	}
+}
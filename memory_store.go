@@ -0,0 +1,468 @@
+/*
+@Project: aihub
+@Module: aihub
+@File : memory_store.go
+*/
+package aihub
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	MemoryType_Memory = "memory" // 进程内存储（默认）
+	MemoryType_File   = "file"   // 本地文件存储，每个会话一个JSONL文件
+	MemoryType_Bolt   = "bolt"   // 嵌入式KV存储（bbolt）
+	MemoryType_KV     = "kv"     // 外部KV存储（Redis等），需先通过RegisterKVStore注册适配器
+)
+
+// MemoryConfig 会话记忆存储配置
+type MemoryConfig struct {
+	Type   string `json:"type,omitempty" yaml:"type,omitempty"`     // 存储类型：memory(默认)|file|bolt|kv，或RegisterMemory注册的自定义类型
+	Path   string `json:"path,omitempty" yaml:"path,omitempty"`     // file：存储目录；bolt：数据库文件路径
+	Store  string `json:"store,omitempty" yaml:"store,omitempty"`   // kv：RegisterKVStore注册的存储名称
+	Prefix string `json:"prefix,omitempty" yaml:"prefix,omitempty"` // kv：键名前缀，默认aihub:memory:
+
+	Summary  *MemorySummaryConfig  `json:"summary,omitempty" yaml:"summary,omitempty"`     // 可选，超过阈值时将较早的对话压缩为摘要
+	LongTerm *LongTermMemoryConfig `json:"long_term,omitempty" yaml:"long_term,omitempty"` // 可选，基于向量检索的长期记忆
+}
+
+func (cfg *MemoryConfig) AutoFix() error {
+	if cfg.Type == "" {
+		cfg.Type = MemoryType_Memory
+	}
+	switch cfg.Type {
+	case MemoryType_File, MemoryType_Bolt:
+		if cfg.Path == "" {
+			return fmt.Errorf("%w: memory type %s path empty", ErrConfiguration, cfg.Type)
+		}
+	case MemoryType_KV:
+		if cfg.Store == "" {
+			return fmt.Errorf("%w: memory type %s store empty", ErrConfiguration, cfg.Type)
+		}
+		if cfg.Prefix == "" {
+			cfg.Prefix = "aihub:memory:"
+		}
+	}
+	if cfg.LongTerm != nil {
+		if err := cfg.LongTerm.AutoFix(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MemoryFactory 会话记忆存储构造方法
+type MemoryFactory func(cfg *AgentConfig) (IMemory, error)
+
+var (
+	memoryFactories = map[string]MemoryFactory{
+		MemoryType_Memory: func(cfg *AgentConfig) (IMemory, error) {
+			return newMemory(&cfg.AgentRuntimeCfg), nil
+		},
+		MemoryType_File: func(cfg *AgentConfig) (IMemory, error) {
+			return NewStoreMemory(newFileMemoryStore(filepath.Join(cfg.Memory.Path, cfg.Name)), &cfg.AgentRuntimeCfg), nil
+		},
+		MemoryType_Bolt: func(cfg *AgentConfig) (IMemory, error) {
+			store, err := newBoltMemoryStore(cfg.Memory.Path, cfg.Name)
+			if err != nil {
+				return nil, err
+			}
+			return NewStoreMemory(store, &cfg.AgentRuntimeCfg), nil
+		},
+		MemoryType_KV: func(cfg *AgentConfig) (IMemory, error) {
+			kv := getKVStore(cfg.Memory.Store)
+			if kv == nil {
+				return nil, fmt.Errorf("%w: kv store %s not registered", ErrConfiguration, cfg.Memory.Store)
+			}
+			return NewStoreMemory(newKVMemoryStore(kv, cfg.Memory.Prefix+cfg.Name+":"), &cfg.AgentRuntimeCfg), nil
+		},
+	}
+	memoryFactoriesLock sync.RWMutex
+)
+
+// RegisterMemory 注册自定义会话记忆存储类型，同名覆盖
+func RegisterMemory(typ string, factory MemoryFactory) {
+	memoryFactoriesLock.Lock()
+	defer memoryFactoriesLock.Unlock()
+	memoryFactories[typ] = factory
+}
+
+// newMemoryByConfig 按配置选择会话记忆存储，未配置时使用进程内存储，配置摘要时再装饰为摘要记忆
+func newMemoryByConfig(cfg *AgentConfig) (IMemory, error) {
+	typ := MemoryType_Memory
+	if cfg.Memory != nil {
+		typ = cfg.Memory.Type
+	}
+
+	memoryFactoriesLock.RLock()
+	factory, ok := memoryFactories[typ]
+	memoryFactoriesLock.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown memory type %s", ErrConfiguration, typ)
+	}
+
+	mem, err := factory(cfg)
+	if err != nil || cfg.Memory == nil || cfg.Memory.Summary == nil {
+		return mem, err
+	}
+	return newSummaryMemory(mem, cfg.Memory.Summary, cfg.LLM), nil
+}
+
+// IMemoryStore 会话记忆持久化存储，按会话整体读写
+type IMemoryStore interface {
+	// Load 读取会话消息记录，不存在时返回空
+	Load(sessionID string) ([]*Message, error)
+	// Save 覆盖写入会话消息记录，ttl为会话过期时间，支持过期的存储可直接使用
+	Save(sessionID string, msgs []*Message, ttl time.Duration) error
+	// Delete 删除会话消息记录
+	Delete(sessionID string) error
+	// DeleteAll 删除所有会话消息记录
+	DeleteAll() error
+	// List 列出所有会话ID
+	List() ([]string, error)
+}
+
+// storeMemory 基于IMemoryStore的会话记忆，过期及条数限制语义与进程内存储一致
+type storeMemory struct {
+	store   IMemoryStore
+	limit   int
+	timeout int64
+	lock    sync.Mutex // 仅保证进程内读改写的原子性
+
+	done      chan struct{} // 关闭时通知cronClean退出
+	closeOnce sync.Once
+}
+
+// NewStoreMemory 基于持久化存储创建会话记忆
+func NewStoreMemory(store IMemoryStore, cfg *AgentRuntimeCfg) IMemory {
+	ret := &storeMemory{
+		store:   store,
+		limit:   cfg.MaxStoreMemory,
+		timeout: cfg.MemoryTimeout,
+		done:    make(chan struct{}),
+	}
+
+	go ret.cronClean()
+	return ret
+}
+
+func (h *storeMemory) cronClean() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-h.done:
+			return
+		case <-ticker.C:
+		}
+
+		h.sweep()
+	}
+}
+
+// sweep 删除存储中已全部过期的会话，与进程内存储的定时清理一致
+func (h *storeMemory) sweep() {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	select {
+	case <-h.done: // 已关闭，存储可能已释放
+		return
+	default:
+	}
+
+	list, err := h.store.List()
+	if err != nil {
+		log.Printf("storeMemory::sweep failed => err:%v\n", err)
+		return
+	}
+	for _, sessionId := range list {
+		h.load(sessionId) // 全部过期时顺带删除
+	}
+}
+
+// load 读取未过期的消息记录，全部过期时顺带删除
+func (h *storeMemory) load(sessionId string) []*Message {
+	list, err := h.store.Load(sessionId)
+	if err != nil {
+		log.Printf("storeMemory::load failed => sessionID:%s, err:%v\n", sessionId, err)
+		return []*Message{}
+	}
+
+	now := time.Now().Unix()
+	ret := make([]*Message, 0, len(list))
+	for _, message := range list {
+		if now-message.CreateTime < h.timeout {
+			ret = append(ret, message)
+		}
+	}
+	if len(ret) == 0 && len(list) > 0 {
+		if err = h.store.Delete(sessionId); err != nil {
+			log.Printf("storeMemory::load delete expired failed => sessionID:%s, err:%v\n", sessionId, err)
+		}
+	}
+	return ret
+}
+
+func (h *storeMemory) Push(opts *RunOptions, msg ...*Message) {
+	now := time.Now().Unix()
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	sessionId := opts.GetSessionID()
+	for _, singleMsg := range msg {
+		if singleMsg.CreateTime == 0 {
+			singleMsg.CreateTime = now
+		}
+		if singleMsg.SessionID == "" {
+			singleMsg.SessionID = sessionId
+		}
+	}
+
+	list := append(h.load(sessionId), msg...)
+	list = list[memoryWindowStart(list, h.limit):]
+	if err := h.store.Save(sessionId, list, time.Duration(h.timeout)*time.Second); err != nil {
+		log.Printf("storeMemory::Push failed => sessionID:%s, err:%v\n", sessionId, err)
+	}
+}
+
+func (h *storeMemory) GetLatest(opts *RunOptions) []*Message {
+	h.lock.Lock()
+	target := h.load(opts.GetSessionID())
+	h.lock.Unlock()
+
+	return target[memoryWindowStart(target, opts.RuntimeCfg.MaxUseMemory):]
+}
+
+func (h *storeMemory) Clear(opts *RunOptions) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	sessionId := opts.GetSessionID()
+	if err := h.store.Delete(sessionId); err != nil {
+		log.Printf("storeMemory::Clear failed => sessionID:%s, err:%v\n", sessionId, err)
+	}
+}
+
+// Close 停止定时清理，存储实现io.Closer时关闭存储
+func (h *storeMemory) Close() error {
+	h.closeOnce.Do(func() {
+		close(h.done)
+	})
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if closer, ok := h.store.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+func (h *storeMemory) ClearAll() {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if err := h.store.DeleteAll(); err != nil {
+		log.Printf("storeMemory::ClearAll failed => err:%v\n", err)
+	}
+}
+
+func (h *storeMemory) ListSessions() []string {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	list, err := h.store.List()
+	if err != nil {
+		log.Printf("storeMemory::ListSessions failed => err:%v\n", err)
+		return []string{}
+	}
+
+	ret := make([]string, 0, len(list))
+	for _, sessionId := range list {
+		if len(h.load(sessionId)) > 0 { // 过滤已过期的会话
+			ret = append(ret, sessionId)
+		}
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+func (h *storeMemory) GetHistory(sessionID string) []*Message {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	return h.load(sessionID)
+}
+
+func (h *storeMemory) DeleteMessage(sessionID string, index int) error {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	list, err := deleteMemoryMessage(h.load(sessionID), index)
+	if err != nil {
+		return err
+	}
+	if len(list) == 0 {
+		return h.store.Delete(sessionID)
+	}
+	return h.store.Save(sessionID, list, time.Duration(h.timeout)*time.Second)
+}
+
+// memoryRecord 持久化的消息记录，Message序列化时不包含创建时间和会话ID
+type memoryRecord struct {
+	Message    *Message `json:"message"`
+	CreateTime int64    `json:"create_time"`
+	SessionID  string   `json:"session_id"`
+}
+
+func encodeMemoryRecords(msgs []*Message) ([]byte, error) {
+	records := make([]*memoryRecord, 0, len(msgs))
+	for _, msg := range msgs {
+		records = append(records, &memoryRecord{Message: msg, CreateTime: msg.CreateTime, SessionID: msg.SessionID})
+	}
+	return json.Marshal(records)
+}
+
+func decodeMemoryRecords(data []byte) ([]*Message, error) {
+	records := make([]*memoryRecord, 0)
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, err
+	}
+	return recordsToMessages(records), nil
+}
+
+func recordsToMessages(records []*memoryRecord) []*Message {
+	ret := make([]*Message, 0, len(records))
+	for _, record := range records {
+		if record.Message == nil {
+			continue
+		}
+		record.Message.CreateTime = record.CreateTime
+		record.Message.SessionID = record.SessionID
+		ret = append(ret, record.Message)
+	}
+	return ret
+}
+
+// fileMemoryStore 本地文件存储，每个会话一个JSONL文件，每行一条消息记录
+type fileMemoryStore struct {
+	dir string
+}
+
+func newFileMemoryStore(dir string) *fileMemoryStore {
+	return &fileMemoryStore{dir: dir}
+}
+
+func (s *fileMemoryStore) file(sessionID string) string {
+	return filepath.Join(s.dir, url.PathEscape(sessionID)+".jsonl")
+}
+
+func (s *fileMemoryStore) Load(sessionID string) ([]*Message, error) {
+	data, err := os.ReadFile(s.file(sessionID))
+	if os.IsNotExist(err) {
+		return []*Message{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]*memoryRecord, 0)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		record := &memoryRecord{}
+		if err = json.Unmarshal([]byte(line), record); err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+	return recordsToMessages(records), nil
+}
+
+func (s *fileMemoryStore) Save(sessionID string, msgs []*Message, ttl time.Duration) error {
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return err
+	}
+
+	buf := &bytes.Buffer{}
+	encoder := json.NewEncoder(buf)
+	for _, msg := range msgs {
+		if err := encoder.Encode(&memoryRecord{Message: msg, CreateTime: msg.CreateTime, SessionID: msg.SessionID}); err != nil {
+			return err
+		}
+	}
+
+	// 先写临时文件再重命名，避免写入中断导致文件损坏
+	tmp, err := os.CreateTemp(s.dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err = tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), s.file(sessionID))
+}
+
+func (s *fileMemoryStore) Delete(sessionID string) error {
+	if err := os.Remove(s.file(sessionID)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (s *fileMemoryStore) List() ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(s.dir, "*.jsonl"))
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]string, 0, len(files))
+	for _, file := range files {
+		sessionID, err := url.PathUnescape(strings.TrimSuffix(filepath.Base(file), ".jsonl"))
+		if err != nil {
+			continue
+		}
+		ret = append(ret, sessionID)
+	}
+	return ret, nil
+}
+
+func (s *fileMemoryStore) DeleteAll() error {
+	files, err := filepath.Glob(filepath.Join(s.dir, "*.jsonl"))
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		if err = os.Remove(file); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
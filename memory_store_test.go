@@ -0,0 +1,316 @@
+/*
+@Project: aihub
+@Module: aihub
+@File : memory_store_test.go
+*/
+package aihub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestMemoryOptions(sessionID string, maxUse int) *RunOptions {
+	opts := &RunOptions{RuntimeCfg: AgentRuntimeCfg{MaxUseMemory: maxUse}, Session: newSession(nil)}
+	WithSessionID(sessionID)(opts)
+	return opts
+}
+
+func Test_storeMemory(t *testing.T) {
+	RegisterKVStore("test-kv", NewMemKVStore())
+	dir := t.TempDir()
+
+	cases := []*MemoryConfig{
+		{Type: MemoryType_Memory},
+		{Type: MemoryType_File, Path: dir},
+		{Type: MemoryType_Bolt, Path: filepath.Join(dir, "memory.db")},
+		{Type: MemoryType_KV, Store: "test-kv"},
+	}
+	for _, memCfg := range cases {
+		t.Run(memCfg.Type, func(t *testing.T) {
+			cfg := &AgentConfig{
+				BriefInfo:       BriefInfo{Name: "test-memory"},
+				AgentRuntimeCfg: AgentRuntimeCfg{MaxStoreMemory: 3, MemoryTimeout: 600},
+				Memory:          memCfg,
+			}
+			if err := cfg.Memory.AutoFix(); err != nil {
+				t.Fatal(err)
+			}
+			mem, err := newMemoryByConfig(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			opts := newTestMemoryOptions("s/1", 2)
+			for _, content := range []string{"a", "b", "c", "d"} {
+				mem.Push(opts, &Message{Role: MessageRoleUser, Content: content})
+			}
+			mem.Push(newTestMemoryOptions("s2", 0), &Message{Role: MessageRoleUser, Content: "x"})
+
+			// MaxStoreMemory裁剪最早消息，MaxUseMemory取最近消息
+			latest := mem.GetLatest(opts)
+			if len(latest) != 2 || latest[0].Content != "c" || latest[1].Content != "d" {
+				t.Fatalf("unexpected latest => %v", latest)
+			}
+			if latest[0].CreateTime == 0 || latest[0].SessionID != "s/1" {
+				t.Fatalf("create time or session id lost => %+v", latest[0])
+			}
+			if all := mem.GetLatest(newTestMemoryOptions("s/1", 0)); len(all) != 3 {
+				t.Fatalf("want 3 stored, got %d", len(all))
+			}
+
+			mem.Clear(opts)
+			if len(mem.GetLatest(opts)) != 0 || len(mem.GetLatest(newTestMemoryOptions("s2", 0))) != 1 {
+				t.Fatal("clear session failed")
+			}
+			// 会话不存在时不清理其他会话
+			mem.Clear(newTestMemoryOptions("not-exist", 0))
+			if sessions := mem.ListSessions(); len(sessions) != 1 || sessions[0] != "s2" {
+				t.Fatalf("unexpected sessions => %v", sessions)
+			}
+
+			mem.Push(opts, &Message{Role: MessageRoleUser, Content: "e"}, &Message{Role: MessageRoleUser, Content: "f"})
+			if err = mem.DeleteMessage("s/1", 0); err != nil {
+				t.Fatal(err)
+			}
+			if history := mem.GetHistory("s/1"); len(history) != 1 || history[0].Content != "f" || history[0].SessionID != "s/1" {
+				t.Fatalf("unexpected history => %v", history)
+			}
+			if err = mem.DeleteMessage("s/1", 1); !errors.Is(err, ErrMemoryNotFound) {
+				t.Fatalf("want ErrMemoryNotFound, got %v", err)
+			}
+
+			mem.ClearAll()
+			if len(mem.ListSessions()) != 0 {
+				t.Fatal("clear all failed")
+			}
+		})
+	}
+}
+
+func Test_storeMemory_Persist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "memory.db")
+	store, err := newBoltMemoryStore(path, "test-persist")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	runtimeCfg := &AgentRuntimeCfg{MaxStoreMemory: 10, MemoryTimeout: 600}
+	opts := newTestMemoryOptions("s1", 0)
+	NewStoreMemory(store, runtimeCfg).Push(opts, &Message{Role: MessageRoleUser, Content: "hello"})
+
+	// 新实例读取已持久化的会话
+	if latest := NewStoreMemory(store, runtimeCfg).GetLatest(opts); len(latest) != 1 || latest[0].Content != "hello" {
+		t.Fatalf("unexpected latest => %v", latest)
+	}
+
+	// 过期消息不再返回
+	store.Save("s1", []*Message{{Role: MessageRoleUser, Content: "old", CreateTime: time.Now().Unix() - 601}}, 0)
+	if latest := NewStoreMemory(store, runtimeCfg).GetLatest(opts); len(latest) != 0 {
+		t.Fatalf("want expired, got %v", latest)
+	}
+}
+
+func Test_storeMemory_Sweep(t *testing.T) {
+	dir := t.TempDir()
+	boltStore, err := newBoltMemoryStore(filepath.Join(dir, "memory.db"), "test-sweep")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	runtimeCfg := &AgentRuntimeCfg{MaxStoreMemory: 10, MemoryTimeout: 600}
+	for _, store := range []IMemoryStore{newFileMemoryStore(filepath.Join(dir, "file")), boltStore} {
+		mem := NewStoreMemory(store, runtimeCfg).(*storeMemory)
+		mem.Push(newTestMemoryOptions("alive", 0), &Message{Role: MessageRoleUser, Content: "hello"})
+		store.Save("expired", []*Message{{Role: MessageRoleUser, Content: "old", CreateTime: time.Now().Unix() - 601}}, 0)
+
+		// 未读取的过期会话也会被清理
+		mem.sweep()
+		if list, err := store.List(); err != nil || len(list) != 1 || list[0] != "alive" {
+			t.Fatalf("unexpected sessions after sweep => %v, %v", list, err)
+		}
+
+		mem.Close()
+		select {
+		case <-mem.done:
+		default:
+			t.Fatal("want cronClean stopped after close")
+		}
+	}
+}
+
+func Test_summaryMemory(t *testing.T) {
+	requests := make([]string, 0)
+	newTestLLM(t, "test-summary-llm", func(req *CreateChatCompletionReq) *ChatCompletionRspChoice {
+		content := req.Messages[len(req.Messages)-1].Content
+		requests = append(requests, content)
+		return &ChatCompletionRspChoice{
+			Message:      &Message{Role: MessageRoleAssistant, Content: fmt.Sprintf("摘要%d", len(requests))},
+			FinishReason: ChatCompletionRspFinishReasonStop,
+		}
+	})
+
+	cfg := &AgentConfig{
+		BriefInfo:       BriefInfo{Name: "test-summary"},
+		AgentRuntimeCfg: AgentRuntimeCfg{LLM: "test-summary-llm", MaxStoreMemory: 5, MemoryTimeout: 600},
+		Memory:          &MemoryConfig{Summary: &MemorySummaryConfig{}},
+	}
+	if err := cfg.AutoFix(); err != nil {
+		t.Fatal(err)
+	}
+	mem, err := newMemoryByConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts := newTestMemoryOptions("s1", 1)
+	for _, content := range []string{"a", "b", "c", "d", "e"} {
+		mem.Push(opts, &Message{Role: MessageRoleUser, Content: content})
+	}
+	latest := mem.GetLatest(opts)
+	if len(latest) != 2 || latest[0].Name != MemorySummaryName || latest[0].Content != memorySummaryPrefix+"摘要1" || latest[1].Content != "e" {
+		t.Fatalf("unexpected latest => %v", latest)
+	}
+	if !strings.Contains(requests[0], "user：c") || strings.Contains(requests[0], "user：d") {
+		t.Fatalf("unexpected summarize input => %s", requests[0])
+	}
+
+	// 增量摘要：合并已有摘要与新的较早对话
+	for _, content := range []string{"f", "g", "h"} {
+		mem.Push(opts, &Message{Role: MessageRoleUser, Content: content})
+	}
+	if len(requests) != 2 || !strings.Contains(requests[1], "摘要1") || !strings.Contains(requests[1], "user：f") {
+		t.Fatalf("unexpected incremental summarize => %v", requests)
+	}
+	if all := mem.GetLatest(newTestMemoryOptions("s1", 0)); len(all) != 3 || all[0].Content != memorySummaryPrefix+"摘要2" {
+		t.Fatalf("unexpected history => %v", all)
+	}
+
+	steps := opts.GetSteps()
+	if len(steps) != 2 || steps[0].StepType != StepType_Summary || steps[0].State != RunState_Succeed {
+		t.Fatalf("unexpected summary steps => %v", steps)
+	}
+}
+
+func Test_summaryMemory_Concurrent(t *testing.T) {
+	newTestLLM(t, "test-summary-concurrent-llm", func(req *CreateChatCompletionReq) *ChatCompletionRspChoice {
+		time.Sleep(20 * time.Millisecond)
+		return &ChatCompletionRspChoice{
+			Message:      &Message{Role: MessageRoleAssistant, Content: "摘要"},
+			FinishReason: ChatCompletionRspFinishReasonStop,
+		}
+	})
+
+	cfg := &AgentConfig{
+		BriefInfo:       BriefInfo{Name: "test-summary-concurrent"},
+		AgentRuntimeCfg: AgentRuntimeCfg{LLM: "test-summary-concurrent-llm", MaxStoreMemory: 5, MemoryTimeout: 600},
+		Memory:          &MemoryConfig{Summary: &MemorySummaryConfig{}},
+	}
+	if err := cfg.AutoFix(); err != nil {
+		t.Fatal(err)
+	}
+	mem, err := newMemoryByConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 摘要替换会话记录期间，并发读取不会看到空记录
+	opts := newTestMemoryOptions("s1", 0)
+	mem.Push(opts, &Message{Role: MessageRoleUser, Content: "a"})
+	stop := make(chan struct{})
+	empty := make(chan struct{}, 1)
+	go func() {
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			if len(mem.GetLatest(newTestMemoryOptions("s1", 0))) == 0 {
+				empty <- struct{}{}
+				return
+			}
+		}
+	}()
+	for _, content := range []string{"b", "c", "d", "e", "f", "g", "h"} {
+		mem.Push(opts, &Message{Role: MessageRoleUser, Content: content})
+	}
+	close(stop)
+	select {
+	case <-empty:
+		t.Fatal("empty history during summarize")
+	default:
+	}
+
+	// 摘要使用本次运行的ctx，计入调用树预算
+	ctx, err := enterCallChain(context.Background(), "test-summary-concurrent", &AgentRuntimeCfg{MaxTreeSteps: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	callChainFromContext(ctx).budget.acquireStep()
+	opts = newTestMemoryOptions("s2", 0)
+	opts.ctx = ctx
+	for _, content := range []string{"a", "b", "c", "d", "e"} {
+		mem.Push(opts, &Message{Role: MessageRoleUser, Content: content})
+	}
+	if steps := opts.GetSteps(); len(steps) != 1 || !strings.Contains(steps[0].Result, ErrCallBudgetExceeded.Error()) {
+		t.Fatalf("want budget exceeded summary step => %+v", steps)
+	}
+}
+
+func Test_summaryMemory_PushOtherSession(t *testing.T) {
+	release := make(chan struct{})
+	newTestLLM(t, "test-summary-blocked-llm", func(req *CreateChatCompletionReq) *ChatCompletionRspChoice {
+		<-release
+		return &ChatCompletionRspChoice{
+			Message:      &Message{Role: MessageRoleAssistant, Content: "摘要"},
+			FinishReason: ChatCompletionRspFinishReasonStop,
+		}
+	})
+
+	cfg := &AgentConfig{
+		BriefInfo:       BriefInfo{Name: "test-summary-blocked"},
+		AgentRuntimeCfg: AgentRuntimeCfg{LLM: "test-summary-blocked-llm", MaxStoreMemory: 5, MemoryTimeout: 600},
+		Memory:          &MemoryConfig{Summary: &MemorySummaryConfig{}},
+	}
+	if err := cfg.AutoFix(); err != nil {
+		t.Fatal(err)
+	}
+	mem, err := newMemoryByConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 会话s1摘要期间，会话s2的Push不被阻塞
+	opts := newTestMemoryOptions("s1", 0)
+	for _, content := range []string{"a", "b", "c", "d"} {
+		mem.Push(opts, &Message{Role: MessageRoleUser, Content: content})
+	}
+	summarized := make(chan struct{})
+	go func() {
+		mem.Push(opts, &Message{Role: MessageRoleUser, Content: "e"})
+		close(summarized)
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	pushed := make(chan struct{})
+	go func() {
+		mem.Push(newTestMemoryOptions("s2", 0), &Message{Role: MessageRoleUser, Content: "a"})
+		close(pushed)
+	}()
+	select {
+	case <-pushed:
+	case <-time.After(time.Second):
+		t.Fatal("push blocked by another session's summarize")
+	}
+	close(release)
+	<-summarized
+	if len(mem.(*summaryMemory).pushLocks) != 0 {
+		t.Fatal("session push locks leaked")
+	}
+}
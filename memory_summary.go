@@ -0,0 +1,248 @@
+/*
+@Project: aihub
+@Module: aihub
+@File : memory_summary.go
+*/
+package aihub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemorySummaryName 摘要消息名称，摘要以该名称的系统消息置顶于会话记忆中
+const MemorySummaryName = "memory_summary"
+
+const memorySummaryPrefix = "以下是早期对话的摘要：\n"
+
+const memorySummaryPrompt = `你是一个对话记忆整理助手，负责将较早的对话内容压缩为简洁的摘要，供后续对话参考。
+## 要求：
+1. 保留用户身份、偏好、关键事实、已做出的决定及待办事项
+2. 如有已有摘要，将新的对话内容合并进已有摘要，去除过时或重复的信息
+3. 只输出摘要正文，不超过500字`
+
+// MemorySummaryConfig 摘要记忆配置：会话历史超过阈值时，由LLM将较早的对话压缩为摘要，置顶于最近消息之前
+type MemorySummaryConfig struct {
+	LLM       string `json:"llm,omitempty" yaml:"llm,omitempty"`             // 可选，摘要使用的LLM名称，默认与agent相同
+	Threshold int    `json:"threshold,omitempty" yaml:"threshold,omitempty"` // 触发摘要的历史消息条数，默认且最大为MaxStoreMemory-1
+	Keep      int    `json:"keep,omitempty" yaml:"keep,omitempty"`           // 摘要后保留的最近消息条数，默认Threshold的一半
+	Prompt    string `json:"prompt,omitempty" yaml:"prompt,omitempty"`       // 可选，摘要系统提示词，覆盖默认提示词
+	Timeout   int64  `json:"timeout,omitempty" yaml:"timeout,omitempty"`     // 摘要LLM调用超时秒数，默认60
+}
+
+func (cfg *MemorySummaryConfig) AutoFix(maxStoreMemory int) error {
+	if maxStoreMemory < 3 {
+		return fmt.Errorf("%w: memory summary needs max_store_memory >= 3", ErrConfiguration)
+	}
+	if cfg.Threshold <= 1 || cfg.Threshold > maxStoreMemory-1 {
+		cfg.Threshold = maxStoreMemory - 1 // 预留摘要消息位置，避免存储按条数裁剪
+	}
+	if cfg.Keep <= 0 || cfg.Keep >= cfg.Threshold {
+		cfg.Keep = cfg.Threshold / 2
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 60
+	}
+	return nil
+}
+
+// summaryMemory 摘要记忆，装饰任意会话记忆存储，摘要消息随会话一起持久化
+type summaryMemory struct {
+	IMemory
+	cfg      *MemorySummaryConfig
+	agentLLM string
+
+	pushLocks     map[string]*sessionPushLock // sessionID => 锁，按会话串行化Push，摘要期间不阻塞其他会话及读取
+	pushLocksLock sync.Mutex
+	lock          sync.RWMutex // 保护摘要替换会话记录的过程，读取时不会看到清空后的中间状态
+}
+
+// sessionPushLock 会话级Push锁，无等待者时从map中移除
+type sessionPushLock struct {
+	sync.Mutex
+	refs int
+}
+
+func newSummaryMemory(base IMemory, cfg *MemorySummaryConfig, agentLLM string) IMemory {
+	return &summaryMemory{
+		IMemory:   base,
+		cfg:       cfg,
+		agentLLM:  agentLLM,
+		pushLocks: make(map[string]*sessionPushLock),
+	}
+}
+
+// lockSession 获取会话级Push锁，返回解锁函数
+func (h *summaryMemory) lockSession(sessionID string) func() {
+	h.pushLocksLock.Lock()
+	tmp, ok := h.pushLocks[sessionID]
+	if !ok {
+		tmp = &sessionPushLock{}
+		h.pushLocks[sessionID] = tmp
+	}
+	tmp.refs++
+	h.pushLocksLock.Unlock()
+
+	tmp.Lock()
+	return func() {
+		tmp.Unlock()
+		h.pushLocksLock.Lock()
+		defer h.pushLocksLock.Unlock()
+		if tmp.refs--; tmp.refs == 0 {
+			delete(h.pushLocks, sessionID)
+		}
+	}
+}
+
+// loadAll 读取会话全部记录，拆分出摘要消息
+func (h *summaryMemory) loadAll(opts *RunOptions) (summary *Message, history []*Message) {
+	history = h.IMemory.GetLatest(&RunOptions{Session: opts.Session}) // 不限制条数
+	if len(history) > 0 && isMemorySummary(history[0]) {
+		return history[0], history[1:]
+	}
+	return nil, history
+}
+
+func (h *summaryMemory) Push(opts *RunOptions, msg ...*Message) {
+	unlock := h.lockSession(opts.GetSessionID())
+	defer unlock()
+
+	h.lock.RLock()
+	summary, history := h.loadAll(opts)
+	h.lock.RUnlock()
+
+	list := append(append(make([]*Message, 0, len(history)+len(msg)), history...), msg...)
+	split := memoryWindowStart(list, h.cfg.Keep) // 在工具调用组边界处切分
+	if len(list) <= h.cfg.Threshold || split == 0 {
+		h.push(opts, msg...)
+		return
+	}
+	newSummary, err := h.summarize(opts, summary, list[:split])
+	if err != nil {
+		// 摘要失败时退化为按条数裁剪
+		log.Printf("summaryMemory::Push summarize failed => sessionID:%s, err:%v\n", opts.GetSessionID(), err)
+		h.push(opts, msg...)
+		return
+	}
+
+	// 清空与写回在同一把锁内完成
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	allOpts := &RunOptions{Session: opts.Session}
+	h.IMemory.Clear(allOpts)
+	h.IMemory.Push(allOpts, append([]*Message{newSummary}, list[split:]...)...)
+}
+
+func (h *summaryMemory) push(opts *RunOptions, msg ...*Message) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.IMemory.Push(opts, msg...)
+}
+
+func (h *summaryMemory) GetHistory(sessionID string) []*Message {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+	return h.IMemory.GetHistory(sessionID)
+}
+
+func (h *summaryMemory) GetLatest(opts *RunOptions) []*Message {
+	h.lock.RLock()
+	summary, history := h.loadAll(opts)
+	h.lock.RUnlock()
+
+	idx := memoryWindowStart(history, opts.RuntimeCfg.MaxUseMemory)
+	if summary == nil {
+		return history[idx:]
+	}
+	return append([]*Message{summary}, history[idx:]...) // 摘要置顶，不计入MaxUseMemory
+}
+
+// summarize 将已有摘要与较早的对话合并为新摘要，过程记录为SUMMARY步骤
+func (h *summaryMemory) summarize(opts *RunOptions, summary *Message, msgs []*Message) (ret *Message, err error) {
+	llmName := h.cfg.LLM
+	if llmName == "" {
+		llmName = h.agentLLM
+	}
+	step := &RunStep{
+		Action:   llmName,
+		Think:    fmt.Sprintf("压缩%d条较早的会话记录", len(msgs)),
+		StepType: StepType_Summary,
+		State:    RunState_Running,
+	}
+	defer func() {
+		if err != nil {
+			step.State = RunState_Failed
+			step.Result = err.Error()
+		} else {
+			step.State = RunState_Succeed
+			step.Result = ret.Content
+		}
+		opts.AddStep(step)
+	}()
+
+	LLMIns := GetLLMHub().GetLLM(llmName)
+	if LLMIns == nil {
+		return nil, fmt.Errorf("%w: llm %s", ErrConfiguration, llmName)
+	}
+
+	prompt := h.cfg.Prompt
+	if prompt == "" {
+		prompt = memorySummaryPrompt
+	}
+	content := "## 新的对话内容\n" + formatMemoryMessages(msgs)
+	if summary != nil {
+		content = "## 已有摘要\n" + strings.TrimPrefix(summary.Content, memorySummaryPrefix) + "\n\n" + content
+	}
+	step.Question = content
+
+	ctx, cancel := context.WithTimeout(opts.getContext(), time.Duration(h.cfg.Timeout)*time.Second)
+	defer cancel()
+	rsp, err := createChatCompletion(ctx, LLMIns, &CreateChatCompletionReq{
+		Messages: []*Message{
+			{Role: MessageRoleSystem, Content: prompt},
+			{Role: MessageRoleUser, Content: content},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if rsp.Error != nil {
+		return nil, errors.New(rsp.Error.Message)
+	}
+	if len(rsp.Choices) == 0 || rsp.Choices[0].Message == nil || rsp.Choices[0].Message.Content == "" {
+		return nil, ErrToolCallResponseEmpty
+	}
+
+	return &Message{
+		Role:    MessageRoleSystem,
+		Name:    MemorySummaryName,
+		Content: memorySummaryPrefix + strings.TrimSpace(rsp.Choices[0].Message.Content),
+	}, nil
+}
+
+func isMemorySummary(msg *Message) bool {
+	return msg.Role == MessageRoleSystem && msg.Name == MemorySummaryName
+}
+
+// formatMemoryMessages 将会话记录转换为摘要输入文本
+func formatMemoryMessages(msgs []*Message) string {
+	builder := strings.Builder{}
+	for _, msg := range msgs {
+		builder.WriteString(string(msg.Role))
+		if msg.Name != "" {
+			builder.WriteString("(" + msg.Name + ")")
+		}
+		builder.WriteString("：")
+		builder.WriteString(msg.Content)
+		for _, toolCall := range msg.ToolCalls {
+			builder.WriteString(fmt.Sprintf("\n调用工具 %s(%s)", toolCall.Function.Name, toolCall.Function.Arguments))
+		}
+		builder.WriteString("\n")
+	}
+	return builder.String()
+}
@@ -0,0 +1,123 @@
+/*
+@Project: aihub
+@Module: aihub
+@File : memory_test.go
+*/
+package aihub
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func newTestToolCallMessages(id string) []*Message {
+	toolCall := &MessageToolCall{Id: id, Type: ToolTypeFunction}
+	toolCall.Function.Name = "test"
+	return []*Message{
+		{Role: MessageRoleAssistant, ToolCalls: []*MessageToolCall{toolCall, toolCall}},
+		{Role: MessageRoleTool, ToolCallID: id, Content: "r1"},
+		{Role: MessageRoleTool, ToolCallID: id, Content: "r2"},
+	}
+}
+
+func Test_memoryWindowStart(t *testing.T) {
+	msgs := append([]*Message{{Role: MessageRoleUser, Content: "q"}}, newTestToolCallMessages("call_1")...)
+	msgs = append(msgs, &Message{Role: MessageRoleAssistant, Content: "a"})
+
+	tests := []struct {
+		limit int
+		want  int
+	}{
+		{0, 0},
+		{5, 0},
+		{4, 1},
+		{3, 4}, // 不从tool消息开始
+		{1, 4},
+	}
+	for _, tt := range tests {
+		if got := memoryWindowStart(msgs, tt.limit); got != tt.want {
+			t.Errorf("memoryWindowStart(limit=%d) = %d, want %d", tt.limit, got, tt.want)
+		}
+	}
+
+	// 最后一组超过limit时完整保留
+	if got := memoryWindowStart(msgs[:4], 2); got != 1 {
+		t.Errorf("want keep last tool call group, got %d", got)
+	}
+	// 全部为孤立的tool消息
+	if got := memoryWindowStart(msgs[2:4], 0); got != 2 {
+		t.Errorf("want skip orphan tool messages, got %d", got)
+	}
+}
+
+func Test_memory_PushToolCalls(t *testing.T) {
+	mem := newMemory(&AgentRuntimeCfg{MaxStoreMemory: 3, MemoryTimeout: 600})
+	opts := newTestMemoryOptions("s1", 0)
+
+	mem.Push(opts, &Message{Role: MessageRoleUser, Content: "q"})
+	mem.Push(opts, newTestToolCallMessages("call_1")...)
+	mem.Push(opts, &Message{Role: MessageRoleAssistant, Content: "a"})
+
+	// 淘汰时整组移除工具调用，不留下孤立的tool消息
+	latest := mem.GetLatest(opts)
+	if len(latest) != 1 || latest[0].Content != "a" {
+		t.Fatalf("unexpected latest => %v", latest)
+	}
+
+	mem.Push(opts, newTestToolCallMessages("call_2")...)
+	opts.RuntimeCfg.MaxUseMemory = 2
+	latest = mem.GetLatest(opts)
+	if len(latest) != 3 || latest[0].Role != MessageRoleAssistant || len(latest[0].ToolCalls) != 2 {
+		t.Fatalf("unexpected window => %v", latest)
+	}
+}
+
+func Test_deleteMemoryMessage(t *testing.T) {
+	msgs := append([]*Message{{Role: MessageRoleUser, Content: "q"}}, newTestToolCallMessages("call_1")...)
+	msgs = append(msgs, &Message{Role: MessageRoleAssistant, Content: "a"})
+
+	// 删除工具结果时整组删除
+	ret, err := deleteMemoryMessage(msgs, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ret) != 2 || ret[0].Content != "q" || ret[1].Content != "a" {
+		t.Fatalf("unexpected messages => %v", ret)
+	}
+
+	if ret, err = deleteMemoryMessage(msgs, 4); err != nil || len(ret) != 4 {
+		t.Fatalf("unexpected delete => %v, %v", ret, err)
+	}
+}
+
+func Test_ExportImportMemory(t *testing.T) {
+	src := newMemory(&AgentRuntimeCfg{MaxStoreMemory: 10, MemoryTimeout: 600})
+	src.Push(newTestMemoryOptions("s1", 0), &Message{Role: MessageRoleUser, Content: "你好"})
+	src.Push(newTestMemoryOptions("s1", 0), newTestToolCallMessages("call_1")...)
+	src.Push(newTestMemoryOptions("s2", 0), &Message{Role: MessageRoleUser, Content: "hi"})
+
+	buf := &bytes.Buffer{}
+	if err := ExportMemory(src, buf); err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimSpace(buf.String()), "\n"); len(lines) != 2 || !strings.HasPrefix(lines[0], `{"session_id":"s1","messages":[{"content":"你好","role":"user"}`) {
+		t.Fatalf("unexpected export => %s", buf.String())
+	}
+
+	dst := newMemory(&AgentRuntimeCfg{MaxStoreMemory: 10, MemoryTimeout: 600})
+	dst.Push(newTestMemoryOptions("s1", 0), &Message{Role: MessageRoleUser, Content: "旧记录"})
+	cnt, err := ImportMemory(dst, buf)
+	if err != nil || cnt != 2 {
+		t.Fatalf("unexpected import => %d, %v", cnt, err)
+	}
+	history := dst.GetHistory("s1")
+	if len(history) != 4 || history[0].Content != "你好" || history[1].ToolCalls[0].Id != "call_1" || history[3].ToolCallID != "call_1" || history[0].CreateTime == 0 {
+		t.Fatalf("unexpected history => %v", history)
+	}
+
+	if _, err = ImportMemory(dst, strings.NewReader(`{"messages":[]}`)); !errors.Is(err, ErrMemoryImportInvalid) {
+		t.Fatalf("want ErrMemoryImportInvalid, got %v", err)
+	}
+}
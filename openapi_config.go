@@ -1,6 +1,9 @@
 package aihub
 
-import "fmt"
+import (
+	"fmt"
+	"github.com/mvptianyu/aihub/jsonschema"
+)
 
 // OPENAPIInfo 定义 OPENAPIConfig 规范中的 info 部分
 type OPENAPIInfo struct {
@@ -17,7 +20,8 @@ type OPENAPIRequestBody struct {
 
 // OPENAPIResponse 定义 OPENAPIConfig 规范中的 response 部分
 type OPENAPIResponse struct {
-	Description string `json:"description"`
+	Description string                 `json:"description"`
+	Content     map[string]interface{} `json:"content,omitempty"`
 }
 
 // OPENAPIServer 定义 OPENAPIConfig 规范中的 servers 部分
@@ -79,6 +83,20 @@ func (cfg *OPENAPIConfig) AddToolFunction(toolFunctions []ToolFunction, server s
 				},
 			},
 		}
+		if toolFunction.OutputSchema != nil {
+			contentType := "application/json"
+			if toolFunction.OutputSchema.Type == jsonschema.String {
+				contentType = "text/plain"
+			}
+			operation.Responses["200"] = OPENAPIResponse{
+				Description: "Successful response",
+				Content: map[string]interface{}{
+					contentType: map[string]interface{}{
+						"schema": toolFunction.OutputSchema,
+					},
+				},
+			}
+		}
 		if server != "" {
 			operation.Servers = []OPENAPIServer{
 				{
@@ -1,8 +1,10 @@
 package aihub
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"log"
 	"strings"
 	"sync"
 	"time"
@@ -10,13 +12,31 @@ import (
 
 type RunOptions struct {
 	*Session
+	RunID      string          // 本次运行ID，可用于状态查询和取消
 	RuntimeCfg AgentRuntimeCfg // 运行时配置
 	Tools      []BriefInfo     // 用到的关联tool定义
 	Agents     []BriefInfo     // 用到的关联Agent定义
 	Context    interface{}     // 可选，上下文信息，例如知识库等
 
-	steps []*RunStep
-	lock  sync.RWMutex
+	PromptVars      map[string]interface{} // 可选，系统提示词模板自定义变量
+	promptFragments map[string]string      // 系统提示词片段
+	toolNames       []string               // 本次指定的工具集，nil表示使用agent配置
+	toolFunctions   []ToolFunction         // 本次可用的工具定义
+
+	plan    *Plan        // Manus任务计划
+	recalls []*VectorHit // 长期记忆检索结果
+
+	knowledge []*KnowledgeHit // inject模式注入的知识库检索结果
+	citations []*KnowledgeHit // 本次运行引用的知识库内容，按引用编号排列
+
+	sessionOverrides map[string]interface{} // WithSessionData显式设置的会话数据，加载已保存会话后覆盖
+	sessionIDSet     bool                   // 是否由WithSessionID显式指定会话ID，仅显式指定时读写会话数据存储
+
+	ctx context.Context // 本次运行的ctx，供记忆摘要等不带ctx参数的内部调用使用
+
+	steps       []*RunStep
+	toolRepairs map[string]int // toolName => 当前调用的入参修正次数
+	lock        sync.RWMutex
 }
 
 type RunStep struct {
@@ -61,13 +81,6 @@ func (r *RunStep) MergeWith(src *RunStep) {
 	}
 }
 
-const (
-	defaultPromptReplaceContext = "{{context}}"
-	defaultPromptReplaceTools   = "{{tools}}"
-	defaultPromptReplaceSession = "{{session}}"
-	defaultPromptReplaceAgents  = "{{agents}}"
-)
-
 // RunState 表示当前状态
 type RunState int
 
@@ -77,6 +90,7 @@ const (
 	RunState_Succeed
 	RunState_Failed
 	RunState_Error
+	RunState_Canceled
 )
 
 // String 返回状态的字符串表示
@@ -92,6 +106,8 @@ func (s RunState) String() string {
 		return "failed"
 	case RunState_Error:
 		return "error"
+	case RunState_Canceled:
+		return "canceled"
 	default:
 		return "unknown"
 	}
@@ -106,6 +122,10 @@ const (
 	StepType_End
 	StepType_Tool
 	StepType_Agent
+	StepType_LLM
+	StepType_Plan
+	StepType_Critique
+	StepType_Summary
 )
 
 // String 返回状态的字符串表示
@@ -119,6 +139,14 @@ func (s StepType) String() string {
 		return "TOOLCALL"
 	case StepType_Agent:
 		return "AGENTCALL"
+	case StepType_LLM:
+		return "LLMCALL"
+	case StepType_Plan:
+		return "PLAN"
+	case StepType_Critique:
+		return "CRITIQUE"
+	case StepType_Summary:
+		return "SUMMARY"
 	default:
 		return "UNKNOWN"
 	}
@@ -162,24 +190,18 @@ const prettyClaimTpl = `
 '''
 `
 
+// UpdateSystemPrompt 以text/template渲染系统提示词，数据模型见PromptData
 func (opts *RunOptions) UpdateSystemPrompt(content string) string {
-	if opts != nil && opts.Context != nil {
-		contentBS, _ := json.Marshal(opts.Context)
-		content = strings.Replace(content, defaultPromptReplaceContext, string(contentBS), -1)
+	r := &promptRenderer{
+		data:      newPromptData(opts),
+		fragments: opts.promptFragments,
 	}
-	if opts.SessionData != nil && len(opts.SessionData) > 0 {
-		toolsBS, _ := json.Marshal(opts.SessionData)
-		content = strings.Replace(content, defaultPromptReplaceSession, string(toolsBS), -1)
+	ret, err := r.Render(content)
+	if err != nil {
+		log.Printf("RunOptions::UpdateSystemPrompt failed => runID:%s, err:%v\n", opts.RunID, err)
+		return content
 	}
-	if opts.Tools != nil && len(opts.Tools) > 0 {
-		toolsBS, _ := json.Marshal(opts.Tools)
-		content = strings.Replace(content, defaultPromptReplaceTools, string(toolsBS), -1)
-	}
-	if opts.Agents != nil && len(opts.Agents) > 0 {
-		agentsBS, _ := json.Marshal(opts.Agents)
-		content = strings.Replace(content, defaultPromptReplaceAgents, string(agentsBS), -1)
-	}
-	return content
+	return ret
 }
 
 func (opts *RunOptions) CheckStepQuit() bool {
@@ -221,6 +243,148 @@ func (opts *RunOptions) AddStep(src *RunStep) {
 	opts.steps = append(opts.steps, src)
 }
 
+// addToolRepair 累加工具当前调用的入参修正次数，返回剩余可修正次数
+func (opts *RunOptions) addToolRepair(name string) int {
+	opts.lock.Lock()
+	defer opts.lock.Unlock()
+
+	if opts.toolRepairs == nil {
+		opts.toolRepairs = make(map[string]int)
+	}
+	opts.toolRepairs[name]++
+	return opts.RuntimeCfg.MaxToolRepair - opts.toolRepairs[name]
+}
+
+// resetToolRepair 工具调用入参校验通过，结束该次调用的修正计数
+func (opts *RunOptions) resetToolRepair(name string) {
+	opts.lock.Lock()
+	defer opts.lock.Unlock()
+
+	delete(opts.toolRepairs, name)
+}
+
+// checkToolAllowed 检查工具是否在本次可用工具集中
+func (opts *RunOptions) checkToolAllowed(name string) error {
+	for _, item := range opts.toolFunctions {
+		if item.Name == name {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s", ErrCallNameNotMatch, name)
+}
+
+// GetPlan 获取当前任务计划
+func (opts *RunOptions) GetPlan() *Plan {
+	opts.lock.RLock()
+	defer opts.lock.RUnlock()
+	return opts.plan
+}
+
+func (opts *RunOptions) setPlan(plan *Plan) {
+	opts.lock.Lock()
+	defer opts.lock.Unlock()
+	opts.plan = plan
+}
+
+// GetRecalls 获取本次运行检索到的长期记忆
+func (opts *RunOptions) GetRecalls() []*VectorHit {
+	opts.lock.RLock()
+	defer opts.lock.RUnlock()
+	return opts.recalls
+}
+
+func (opts *RunOptions) setRecalls(recalls []*VectorHit) {
+	opts.lock.Lock()
+	defer opts.lock.Unlock()
+	opts.recalls = recalls
+}
+
+// GetKnowledge 获取本次运行注入系统提示词的知识库内容
+func (opts *RunOptions) GetKnowledge() []*KnowledgeHit {
+	opts.lock.RLock()
+	defer opts.lock.RUnlock()
+	return opts.knowledge
+}
+
+func (opts *RunOptions) setKnowledge(hits []*KnowledgeHit) {
+	opts.lock.Lock()
+	defer opts.lock.Unlock()
+	opts.knowledge = hits
+}
+
+// GetCitations 获取本次运行检索到的引用来源
+func (opts *RunOptions) GetCitations() []*KnowledgeHit {
+	opts.lock.RLock()
+	defer opts.lock.RUnlock()
+
+	ret := make([]*KnowledgeHit, len(opts.citations))
+	copy(ret, opts.citations)
+	return ret
+}
+
+// getContext 获取本次运行的ctx，不在运行中时返回context.Background()
+func (opts *RunOptions) getContext() context.Context {
+	if opts.ctx == nil {
+		return context.Background()
+	}
+	return opts.ctx
+}
+
+// addCitations 登记引用来源并分配编号，同一分块多次检索复用已有编号
+func (opts *RunOptions) addCitations(hits []*KnowledgeHit) []*KnowledgeHit {
+	opts.lock.Lock()
+	defer opts.lock.Unlock()
+
+	ret := make([]*KnowledgeHit, 0, len(hits))
+	for _, hit := range hits {
+		var exist *KnowledgeHit
+		for _, item := range opts.citations {
+			if item.Base == hit.Base && item.ChunkID == hit.ChunkID {
+				exist = item
+				break
+			}
+		}
+		if exist == nil {
+			exist = hit
+			exist.Index = len(opts.citations) + 1
+			opts.citations = append(opts.citations, exist)
+		}
+		ret = append(ret, exist)
+	}
+	return ret
+}
+
+// updateStep 在锁内更新已登记步骤的结果及状态，避免与状态查询并发读写
+func (opts *RunOptions) updateStep(step *RunStep, result string, state RunState) {
+	opts.lock.Lock()
+	defer opts.lock.Unlock()
+	step.Result = result
+	step.State = state
+}
+
+// lastStep 获取步骤数及最新步骤的拷贝
+func (opts *RunOptions) lastStep() (int, *RunStep) {
+	opts.lock.RLock()
+	defer opts.lock.RUnlock()
+
+	cnt := len(opts.steps)
+	if cnt == 0 {
+		return 0, nil
+	}
+	tmp := *opts.steps[cnt-1]
+	return cnt, &tmp
+}
+
+// GetSteps 获取当前已执行步骤列表
+func (opts *RunOptions) GetSteps() []*RunStep {
+	opts.lock.RLock()
+	defer opts.lock.RUnlock()
+
+	ret := make([]*RunStep, len(opts.steps))
+	copy(ret, opts.steps)
+	return ret
+}
+
 func (opts *RunOptions) RenderFinalAnswer() string {
 	opts.lock.RLock()
 	defer opts.lock.RUnlock()
@@ -281,6 +445,39 @@ func WithRuntimeCfg(runtimeCfg AgentRuntimeCfg) RunOptionFunc {
 	}
 }
 
+// WithLLM 本次运行使用指定名称的LLM
+func WithLLM(name string) RunOptionFunc {
+	return func(opts *RunOptions) {
+		opts.RuntimeCfg.LLM = name
+	}
+}
+
+func WithTemperature(temperature float64) RunOptionFunc {
+	return func(opts *RunOptions) {
+		opts.RuntimeCfg.Temperature = temperature
+	}
+}
+
+func WithMaxTokens(maxTokens int) RunOptionFunc {
+	return func(opts *RunOptions) {
+		opts.RuntimeCfg.MaxTokens = maxTokens
+	}
+}
+
+func WithMaxSteps(maxSteps int) RunOptionFunc {
+	return func(opts *RunOptions) {
+		opts.RuntimeCfg.MaxStepQuit = maxSteps
+	}
+}
+
+// WithTools 本次运行使用指定的工具集，可缩减或扩充agent配置的工具，不传则禁用所有工具
+func WithTools(names ...string) RunOptionFunc {
+	return func(opts *RunOptions) {
+		opts.toolNames = make([]string, 0)
+		opts.toolNames = append(opts.toolNames, names...)
+	}
+}
+
 func WithDebug(debug bool) RunOptionFunc {
 	return func(opts *RunOptions) {
 		opts.RuntimeCfg.Debug = debug
@@ -299,11 +496,29 @@ func WithContext(context interface{}) RunOptionFunc {
 	}
 }
 
+func WithRunID(runID string) RunOptionFunc {
+	return func(opts *RunOptions) {
+		opts.RunID = runID
+	}
+}
+
+func WithPromptVars(vars map[string]interface{}) RunOptionFunc {
+	return func(opts *RunOptions) {
+		if opts.PromptVars == nil {
+			opts.PromptVars = make(map[string]interface{})
+		}
+		for key, value := range vars {
+			opts.PromptVars[key] = value
+		}
+	}
+}
+
 func WithSessionID(sessionID string) RunOptionFunc {
 	return func(opts *RunOptions) {
 		if opts.Session != nil {
 			opts.Session.SessionID = sessionID
 		}
+		opts.sessionIDSet = sessionID != ""
 	}
 }
 
@@ -312,6 +527,12 @@ func WithSessionData(sessionData map[string]interface{}) RunOptionFunc {
 		if opts.Session != nil {
 			opts.Session.MergeSessionData(sessionData)
 		}
+		if opts.sessionOverrides == nil {
+			opts.sessionOverrides = make(map[string]interface{})
+		}
+		for key, value := range sessionData {
+			opts.sessionOverrides[key] = value
+		}
 	}
 }
 
@@ -0,0 +1,158 @@
+/*
+@Project: aihub
+@Module: aihub
+@File : plan.go
+*/
+package aihub
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Plan Manus任务计划
+type Plan struct {
+	Goal    string      `json:"goal" yaml:"goal"`                         // 用户需求目标
+	Tasks   []*PlanTask `json:"tasks" yaml:"tasks"`                       // 任务列表
+	Answer  string      `json:"answer,omitempty" yaml:"answer,omitempty"` // 无需拆解任务时的直接回复
+	Version int         `json:"version" yaml:"version"`                   // 计划版本，每次重新规划后递增
+}
+
+// PlanTask 计划中的单个任务
+type PlanTask struct {
+	ID        string   `json:"id" yaml:"id"`                                     // 任务ID，计划内唯一
+	Title     string   `json:"title" yaml:"title"`                               // 任务概述
+	Agent     string   `json:"agent,omitempty" yaml:"agent,omitempty"`           // 执行该任务的agent名称，为空时由Manus自身完成
+	Question  string   `json:"question" yaml:"question"`                         // 调用agent的输入提示词
+	DependsOn []string `json:"depends_on,omitempty" yaml:"depends_on,omitempty"` // 依赖的任务ID
+	State     RunState `json:"state" yaml:"state"`                               // 任务状态
+	Result    string   `json:"result,omitempty" yaml:"result,omitempty"`         // 任务执行结果或失败原因
+}
+
+// parsePlan 解析模型输出的计划JSON并校验
+func parsePlan(content string) (*Plan, error) {
+	plan := &Plan{}
+	if err := json.Unmarshal([]byte(TrimJSONCodeBlock(content)), plan); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrPlanInvalid, err)
+	}
+	if err := plan.Check(); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// Check 校验任务ID唯一、依赖存在且无循环依赖
+func (p *Plan) Check() error {
+	if len(p.Tasks) == 0 && p.Answer == "" {
+		return fmt.Errorf("%w: tasks and answer both empty", ErrPlanInvalid)
+	}
+
+	ids := make(map[string]bool)
+	for _, task := range p.Tasks {
+		if task.ID == "" || ids[task.ID] {
+			return fmt.Errorf("%w: task id empty or repeated: %s", ErrPlanInvalid, task.ID)
+		}
+		ids[task.ID] = true
+	}
+
+	done := make(map[string]bool)
+	for len(done) < len(p.Tasks) {
+		progress := false
+		for _, task := range p.Tasks {
+			if done[task.ID] {
+				continue
+			}
+			ready := true
+			for _, dep := range task.DependsOn {
+				if !ids[dep] {
+					return fmt.Errorf("%w: task %s depends on unknown task %s", ErrPlanInvalid, task.ID, dep)
+				}
+				ready = ready && done[dep]
+			}
+			if ready {
+				done[task.ID] = true
+				progress = true
+			}
+		}
+		if !progress {
+			return fmt.Errorf("%w: tasks depends cycle", ErrPlanInvalid)
+		}
+	}
+	return nil
+}
+
+// GetTask 按ID获取任务
+func (p *Plan) GetTask(id string) *PlanTask {
+	for _, task := range p.Tasks {
+		if task.ID == id {
+			return task
+		}
+	}
+	return nil
+}
+
+// NextTasks 获取依赖均已成功、尚未执行的任务，按计划顺序返回
+func (p *Plan) NextTasks() []*PlanTask {
+	ret := make([]*PlanTask, 0)
+	for _, task := range p.Tasks {
+		if task.State != RunState_Idle {
+			continue
+		}
+		ready := true
+		for _, dep := range task.DependsOn {
+			if tmp := p.GetTask(dep); tmp == nil || tmp.State != RunState_Succeed {
+				ready = false
+				break
+			}
+		}
+		if ready {
+			ret = append(ret, task)
+		}
+	}
+	return ret
+}
+
+// IsDone 所有任务是否均已成功
+func (p *Plan) IsDone() bool {
+	for _, task := range p.Tasks {
+		if task.State != RunState_Succeed {
+			return false
+		}
+	}
+	return true
+}
+
+// Clone 深拷贝计划，避免执行中修改影响已返回的计划
+func (p *Plan) Clone() *Plan {
+	ret := &Plan{
+		Goal:    p.Goal,
+		Answer:  p.Answer,
+		Version: p.Version,
+		Tasks:   make([]*PlanTask, 0, len(p.Tasks)),
+	}
+	for _, task := range p.Tasks {
+		tmp := *task
+		tmp.DependsOn = append([]string{}, task.DependsOn...)
+		ret.Tasks = append(ret.Tasks, &tmp)
+	}
+	return ret
+}
+
+// mergeDone 重新规划后保留旧计划中同ID且已成功任务的状态和结果
+func (p *Plan) mergeDone(old *Plan) {
+	for _, task := range p.Tasks {
+		if tmp := old.GetTask(task.ID); tmp != nil && tmp.State == RunState_Succeed {
+			task.State = tmp.State
+			task.Result = tmp.Result
+		} else {
+			task.State = RunState_Idle
+			task.Result = ""
+		}
+	}
+	p.Version = old.Version + 1
+}
+
+func (p *Plan) String() string {
+	bs, _ := json.Marshal(p)
+	return string(bs)
+}
@@ -0,0 +1,176 @@
+/*
+@Project: aihub
+@Module: aihub
+@File : prompt.go
+*/
+package aihub
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// PromptData 系统提示词模板数据，模板中以 {{.Field}} 访问，例如：
+//
+//	{{range .Tools}}- {{.Name}}: {{.Description}}
+//	{{end}}
+//	当前日期：{{date "2006-01-02"}}，用户：{{.Session.user_id | default "匿名"}}
+type PromptData struct {
+	Context   interface{}            // 上下文信息，WithContext设置
+	Session   map[string]interface{} // session数据
+	SessionID string                 // session ID
+	RunID     string                 // 本次运行ID
+	Tools     []BriefInfo            // 关联的工具
+	Agents    []BriefInfo            // 关联的子Agent
+	Now       time.Time              // 渲染时间
+	Vars      map[string]interface{} // 自定义变量，AgentConfig.PromptVars与WithPromptVars合并
+}
+
+const maxPromptFragmentDepth = 5
+
+// promptRenderer 系统提示词渲染器
+type promptRenderer struct {
+	data      *PromptData
+	fragments map[string]string
+	depth     int
+}
+
+// newPromptData 由运行时选项构造模板数据
+func newPromptData(opts *RunOptions) *PromptData {
+	data := &PromptData{
+		Context: opts.Context,
+		RunID:   opts.RunID,
+		Tools:   opts.Tools,
+		Agents:  opts.Agents,
+		Now:     time.Now(),
+		Vars:    opts.PromptVars,
+	}
+	if opts.Session != nil {
+		data.Session = opts.CopySessionData()
+		data.SessionID = opts.GetSessionID()
+	}
+	if data.Vars == nil {
+		data.Vars = make(map[string]interface{})
+	}
+	return data
+}
+
+// Render 渲染提示词模板
+func (r *promptRenderer) Render(content string) (string, error) {
+	tpl, err := template.New("system_prompt").Funcs(r.funcMap()).Parse(content)
+	if err != nil {
+		return "", err
+	}
+
+	buf := &bytes.Buffer{}
+	if err = tpl.Execute(buf, r.data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func (r *promptRenderer) funcMap() template.FuncMap {
+	return template.FuncMap{
+		// 兼容旧版占位符：{{context}}、{{tools}}、{{session}}、{{agents}}
+		"context": func() string { return promptJSON(r.data.Context) },
+		"tools":   func() string { return promptJSON(r.data.Tools) },
+		"session": func() string { return promptJSON(r.data.Session) },
+		"agents":  func() string { return promptJSON(r.data.Agents) },
+
+		"fragment": r.fragment,
+		"json":     promptJSON,
+		"join":     promptJoin,
+		"upper":    strings.ToUpper,
+		"lower":    strings.ToLower,
+		"trim":     strings.TrimSpace,
+		"indent":   promptIndent,
+		"default":  promptDefault,
+		"now":      func() time.Time { return r.data.Now },
+		"date":     func(layout string) string { return r.data.Now.Format(layout) },
+		"formatTime": func(layout string, t time.Time) string {
+			return t.Format(layout)
+		},
+	}
+}
+
+// fragment 引用并渲染提示词片段，片段内可继续使用模板语法
+func (r *promptRenderer) fragment(name string) (string, error) {
+	content, ok := r.fragments[name]
+	if !ok {
+		if content, ok = GetAgentHub().(*agentHub).GetPromptFragment(name); !ok {
+			return "", fmt.Errorf("%w: %s", ErrPromptFragmentNotFound, name)
+		}
+	}
+	if r.depth >= maxPromptFragmentDepth {
+		return "", fmt.Errorf("%w: fragment %s nested too deep", ErrPromptTemplateInvalid, name)
+	}
+
+	sub := &promptRenderer{
+		data:      r.data,
+		fragments: r.fragments,
+		depth:     r.depth + 1,
+	}
+	return sub.Render(content)
+}
+
+// checkPromptTemplate 注册时校验提示词模板语法及片段引用
+func checkPromptTemplate(content string, fragments map[string]string) error {
+	r := &promptRenderer{
+		data: &PromptData{
+			Session: make(map[string]interface{}),
+			Vars:    make(map[string]interface{}),
+			Now:     time.Now(),
+		},
+		fragments: fragments,
+	}
+	if _, err := r.Render(content); err != nil {
+		return fmt.Errorf("%w: %v", ErrPromptTemplateInvalid, err)
+	}
+	return nil
+}
+
+func promptJSON(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	bs, _ := json.Marshal(v)
+	return string(bs)
+}
+
+func promptJoin(sep string, list interface{}) string {
+	ret := make([]string, 0)
+	switch tmp := list.(type) {
+	case []string:
+		ret = tmp
+	case []interface{}:
+		for _, item := range tmp {
+			ret = append(ret, fmt.Sprint(item))
+		}
+	case []BriefInfo:
+		for _, item := range tmp {
+			ret = append(ret, item.Name)
+		}
+	default:
+		return fmt.Sprint(list)
+	}
+	return strings.Join(ret, sep)
+}
+
+func promptIndent(spaces int, s string) string {
+	pad := strings.Repeat(" ", spaces)
+	return pad + strings.Replace(s, "\n", "\n"+pad, -1)
+}
+
+func promptDefault(def interface{}, v interface{}) interface{} {
+	if v == nil {
+		return def
+	}
+	if str, ok := v.(string); ok && str == "" {
+		return def
+	}
+	return v
+}
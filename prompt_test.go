@@ -0,0 +1,58 @@
+/*
+@Project: aihub
+@Module: aihub
+@File : prompt_test.go
+*/
+package aihub
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func Test_RunOptions_UpdateSystemPrompt(t *testing.T) {
+	opts := &RunOptions{
+		Session:    newSession(map[string]interface{}{"user_id": "u1"}),
+		Tools:      []BriefInfo{{Name: "GetWeather", Description: "查天气"}, {Name: "GetSong", Description: "查歌曲"}},
+		Context:    map[string]string{"city": "深圳"},
+		PromptVars: map[string]interface{}{"team": "aihub"},
+		promptFragments: map[string]string{
+			"footer": "by {{.Vars.team | upper}}",
+		},
+	}
+
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"legacy placeholders", "ctx:{{context}}", `ctx:{"city":"深圳"}`},
+		{"range tools", "{{range .Tools}}[{{.Name}}]{{end}}", "[GetWeather][GetSong]"},
+		{"session value", "user:{{.Session.user_id}}", "user:u1"},
+		{"default value", `{{.Session.missing | default "匿名"}}`, "匿名"},
+		{"join", `{{join "," .Tools}}`, "GetWeather,GetSong"},
+		{"conditional", `{{if .Context}}有上下文{{else}}无上下文{{end}}`, "有上下文"},
+		{"fragment", `{{fragment "footer"}}`, "by AIHUB"},
+		{"date", `{{date "2006"}}`, time.Now().Format("2006")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := opts.UpdateSystemPrompt(tt.content); got != tt.want {
+				t.Errorf("UpdateSystemPrompt() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_checkPromptTemplate(t *testing.T) {
+	if err := checkPromptTemplate("{{range .Tools}}", nil); !errors.Is(err, ErrPromptTemplateInvalid) {
+		t.Fatalf("want ErrPromptTemplateInvalid, got %v", err)
+	}
+	if err := checkPromptTemplate(`{{fragment "not-exist"}}`, nil); !errors.Is(err, ErrPromptTemplateInvalid) {
+		t.Fatalf("want ErrPromptTemplateInvalid, got %v", err)
+	}
+	if err := checkPromptTemplate("{{context}} {{tools}} {{session}} {{agents}}", nil); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,139 @@
+/*
+@Project: aihub
+@Module: aihub
+@File : reflection.go
+*/
+package aihub
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ReflectionConfig 反思配置：最终回答草稿经评审不通过时，附带评审意见退回agent修正
+type ReflectionConfig struct {
+	Criteria       string `json:"criteria,omitempty" yaml:"criteria,omitempty"`               // 评审标准
+	Prompt         string `json:"prompt,omitempty" yaml:"prompt,omitempty"`                   // 可选，评审系统提示词，覆盖默认提示词
+	LLM            string `json:"llm,omitempty" yaml:"llm,omitempty"`                         // 可选，评审使用的LLM名称，默认与agent相同
+	Agent          string `json:"agent,omitempty" yaml:"agent,omitempty"`                     // 可选，评审agent名称，设置时优先使用
+	MaxRounds      int    `json:"max_rounds,omitempty" yaml:"max_rounds,omitempty"`           // 最大修正轮数，默认2
+	ApproveKeyword string `json:"approve_keyword,omitempty" yaml:"approve_keyword,omitempty"` // 评审agent输出非JSON时，包含该关键词视为通过，默认APPROVE
+}
+
+func (cfg *ReflectionConfig) AutoFix() error {
+	if cfg.Criteria == "" && cfg.Prompt == "" && cfg.Agent == "" {
+		return fmt.Errorf("%w: reflection criteria, prompt and agent all empty", ErrConfiguration)
+	}
+	if cfg.MaxRounds <= 0 || cfg.MaxRounds > 5 {
+		cfg.MaxRounds = 2
+	}
+	if cfg.ApproveKeyword == "" {
+		cfg.ApproveKeyword = "APPROVE"
+	}
+	return nil
+}
+
+// Critique 评审结果
+type Critique struct {
+	Pass     bool   `json:"pass"`     // 是否通过
+	Feedback string `json:"feedback"` // 不通过时的修改意见
+}
+
+const reflectionCriticPrompt = `你是一个严格的评审员，负责按评审标准检查回答草稿的质量。
+## 评审标准：
+%s
+
+## 输出格式：
+只输出标准JSON格式（去除制表、换行符）：{"pass":是否通过(true/false),"feedback":"不通过时具体的修改意见"}`
+
+const reflectionFeedbackTpl = `你的回答未通过评审，请根据以下评审意见修正后重新回答：
+%s`
+
+// critique 评审最终回答草稿，评审过程记录为CRITIQUE步骤
+func (a *agent) critique(ctx context.Context, input string, draft string, options *RunOptions, round int) (ret *Critique, err error) {
+	cfg := a.cfg.Reflection
+	step := &RunStep{
+		Action:   cfg.Agent,
+		Question: draft,
+		Think:    fmt.Sprintf("第%d轮评审", round),
+		StepType: StepType_Critique,
+		State:    RunState_Running,
+	}
+	defer func() {
+		step.State = RunState_Failed
+		if err != nil {
+			step.Result = err.Error()
+		} else {
+			step.Result = ret.Feedback
+			if ret.Pass {
+				step.State = RunState_Succeed
+			}
+		}
+		options.AddStep(step)
+	}()
+
+	content := fmt.Sprintf("## 用户问题\n%s\n\n## 回答草稿\n%s", input, draft)
+	if cfg.Agent != "" {
+		ag := GetAgentHub().GetAgent(cfg.Agent)
+		if ag == nil {
+			return nil, fmt.Errorf("%w: %s", ErrCallNameNotMatch, cfg.Agent)
+		}
+		if cfg.Criteria != "" {
+			content += "\n\n## 评审标准\n" + cfg.Criteria
+		}
+		rsp := ag.Run(ctx, content, WithDebug(false))
+		if rsp.Err != nil {
+			return nil, rsp.Err
+		}
+		if rsp.Message == nil {
+			return nil, ErrToolCallResponseEmpty
+		}
+		return parseCritique(rsp.Message.Content, cfg.ApproveKeyword), nil
+	}
+
+	llmName := cfg.LLM
+	if llmName == "" {
+		llmName = options.RuntimeCfg.LLM
+	}
+	step.Action = llmName
+	LLMIns := GetLLMHub().GetLLM(llmName)
+	if LLMIns == nil {
+		return nil, fmt.Errorf("%w: llm %s", ErrConfiguration, llmName)
+	}
+
+	prompt := cfg.Prompt
+	if prompt == "" {
+		prompt = fmt.Sprintf(reflectionCriticPrompt, cfg.Criteria)
+	}
+	rsp, err := createChatCompletion(ctx, LLMIns, &CreateChatCompletionReq{
+		Messages: []*Message{
+			{Role: MessageRoleSystem, Content: prompt},
+			{Role: MessageRoleUser, Content: content},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if rsp.Error != nil {
+		return nil, errors.New(rsp.Error.Message)
+	}
+	if len(rsp.Choices) == 0 || rsp.Choices[0].Message == nil {
+		return nil, ErrToolCallResponseEmpty
+	}
+	return parseCritique(rsp.Choices[0].Message.Content, cfg.ApproveKeyword), nil
+}
+
+// parseCritique 优先按JSON解析评审结果，否则按是否包含通过关键词判断
+func parseCritique(content string, approveKeyword string) *Critique {
+	ret := &Critique{}
+	if err := json.Unmarshal([]byte(TrimJSONCodeBlock(content)), ret); err == nil {
+		return ret
+	}
+
+	ret.Pass = strings.Contains(content, approveKeyword)
+	ret.Feedback = strings.TrimSpace(strings.Replace(content, approveKeyword, "", -1))
+	return ret
+}
@@ -0,0 +1,83 @@
+/*
+@Project: aihub
+@Module: aihub
+@File : reflection_test.go
+*/
+package aihub
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func Test_agent_RunReflection(t *testing.T) {
+	newTestLLM(t, "test-reflection-llm", func(req *CreateChatCompletionReq) *ChatCompletionRspChoice {
+		content := "查询所有用户"
+		if strings.Contains(req.Messages[len(req.Messages)-1].Content, "缺少SQL语句") {
+			content = "SELECT * FROM users"
+		}
+		return &ChatCompletionRspChoice{
+			Message:      &Message{Role: MessageRoleAssistant, Content: content},
+			FinishReason: ChatCompletionRspFinishReasonStop,
+		}
+	})
+	newTestLLM(t, "test-critic-llm", func(req *CreateChatCompletionReq) *ChatCompletionRspChoice {
+		content := `{"pass":false,"feedback":"缺少SQL语句"}`
+		if strings.Contains(req.Messages[len(req.Messages)-1].Content, "SELECT") {
+			content = "```json\n{\"pass\":true}\n```"
+		}
+		return &ChatCompletionRspChoice{
+			Message:      &Message{Role: MessageRoleAssistant, Content: content},
+			FinishReason: ChatCompletionRspFinishReasonStop,
+		}
+	})
+
+	ag, err := GetAgentHub().SetAgentByYamlData([]byte(`
+name: test-reflection
+llm: test-reflection-llm
+debug: true
+reflection:
+  criteria: 回答必须是可执行的SQL语句
+  llm: test-critic-llm
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rsp := ag.Run(context.Background(), "查询所有用户的SQL")
+	if rsp.Err != nil {
+		t.Fatal(rsp.Err)
+	}
+	if rsp.Message.Content != "SELECT * FROM users" {
+		t.Fatalf("want revised answer, got %s", rsp.Message.Content)
+	}
+
+	critiques := make([]*RunStep, 0)
+	for _, step := range rsp.Steps {
+		if step.StepType == StepType_Critique {
+			critiques = append(critiques, step)
+		}
+	}
+	if len(critiques) != 2 || critiques[0].State != RunState_Failed || critiques[1].State != RunState_Succeed {
+		t.Fatalf("unexpected critique steps => %d", len(critiques))
+	}
+	if !strings.Contains(rsp.Content, "CRITIQUE") {
+		t.Fatalf("critique steps not rendered => %s", rsp.Content)
+	}
+
+	// 未通过的草稿及评审意见不写入会话记忆
+	history := ag.GetMemory().GetLatest(&RunOptions{Session: rsp.Session})
+	if len(history) != 2 || history[0].Content != "查询所有用户的SQL" || history[1].Content != "SELECT * FROM users" {
+		t.Fatalf("unexpected history => %d", len(history))
+	}
+}
+
+func Test_parseCritique(t *testing.T) {
+	if ret := parseCritique("写得不错，APPROVE", "APPROVE"); !ret.Pass {
+		t.Fatal("want pass by keyword")
+	}
+	if ret := parseCritique("请补充示例", "APPROVE"); ret.Pass || ret.Feedback != "请补充示例" {
+		t.Fatalf("unexpected critique => %+v", ret)
+	}
+}
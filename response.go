@@ -16,10 +16,17 @@ type Response struct {
 }
 
 type rawResponse struct {
+	RunID   string   `json:"run_id,omitempty"`
 	Message *Message `json:"message,omitempty"`
 	Session *Session `json:"session,omitempty"`
 	Content string   `json:"content"`
 	Error   string   `json:"error,omitempty"`
+
+	Guardrail  *GuardrailError `json:"guardrail,omitempty"`  // 触发的护栏信息
+	Transcript []*Message      `json:"transcript,omitempty"` // 多agent群聊的完整对话记录
+	Plan       *Plan           `json:"plan,omitempty"`       // Manus任务计划及执行状态
+	Steps      []*RunStep      `json:"steps,omitempty"`      // 执行步骤列表，包含工具及子agent调用
+	Citations  []*KnowledgeHit `json:"citations,omitempty"`  // 知识库引用来源，编号与回答中的[编号]对应
 }
 
 func (r *Response) MarshalJSON() ([]byte, error) {
@@ -35,7 +42,9 @@ func (r *Response) UnmarshalJSON(bs []byte) error {
 		return err1
 	}
 
-	if r.rawResponse.Error != "" {
+	if r.rawResponse.Guardrail != nil {
+		r.Err = r.rawResponse.Guardrail
+	} else if r.rawResponse.Error != "" {
 		r.Err = errors.New(r.rawResponse.Error)
 	}
 
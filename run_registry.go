@@ -0,0 +1,228 @@
+/*
+@Project: aihub
+@Module: aihub
+@File : run_registry.go
+*/
+package aihub
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// RunInfo 运行中Agent请求的状态快照
+type RunInfo struct {
+	RunID       string        `json:"run_id"`                 // 运行ID
+	AgentName   string        `json:"agent_name"`             // 所属agent名称
+	SessionID   string        `json:"session_id"`             // 会话ID
+	Input       string        `json:"input"`                  // 用户输入
+	State       RunState      `json:"state"`                  // 当前状态
+	CurrentStep *RunStep      `json:"current_step,omitempty"` // 当前最新步骤
+	StepCount   int           `json:"step_count"`             // 已执行步数
+	StartTime   time.Time     `json:"start_time"`             // 开始时间
+	Elapsed     time.Duration `json:"elapsed"`                // 已耗时
+}
+
+type runEntry struct {
+	info   RunInfo
+	opts   *RunOptions
+	cancel context.CancelCauseFunc
+
+	owner    IAgent        // 发起请求的agent实例
+	done     chan struct{} // 请求执行结束时关闭
+	detached bool          // 由后台协程执行，注销后协程仍可能在运行，结束时由detach返回的函数关闭done
+}
+
+type runRegistry struct {
+	runs    map[string]*runEntry   // runID => runEntry
+	exiting map[*runEntry]struct{} // 已注销但后台协程尚未退出的请求
+
+	lock sync.RWMutex
+}
+
+func newRunRegistry() *runRegistry {
+	return &runRegistry{
+		runs:    make(map[string]*runEntry),
+		exiting: make(map[*runEntry]struct{}),
+	}
+}
+
+// register 登记运行中的请求，运行ID重复时拒绝
+func (r *runRegistry) register(owner IAgent, input string, opts *RunOptions, cancel context.CancelCauseFunc) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if _, ok := r.runs[opts.RunID]; ok {
+		return fmt.Errorf("%w: %s", ErrRunIDRepeat, opts.RunID)
+	}
+
+	r.runs[opts.RunID] = &runEntry{
+		info: RunInfo{
+			RunID:     opts.RunID,
+			AgentName: owner.GetBriefInfo().Name,
+			SessionID: opts.GetSessionID(),
+			Input:     input,
+			State:     RunState_Running,
+			StartTime: time.Now(),
+		},
+		opts:   opts,
+		cancel: cancel,
+		owner:  owner,
+		done:   make(chan struct{}),
+	}
+	return nil
+}
+
+// unregister 请求结束后注销，后台协程尚未退出时继续跟踪至其退出
+func (r *runRegistry) unregister(runID string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	tmp, ok := r.runs[runID]
+	if !ok {
+		return
+	}
+	delete(r.runs, runID)
+	if !tmp.detached {
+		close(tmp.done)
+		return
+	}
+	select {
+	case <-tmp.done:
+	default:
+		r.exiting[tmp] = struct{}{}
+	}
+}
+
+// detach 标记请求由后台协程执行，返回协程退出时调用的函数
+func (r *runRegistry) detach(runID string) func() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	tmp, ok := r.runs[runID]
+	if !ok {
+		return func() {}
+	}
+	tmp.detached = true
+	return func() {
+		r.lock.Lock()
+		defer r.lock.Unlock()
+		close(tmp.done)
+		delete(r.exiting, tmp)
+	}
+}
+
+// entriesOf 获取agent实例运行中及后台协程尚未退出的请求，调用方需持有锁
+func (r *runRegistry) entriesOf(owner IAgent) []*runEntry {
+	ret := make([]*runEntry, 0)
+	for _, tmp := range r.runs {
+		if tmp.owner == owner {
+			ret = append(ret, tmp)
+		}
+	}
+	for tmp := range r.exiting {
+		if tmp.owner == owner {
+			ret = append(ret, tmp)
+		}
+	}
+	return ret
+}
+
+// hasRuns 判断agent实例是否有运行中的请求
+func (r *runRegistry) hasRuns(owner IAgent) bool {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	return len(r.entriesOf(owner)) > 0
+}
+
+// waitDrain 等待agent实例运行中的请求全部结束，ctx结束时提前返回
+func (r *runRegistry) waitDrain(ctx context.Context, owner IAgent) error {
+	for {
+		r.lock.RLock()
+		dones := make([]chan struct{}, 0)
+		for _, tmp := range r.entriesOf(owner) {
+			dones = append(dones, tmp.done)
+		}
+		r.lock.RUnlock()
+
+		if len(dones) == 0 {
+			return nil
+		}
+		for _, done := range dones {
+			select {
+			case <-done:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+}
+
+func (r *runRegistry) GetAllRunIDList() []string {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	ret := make([]string, 0)
+	for runID, _ := range r.runs {
+		ret = append(ret, runID)
+	}
+	return ret
+}
+
+func (r *runRegistry) GetRunInfo(runID string) *RunInfo {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	if tmp, ok := r.runs[runID]; ok {
+		return tmp.snapshot()
+	}
+	return nil
+}
+
+func (r *runRegistry) GetRunInfoList() []*RunInfo {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	ret := make([]*RunInfo, 0)
+	for _, tmp := range r.runs {
+		ret = append(ret, tmp.snapshot())
+	}
+	return ret
+}
+
+// cancelAll 取消所有运行中的请求
+func (r *runRegistry) cancelAll(cause error) {
+	r.lock.Lock()
+	entries := make([]*runEntry, 0, len(r.runs))
+	for _, tmp := range r.runs {
+		tmp.info.State = RunState_Canceled
+		entries = append(entries, tmp)
+	}
+	r.lock.Unlock()
+
+	for _, tmp := range entries {
+		tmp.cancel(cause)
+	}
+}
+
+func (r *runRegistry) Cancel(runID string) error {
+	r.lock.Lock()
+	tmp, ok := r.runs[runID]
+	if ok {
+		tmp.info.State = RunState_Canceled
+	}
+	r.lock.Unlock()
+	if !ok {
+		return ErrRunNotFound
+	}
+
+	tmp.cancel(ErrAgentRunCanceled)
+	return nil
+}
+
+func (e *runEntry) snapshot() *RunInfo {
+	ret := e.info
+	ret.Elapsed = time.Since(ret.StartTime)
+
+	ret.StepCount, ret.CurrentStep = e.opts.lastStep()
+	return &ret
+}
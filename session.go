@@ -22,8 +22,8 @@ func newSession(src map[string]interface{}) *Session {
 		SessionID:   uuid.NewV4().String(),
 		SessionData: make(map[string]interface{}),
 	}
-	if src != nil {
-		ret.SessionData = src
+	for key, value := range src {
+		ret.SessionData[key] = value // 拷贝，避免多次运行共享配置中的map
 	}
 	return ret
 }
@@ -52,6 +52,17 @@ func (s *Session) GetAllSessionData() map[string]interface{} {
 	return s.SessionData
 }
 
+// CopySessionData 获取session数据的浅拷贝
+func (s *Session) CopySessionData() map[string]interface{} {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	ret := make(map[string]interface{})
+	for key, value := range s.SessionData {
+		ret[key] = value
+	}
+	return ret
+}
+
 func (s *Session) GetSessionID() string {
 	return s.SessionID
 }
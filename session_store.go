@@ -0,0 +1,258 @@
+/*
+@Project: aihub
+@Module: aihub
+@File : session_store.go
+*/
+package aihub
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	SessionStoreType_Memory = "memory" // 进程内存储，所有agent共用
+	SessionStoreType_File   = "file"   // 本地文件存储，每个会话一个JSON文件
+)
+
+// SessionStoreConfig 会话数据存储配置：指定已有会话ID运行时加载保存的会话数据，运行结束后写回
+type SessionStoreConfig struct {
+	Type string `json:"type,omitempty" yaml:"type,omitempty"` // 存储类型：memory(默认)|file，或RegisterSessionStore注册的存储名称
+	Path string `json:"path,omitempty" yaml:"path,omitempty"` // file：存储目录
+	TTL  int64  `json:"ttl,omitempty" yaml:"ttl,omitempty"`   // 会话数据过期秒数，每次写回时重新计时，默认与MemoryTimeout一致
+}
+
+func (cfg *SessionStoreConfig) AutoFix() error {
+	if cfg.Type == "" {
+		cfg.Type = SessionStoreType_Memory
+	}
+	if cfg.Type == SessionStoreType_File && cfg.Path == "" {
+		return fmt.Errorf("%w: session store type %s path empty", ErrConfiguration, cfg.Type)
+	}
+	if cfg.TTL < 0 {
+		cfg.TTL = 0
+	}
+	return nil
+}
+
+var (
+	sessionStores     = make(map[string]ISessionStore)
+	sessionStoresLock sync.RWMutex
+
+	defaultMemorySessionStore     ISessionStore
+	defaultMemorySessionStoreOnce sync.Once
+)
+
+// RegisterSessionStore 注册自定义会话数据存储，供配置按名称引用，同名覆盖
+func RegisterSessionStore(name string, store ISessionStore) {
+	sessionStoresLock.Lock()
+	defer sessionStoresLock.Unlock()
+	sessionStores[name] = store
+}
+
+// newSessionStore 按配置选择会话数据存储
+func newSessionStore(cfg *SessionStoreConfig) (ISessionStore, error) {
+	switch cfg.Type {
+	case SessionStoreType_Memory:
+		defaultMemorySessionStoreOnce.Do(func() {
+			defaultMemorySessionStore = NewMemorySessionStore()
+		})
+		return defaultMemorySessionStore, nil
+	case SessionStoreType_File:
+		return NewFileSessionStore(cfg.Path), nil
+	}
+
+	sessionStoresLock.RLock()
+	defer sessionStoresLock.RUnlock()
+	if store, ok := sessionStores[cfg.Type]; ok {
+		return store, nil
+	}
+	return nil, fmt.Errorf("%w: unknown session store %s", ErrConfiguration, cfg.Type)
+}
+
+// sessionRecord 保存的会话数据
+type sessionRecord struct {
+	SessionID   string                 `json:"session_id"`
+	SessionData map[string]interface{} `json:"session_data"`
+	ExpireAt    int64                  `json:"expire_at,omitempty"` // 过期时间戳，0表示不过期
+}
+
+func newSessionRecord(sessionID string, data map[string]interface{}, ttl time.Duration) *sessionRecord {
+	ret := &sessionRecord{SessionID: sessionID, SessionData: data}
+	if ttl > 0 {
+		ret.ExpireAt = time.Now().Add(ttl).Unix()
+	}
+	return ret
+}
+
+func (r *sessionRecord) expired(now int64) bool {
+	return r.ExpireAt > 0 && now >= r.ExpireAt
+}
+
+// memorySessionStore 进程内会话数据存储
+type memorySessionStore struct {
+	records   map[string]*sessionRecord
+	lastSweep int64
+	lock      sync.RWMutex
+}
+
+// NewMemorySessionStore 创建进程内会话数据存储
+func NewMemorySessionStore() ISessionStore {
+	return &memorySessionStore{records: make(map[string]*sessionRecord)}
+}
+
+func (s *memorySessionStore) Load(sessionID string) (map[string]interface{}, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	record, ok := s.records[sessionID]
+	if !ok || record.expired(time.Now().Unix()) {
+		return nil, nil
+	}
+	ret := make(map[string]interface{}, len(record.SessionData))
+	for key, value := range record.SessionData {
+		ret[key] = value
+	}
+	return ret, nil
+}
+
+func (s *memorySessionStore) Save(sessionID string, data map[string]interface{}, ttl time.Duration) error {
+	copied := make(map[string]interface{}, len(data))
+	for key, value := range data {
+		copied[key] = value
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.records[sessionID] = newSessionRecord(sessionID, copied, ttl)
+
+	// 每分钟最多清理一次过期会话
+	if now := time.Now().Unix(); now-s.lastSweep >= 60 {
+		s.lastSweep = now
+		for key, record := range s.records {
+			if record.expired(now) {
+				delete(s.records, key)
+			}
+		}
+	}
+	return nil
+}
+
+func (s *memorySessionStore) Delete(sessionID string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.records, sessionID)
+	return nil
+}
+
+// fileSessionStore 本地文件会话数据存储，数据经JSON序列化，读取后数值类型为float64
+type fileSessionStore struct {
+	dir       string
+	lastSweep int64
+	lock      sync.Mutex
+}
+
+// NewFileSessionStore 创建本地文件会话数据存储
+func NewFileSessionStore(dir string) ISessionStore {
+	return &fileSessionStore{dir: dir}
+}
+
+func (s *fileSessionStore) file(sessionID string) string {
+	return filepath.Join(s.dir, url.PathEscape(sessionID)+".json")
+}
+
+func (s *fileSessionStore) Load(sessionID string) (map[string]interface{}, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	data, err := os.ReadFile(s.file(sessionID))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	record := &sessionRecord{}
+	if err = json.Unmarshal(data, record); err != nil {
+		return nil, err
+	}
+	if record.expired(time.Now().Unix()) {
+		os.Remove(s.file(sessionID))
+		return nil, nil
+	}
+	if record.SessionData == nil {
+		record.SessionData = make(map[string]interface{})
+	}
+	return record.SessionData, nil
+}
+
+func (s *fileSessionStore) Save(sessionID string, data map[string]interface{}, ttl time.Duration) error {
+	bs, err := json.Marshal(newSessionRecord(sessionID, data, ttl))
+	if err != nil {
+		return err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if err = os.MkdirAll(s.dir, 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(s.dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(bs)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err = os.Rename(tmp.Name(), s.file(sessionID)); err != nil {
+		return err
+	}
+
+	// 每分钟最多清理一次过期会话文件
+	if now := time.Now().Unix(); now-s.lastSweep >= 60 {
+		s.lastSweep = now
+		s.sweep(now)
+	}
+	return nil
+}
+
+// sweep 删除目录下已过期的会话文件，调用方需持有锁
+func (s *fileSessionStore) sweep(now int64) {
+	files, err := filepath.Glob(filepath.Join(s.dir, "*.json"))
+	if err != nil {
+		return
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		record := &sessionRecord{}
+		if json.Unmarshal(data, record) != nil || !record.expired(now) {
+			continue
+		}
+		os.Remove(file)
+	}
+}
+
+func (s *fileSessionStore) Delete(sessionID string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if err := os.Remove(s.file(sessionID)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+/*
+@Project: aihub
+@Module: aihub
+@File : session_store_test.go
+*/
+package aihub
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_sessionStore(t *testing.T) {
+	for _, store := range []ISessionStore{NewMemorySessionStore(), NewFileSessionStore(t.TempDir())} {
+		if data, err := store.Load("s/1"); err != nil || data != nil {
+			t.Fatalf("want nil for missing session => %v, %v", data, err)
+		}
+		if err := store.Save("s/1", map[string]interface{}{"user": "u1"}, time.Hour); err != nil {
+			t.Fatal(err)
+		}
+		if data, err := store.Load("s/1"); err != nil || data["user"] != "u1" {
+			t.Fatalf("unexpected load => %v, %v", data, err)
+		}
+
+		// 过期
+		if err := store.Save("s2", map[string]interface{}{"user": "u2"}, time.Nanosecond); err != nil {
+			t.Fatal(err)
+		}
+		if data, _ := store.Load("s2"); data != nil {
+			t.Fatalf("want expired => %v", data)
+		}
+
+		if err := store.Delete("s/1"); err != nil {
+			t.Fatal(err)
+		}
+		if data, _ := store.Load("s/1"); data != nil {
+			t.Fatalf("want deleted => %v", data)
+		}
+	}
+}
+
+func Test_fileSessionStore_Sweep(t *testing.T) {
+	dir := t.TempDir()
+	store := NewFileSessionStore(dir)
+	if err := store.Save("s1", map[string]interface{}{"user": "u1"}, time.Nanosecond); err != nil {
+		t.Fatal(err)
+	}
+
+	// 未读取的过期文件在后续写入时清理
+	store.(*fileSessionStore).lastSweep = 0
+	time.Sleep(time.Second)
+	if err := store.Save("s2", map[string]interface{}{"user": "u2"}, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "s1.json")); !os.IsNotExist(err) {
+		t.Fatalf("want expired file swept => %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "s2.json")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_agent_RunResumeSession(t *testing.T) {
+	prompts := make([]string, 0)
+	newTestLLM(t, "test-session-llm", func(req *CreateChatCompletionReq) *ChatCompletionRspChoice {
+		prompts = append(prompts, req.Messages[0].Content)
+		return &ChatCompletionRspChoice{
+			Message:      &Message{Role: MessageRoleAssistant, Content: "好的"},
+			FinishReason: ChatCompletionRspFinishReasonStop,
+		}
+	})
+
+	dir := t.TempDir()
+	ag, err := GetAgentHub().SetAgentByYamlData([]byte(`
+name: test-session
+llm: test-session-llm
+system_prompt: "用户：{{.Session.user | default \"匿名\"}}"
+session_data:
+  user: 默认
+session_store:
+  type: file
+  path: ` + dir + `
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	if rsp := ag.Run(ctx, "我是u1", WithSessionID("s1"), WithSessionData(map[string]interface{}{"user": "u1"})); rsp.Err != nil {
+		t.Fatal(rsp.Err)
+	}
+
+	// 指定已有会话ID时恢复会话数据
+	rsp := ag.Run(ctx, "我是谁", WithSessionID("s1"))
+	if rsp.Err != nil {
+		t.Fatal(rsp.Err)
+	}
+	if rsp.Session.GetSessionData("user") != "u1" || !strings.Contains(prompts[1], "用户：u1") {
+		t.Fatalf("session not resumed => %v, %s", rsp.Session.GetAllSessionData(), prompts[1])
+	}
+
+	// 显式设置的会话数据优先
+	if rsp = ag.Run(ctx, "我是u2", WithSessionData(map[string]interface{}{"user": "u2"}), WithSessionID("s1")); rsp.Session.GetSessionData("user") != "u2" {
+		t.Fatalf("want override => %v", rsp.Session.GetAllSessionData())
+	}
+
+	// 新会话使用配置默认值
+	if rsp = ag.Run(ctx, "你好"); rsp.Session.GetSessionData("user") != "默认" {
+		t.Fatalf("unexpected new session => %v", rsp.Session.GetAllSessionData())
+	}
+
+	// 未指定会话ID时不保存
+	if files, _ := filepath.Glob(filepath.Join(dir, "*.json")); len(files) != 1 {
+		t.Fatalf("want only explicit session saved => %v", files)
+	}
+}
+
+func Test_agent_RunTimeoutSession(t *testing.T) {
+	release := make(chan struct{})
+	newTestLLM(t, "test-session-timeout-llm", func(req *CreateChatCompletionReq) *ChatCompletionRspChoice {
+		<-release
+		return &ChatCompletionRspChoice{
+			Message:      &Message{Role: MessageRoleAssistant, Content: "好的"},
+			FinishReason: ChatCompletionRspFinishReasonStop,
+		}
+	})
+
+	store := NewMemorySessionStore()
+	RegisterSessionStore("test-session-timeout", store)
+	ag, err := GetAgentHub().SetAgent(&AgentConfig{
+		BriefInfo:       BriefInfo{Name: "test-session-timeout"},
+		AgentRuntimeCfg: AgentRuntimeCfg{LLM: "test-session-timeout-llm", RunTimeout: 1},
+		SessionStore:    &SessionStoreConfig{Type: "test-session-timeout"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rsp := ag.Run(context.Background(), "你好", WithSessionID("s1"), WithSessionData(map[string]interface{}{"user": "u1"}))
+	if !errors.Is(rsp.Err, ErrAgentRunTimeout) {
+		t.Fatalf("want ErrAgentRunTimeout, got %v", rsp.Err)
+	}
+	// 超时返回时主循环仍在执行，不保存会话快照
+	if data, _ := store.Load("s1"); data != nil {
+		t.Fatalf("want no session saved on timeout => %v", data)
+	}
+
+	// 主循环结束后保存
+	close(release)
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if data, _ := store.Load("s1"); data["user"] == "u1" {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("want session saved after run loop finished")
+}
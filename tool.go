@@ -1,7 +1,10 @@
 package aihub
 
 import (
+	"encoding/json"
 	"github.com/mvptianyu/aihub/jsonschema"
+	"reflect"
+	"strings"
 )
 
 const (
@@ -22,6 +25,8 @@ type ToolFunction struct {
 	BriefInfo
 	Parameters *jsonschema.Definition `json:"parameters,omitempty" yaml:"parameters,omitempty"`
 	Strict     bool                   `json:"strict,omitempty" yaml:"strict,omitempty"`
+
+	OutputSchema *jsonschema.Definition `json:"-" yaml:"-"` // 可选，输出结果定义，不发送给模型，用于OpenAPI文档及MCP元数据
 }
 
 // IToolInput 工具入参格式定义
@@ -51,3 +56,147 @@ func (t *ToolInputBase) GetRawSession() string {
 func (t *ToolInputBase) SetRawSession(str string) {
 	t.Session = str
 }
+
+// ToolArgumentsError 工具入参校验失败，内容以结构化JSON反馈给模型自我修正
+type ToolArgumentsError struct {
+	Tool      string                       `json:"tool"`
+	Fields    []jsonschema.ValidationError `json:"fields"`
+	Remaining int                          `json:"remaining_repairs"` // 剩余可修正次数
+}
+
+func (e *ToolArgumentsError) Error() string {
+	bs, _ := json.Marshal(struct {
+		Error string `json:"error"`
+		Hint  string `json:"hint"`
+		*ToolArgumentsError
+	}{
+		Error:              ErrToolArgumentsInvalid.Error(),
+		Hint:               "请根据工具参数定义修正以上字段后重新调用",
+		ToolArgumentsError: e,
+	})
+	return string(bs)
+}
+
+func (e *ToolArgumentsError) Is(target error) bool {
+	return target == ErrToolArgumentsInvalid
+}
+
+// validateToolArguments 按工具参数定义校验模型生成的入参
+func validateToolArguments(name string, params *jsonschema.Definition, input string) error {
+	if params == nil {
+		return nil
+	}
+	if input == "" {
+		input = "{}"
+	}
+
+	var data any
+	if err := json.Unmarshal([]byte(input), &data); err != nil {
+		return &ToolArgumentsError{
+			Tool: name,
+			Fields: []jsonschema.ValidationError{
+				{Reason: "invalid json: " + err.Error()},
+			},
+		}
+	}
+
+	if errs := jsonschema.ValidateWithErrors(*params, data); len(errs) > 0 {
+		return &ToolArgumentsError{
+			Tool:   name,
+			Fields: errs,
+		}
+	}
+	return nil
+}
+
+// toolSessionFields 解析入参结构体中声明session tag的字段，返回 参数名 => session key
+func toolSessionFields(t reflect.Type) map[string]string {
+	ret := make(map[string]string)
+	if t.Kind() != reflect.Struct {
+		return ret
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		jsonTag := strings.Split(field.Tag.Get("json"), ",")[0]
+		if jsonTag == "-" {
+			continue
+		}
+		if jsonTag == "" && field.Anonymous {
+			// 组合成员，子字段承接
+			for name, key := range toolSessionFields(field.Type) {
+				ret[name] = key
+			}
+			continue
+		}
+		if jsonTag == "" {
+			jsonTag = field.Name
+		}
+		if key := field.Tag.Get("session"); key != "" {
+			ret[jsonTag] = key
+		}
+	}
+	return ret
+}
+
+// hideToolSessionArgs 从参数定义中移除session绑定的参数，不暴露给模型，返回拷贝不修改原定义
+func hideToolSessionArgs(params *jsonschema.Definition, bindings map[string]string) *jsonschema.Definition {
+	if params == nil || len(bindings) == 0 {
+		return params
+	}
+
+	ret := *params
+	ret.Properties = make(map[string]jsonschema.Definition, len(params.Properties))
+	for name, def := range params.Properties {
+		if _, ok := bindings[name]; !ok {
+			ret.Properties[name] = def
+		}
+	}
+	ret.Required = make([]string, 0, len(params.Required))
+	for _, name := range params.Required {
+		if _, ok := bindings[name]; !ok {
+			ret.Required = append(ret.Required, name)
+		}
+	}
+	return &ret
+}
+
+// injectToolSessionArgs 先移除模型传入的绑定参数，再从session数据中取值填充，session中不存在的key不填充，避免模型伪造绑定参数
+func injectToolSessionArgs(input string, bindings map[string]string, session *Session) (string, error) {
+	if len(bindings) == 0 {
+		return input, nil
+	}
+	if input == "" {
+		input = "{}"
+	}
+
+	args := make(map[string]interface{})
+	if err := json.Unmarshal([]byte(input), &args); err != nil {
+		return input, err
+	}
+	changed := false
+	for name, key := range bindings {
+		if _, ok := args[name]; ok {
+			delete(args, name)
+			changed = true
+		}
+		if session == nil {
+			continue
+		}
+		if value := session.GetSessionData(key); value != nil {
+			args[name] = value
+			changed = true
+		}
+	}
+	if !changed {
+		return input, nil
+	}
+
+	bs, err := json.Marshal(args)
+	if err != nil {
+		return input, err
+	}
+	return string(bs), nil
+}
@@ -3,12 +3,15 @@ package aihub
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
 	"github.com/mvptianyu/aihub/jsonschema"
 	"github.com/tidwall/gjson"
 	"log"
 	"reflect"
+	"regexp"
 	"runtime"
 	"strings"
 	"sync"
@@ -18,12 +21,21 @@ import (
 type ToolMethod func(ctx context.Context, input IToolInput, output *Message) (err error)
 
 type ToolEntry struct {
+	Name        string // 可选，工具名，为空时取函数名；匿名函数必须指定
 	Description string
-	Function    interface{} // 方法入口
+	Function    interface{} // 方法入口，普通函数、方法值或闭包
+	Policy      ToolPolicy  // 调用策略：超时、并发、重试
 
 	method       reflect.Value
 	input        reflect.Type
 	toolFunction ToolFunction
+
+	output reflect.Type                                                   // 泛型工具的出参类型，用于生成输出定义
+	call   func(ctx context.Context, input string, output *Message) error // 泛型工具的调用入口，为空时按方法签名反射调用
+
+	sessionFields map[string]string // 入参中session绑定的参数名 => session key，运行时从session数据填充
+
+	sem chan struct{} // 按Policy.MaxConcurrency创建的并发限制，随注册的工具定义替换
 }
 
 type toolHub struct {
@@ -71,79 +83,165 @@ func (h *toolHub) GetToolFunctions(names ...string) []ToolFunction {
 }
 
 func (h *toolHub) SetTool(objs ...ToolEntry) error {
-	h.lock.Lock()
-	defer h.lock.Unlock()
-	var err error
+	// 先校验全部入参，任一失败则整批不注册
+	entrys := make([]ToolEntry, 0, len(objs))
+	names := make(map[string]bool)
 	for _, obj := range objs {
-		if obj.Function == nil {
-			continue
+		entry, err := newToolEntry(obj)
+		if err != nil {
+			return err
 		}
-		obj.method = reflect.ValueOf(obj.Function)
-		// 获取函数名
-		methodName := runtime.FuncForPC(obj.method.Pointer()).Name()
-		splits := strings.Split(methodName, ".")
-		fixName := splits[len(splits)-1] // 去除函数名中的包路径
-
-		// 获取函数类型
-		methodType := reflect.TypeOf(obj.Function)
-		// 重复注册
-		if _, ok := h.toolEntrys[fixName]; ok {
-			return ErrToolRegisterRepeat
+		if names[entry.toolFunction.Name] {
+			return fmt.Errorf("%w: %s", ErrToolRegisterRepeat, entry.toolFunction.Name)
 		}
+		names[entry.toolFunction.Name] = true
+		entrys = append(entrys, entry)
+	}
 
-		// 检查方法的出入参数量
-		if methodType.NumIn() != 4 && methodType.NumOut() != 1 {
-			continue
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	for _, entry := range entrys {
+		// 重复注册
+		if _, ok := h.toolEntrys[entry.toolFunction.Name]; ok {
+			return fmt.Errorf("%w: %s", ErrToolRegisterRepeat, entry.toolFunction.Name)
 		}
+	}
+	for _, entry := range entrys {
+		h.toolEntrys[entry.toolFunction.Name] = entry
+		h.addMCPServerTool(entry) // 加入MCPServer
+	}
+	return nil
+}
 
-		// 检查第一个参数是否为 Context.Context 类型
-		if methodType.In(0) != reflect.TypeOf((*context.Context)(nil)).Elem() {
-			continue
-		}
+var closureNameReg = regexp.MustCompile(`\.func\d+(\.\d+)*$`) // 匿名函数符号名，嵌套时如pkg.main.func1.1
 
-		// 检查第二个参数是否实现了 IToolInput 接口
-		if methodType.In(1).Implements(reflect.TypeOf((*IToolInput)(nil)).Elem()) == false {
-			continue
-		}
+// toolFuncName 由函数符号名推导工具名：去除包路径及方法值的-fm后缀，匿名函数无法推导
+func toolFuncName(method reflect.Value) (string, error) {
+	methodName := runtime.FuncForPC(method.Pointer()).Name()
+	if closureNameReg.MatchString(methodName) {
+		return "", fmt.Errorf("%w: anonymous function %s requires explicit Name", ErrToolRegisterInvalid, methodName)
+	}
+	splits := strings.Split(methodName, ".")
+	return strings.TrimSuffix(splits[len(splits)-1], "-fm"), nil // 去除函数名中的包路径
+}
 
-		// 检查第三个返回值是否为 *Message 类型
-		if methodType.In(2) != reflect.TypeOf((*Message)(nil)) {
-			continue
-		}
+// newToolEntry 校验工具方法签名并生成参数定义
+func newToolEntry(obj ToolEntry) (ToolEntry, error) {
+	if obj.Function == nil {
+		return obj, fmt.Errorf("%w: function nil, name:%s", ErrToolRegisterInvalid, obj.Name)
+	}
+	obj.method = reflect.ValueOf(obj.Function)
+	methodType := obj.method.Type()
+	if methodType.Kind() != reflect.Func {
+		return obj, fmt.Errorf("%w: %s is not a function", ErrToolRegisterInvalid, methodType)
+	}
 
-		// 检查第1个返回值是否为 error 类型
-		if methodType.Out(0) != reflect.TypeOf((*error)(nil)).Elem() {
-			continue
+	// 获取工具名
+	fixName := obj.Name
+	if fixName == "" {
+		var err error
+		if fixName, err = toolFuncName(obj.method); err != nil {
+			return obj, err
 		}
-
-		// 获取第二个参数的类型（索引为 1，因为第一个是 ctx）
-		obj.input = methodType.In(1).Elem()
-		obj.toolFunction = ToolFunction{}
-		obj.toolFunction.Name = fixName
-		if obj.Description == "" {
-			obj.Description = fixName
+	}
+	if obj.call == nil {
+		var err error
+		if obj.input, err = toolMethodInput(fixName, methodType); err != nil {
+			return obj, err
 		}
-		obj.toolFunction.Description = obj.Description
+	} else if obj.input.Kind() != reflect.Struct {
+		// 泛型工具的入参需为结构体或结构体指针
+		return obj, fmt.Errorf("%w: %s input %s is not a struct", ErrToolRegisterInvalid, fixName, obj.input)
+	}
 
-		if obj.toolFunction.Parameters, err = jsonschema.GenerateSchemaForType(obj.input); err != nil {
-			log.Printf("jsonschema.GenerateSchemaForType failed => name:%s, err:%v\n", methodName, err)
-			continue
-		}
+	obj.Name = fixName
+	obj.toolFunction = ToolFunction{}
+	obj.toolFunction.Name = fixName
+	if obj.Description == "" {
+		obj.Description = fixName
+	}
+	obj.toolFunction.Description = obj.Description
 
-		if obj.toolFunction.Parameters.Properties == nil {
-			obj.toolFunction.Parameters.Properties = make(map[string]jsonschema.Definition)
+	var err error
+	if obj.toolFunction.Parameters, err = jsonschema.GenerateSchemaForType(obj.input); err != nil {
+		return obj, fmt.Errorf("%w: %s generate schema failed, %v", ErrToolRegisterInvalid, fixName, err)
+	}
+	if obj.output != nil && obj.output.Kind() != reflect.Interface {
+		// 输出定义仅用于文档及元数据，生成失败不影响注册
+		if obj.toolFunction.OutputSchema, err = jsonschema.GenerateSchemaForType(obj.output); err != nil {
+			log.Printf("jsonschema.GenerateSchemaForType failed => name:%s, err:%v\n", fixName, err)
 		}
-		if len(obj.toolFunction.Parameters.Properties) == 0 {
-			obj.toolFunction.Parameters.Properties[ToolArgumentsRawInputKey] = jsonschema.Definition{
-				Type:        jsonschema.String,
-				Description: ToolArgumentsRawInputKey,
-			}
+	}
+
+	// session绑定的参数不暴露给模型
+	obj.sessionFields = toolSessionFields(obj.input)
+	obj.sem = newSemaphore(obj.Policy.MaxConcurrency)
+	obj.toolFunction.Parameters = hideToolSessionArgs(obj.toolFunction.Parameters, obj.sessionFields)
+
+	if obj.toolFunction.Parameters.Properties == nil {
+		obj.toolFunction.Parameters.Properties = make(map[string]jsonschema.Definition)
+	}
+	if len(obj.toolFunction.Parameters.Properties) == 0 {
+		obj.toolFunction.Parameters.Properties[ToolArgumentsRawInputKey] = jsonschema.Definition{
+			Type:        jsonschema.String,
+			Description: ToolArgumentsRawInputKey,
 		}
+	}
+	return obj, nil
+}
 
-		h.toolEntrys[fixName] = obj
-		h.addMCPServerTool(obj) // 加入MCPServer
+// toolMethodInput 校验工具方法签名，返回入参结构体类型
+func toolMethodInput(fixName string, methodType reflect.Type) (reflect.Type, error) {
+	invalid := func(reason string) error {
+		return fmt.Errorf("%w: %s %s, want func(context.Context, *Input, *aihub.Message) error", ErrToolRegisterInvalid, fixName, reason)
 	}
-	return nil
+
+	// 检查方法的出入参数量
+	if methodType.NumIn() != 3 || methodType.NumOut() != 1 {
+		return nil, invalid("arguments or results count mismatch")
+	}
+
+	// 检查第一个参数是否为 Context.Context 类型
+	if methodType.In(0) != reflect.TypeOf((*context.Context)(nil)).Elem() {
+		return nil, invalid("first argument is not context.Context")
+	}
+
+	// 检查第二个参数是否为实现了 IToolInput 接口的结构体指针
+	if methodType.In(1).Kind() != reflect.Ptr || methodType.In(1).Elem().Kind() != reflect.Struct ||
+		!methodType.In(1).Implements(reflect.TypeOf((*IToolInput)(nil)).Elem()) {
+		return nil, invalid("second argument is not a struct pointer implementing IToolInput")
+	}
+
+	// 检查第三个参数是否为 *Message 类型
+	if methodType.In(2) != reflect.TypeOf((*Message)(nil)) {
+		return nil, invalid("third argument is not *Message")
+	}
+
+	// 检查第1个返回值是否为 error 类型
+	if methodType.Out(0) != reflect.TypeOf((*error)(nil)).Elem() {
+		return nil, invalid("result is not error")
+	}
+
+	// 获取第二个参数的类型（索引为 1，因为第一个是 ctx）
+	return methodType.In(1).Elem(), nil
+}
+
+// ToolEntrysOf 将结构体实例上符合工具签名的导出方法转换为工具定义，工具名为方法名，描述默认同名可按需修改后注册；
+// 签名不符的方法被跳过，并在返回的错误中逐个列出
+func ToolEntrysOf(receiver interface{}) ([]ToolEntry, error) {
+	value := reflect.ValueOf(receiver)
+	ret := make([]ToolEntry, 0)
+	errs := make([]error, 0)
+	for i := 0; i < value.NumMethod(); i++ {
+		method := value.Type().Method(i)
+		entry := ToolEntry{Name: method.Name, Function: value.Method(i).Interface()}
+		if _, err := newToolEntry(entry); err != nil {
+			errs = append(errs, fmt.Errorf("method %s skipped => %w", method.Name, err))
+			continue
+		}
+		ret = append(ret, entry)
+	}
+	return ret, errors.Join(errs...)
 }
 
 func (h *toolHub) DelTool(names ...string) error {
@@ -164,6 +262,22 @@ func (h *toolHub) ProxyCall(ctx context.Context, name string, input string, outp
 	}
 	toolEntry := tmpToolEntrys[0]
 
+	// 按参数定义校验入参
+	if err = validateToolArguments(name, toolEntry.toolFunction.Parameters, input); err != nil {
+		return err
+	}
+	if input == "" {
+		input = "{}"
+	}
+	// 校验通过后填充session绑定的参数
+	if input, err = injectToolSessionArgs(input, toolEntry.sessionFields, SessionFromContext(ctx)); err != nil {
+		return err
+	}
+
+	if toolEntry.call != nil {
+		return toolEntry.call(ctx, input, output)
+	}
+
 	// 获取结构体实例的反射值
 	inputValue := reflect.New(toolEntry.input)
 	if err = json.Unmarshal([]byte(input), inputValue.Interface()); err != nil {
@@ -220,10 +334,16 @@ func (h *toolHub) addMCPServerTool(entry ToolEntry) {
 	tmpBS, _ := json.Marshal(entry.toolFunction.Parameters)
 	inputSchema := mcp.ToolInputSchema{}
 	json.Unmarshal(tmpBS, &inputSchema)
+	// 当前mcp-go版本的Tool暂无outputSchema字段，输出定义附加在描述中
+	description := entry.toolFunction.Description
+	if entry.toolFunction.OutputSchema != nil {
+		outputBS, _ := json.Marshal(entry.toolFunction.OutputSchema)
+		description += "\n\n输出结果JSON Schema：" + string(outputBS)
+	}
 	tool := server.ServerTool{
 		Tool: mcp.Tool{
 			Name:        entry.toolFunction.Name,
-			Description: entry.toolFunction.Description,
+			Description: description,
 			InputSchema: inputSchema,
 		},
 		Handler: func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
@@ -7,6 +7,12 @@ package aihub
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/mvptianyu/aihub/jsonschema"
+	"github.com/tidwall/gjson"
+	"strings"
 	"testing"
 )
 
@@ -48,3 +54,204 @@ func Test_toolEntryHub_SetToolEntry(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func Test_toolHub_ProxyCallInvalidArguments(t *testing.T) {
+	GetToolHub().SetTool(
+		ToolEntry{
+			Function:    Method1,
+			Description: "Method1 desc",
+		},
+	)
+
+	err := GetToolHub().ProxyCall(context.Background(), "Method1", "{\"aa\":\"abc\"}", &Message{})
+	argErr := &ToolArgumentsError{}
+	if !errors.As(err, &argErr) {
+		t.Fatalf("want ToolArgumentsError, got %v", err)
+	}
+	if len(argErr.Fields) != 1 || argErr.Fields[0].Field != "aa" {
+		t.Fatalf("want invalid field aa, got %v", argErr.Fields)
+	}
+}
+
+type SessionBoundInput struct {
+	ToolInputBase
+
+	Query  string `json:"query"`
+	UserID string `json:"user_id" session:"user_id"`
+}
+
+func SessionBoundMethod(ctx context.Context, input *SessionBoundInput, output *Message) (err error) {
+	output.Content = input.UserID + ":" + input.Query
+	return nil
+}
+
+func Test_toolHub_SessionFields(t *testing.T) {
+	GetToolHub().SetTool(ToolEntry{Function: SessionBoundMethod})
+
+	params := GetToolHub().GetToolFunctions("SessionBoundMethod")[0].Parameters
+	if _, ok := params.Properties["user_id"]; ok {
+		t.Fatalf("session field should be hidden => %v", params.Properties)
+	}
+	for _, name := range params.Required {
+		if name == "user_id" {
+			t.Fatalf("session field should not be required => %v", params.Required)
+		}
+	}
+
+	session := newSession(map[string]interface{}{"user_id": "u1"})
+	ctx := ContextWithSession(context.Background(), session)
+	msg := &Message{}
+	if err := GetToolHub().ProxyCall(ctx, "SessionBoundMethod", "{\"query\":\"q\"}", msg); err != nil {
+		t.Fatal(err)
+	}
+	if msg.Content != "u1:q" {
+		t.Fatalf("want u1:q, got %s", msg.Content)
+	}
+
+	// 以session数据为准，覆盖模型传入的值
+	if err := GetToolHub().ProxyCall(ctx, "SessionBoundMethod", "{\"query\":\"q\",\"user_id\":\"u2\"}", msg); err != nil {
+		t.Fatal(err)
+	}
+	if msg.Content != "u1:q" {
+		t.Fatalf("want u1:q, got %s", msg.Content)
+	}
+}
+
+type testToolkit struct {
+	prefix string
+}
+
+func (k *testToolkit) ToolkitEcho(ctx context.Context, input *Method1Input, output *Message) (err error) {
+	output.Content = fmt.Sprintf("%s%d", k.prefix, input.AA)
+	return nil
+}
+
+func (k *testToolkit) NotTool(a int) int {
+	return a
+}
+
+func Test_toolHub_SetToolNames(t *testing.T) {
+	// 匿名函数需显式指定名称
+	closure := func(ctx context.Context, input *Method1Input, output *Message) error {
+		output.Content = "closure"
+		return nil
+	}
+	if err := GetToolHub().SetTool(ToolEntry{Function: closure}); !errors.Is(err, ErrToolRegisterInvalid) {
+		t.Fatalf("want ErrToolRegisterInvalid, got %v", err)
+	}
+	if err := GetToolHub().SetTool(ToolEntry{Name: "test_closure", Function: closure}); err != nil {
+		t.Fatal(err)
+	}
+	msg := &Message{}
+	if err := GetToolHub().ProxyCall(context.Background(), "test_closure", `{"aa":1}`, msg); err != nil || msg.Content != "closure" {
+		t.Fatalf("unexpected closure call => %v, %s", err, msg.Content)
+	}
+
+	// 方法值去除-fm后缀
+	kit := &testToolkit{prefix: "kit-"}
+	if err := GetToolHub().SetTool(ToolEntry{Function: kit.ToolkitEcho}); err != nil {
+		t.Fatal(err)
+	}
+	if err := GetToolHub().ProxyCall(context.Background(), "ToolkitEcho", `{"aa":2}`, msg); err != nil || msg.Content != "kit-2" {
+		t.Fatalf("unexpected method call => %v, %s", err, msg.Content)
+	}
+
+	// 嵌套匿名函数同样需显式指定名称
+	nested := func() interface{} {
+		return func(ctx context.Context, input *Method1Input, output *Message) error { return nil }
+	}()
+	if err := GetToolHub().SetTool(ToolEntry{Function: nested}); !errors.Is(err, ErrToolRegisterInvalid) {
+		t.Fatalf("want ErrToolRegisterInvalid for nested closure, got %v", err)
+	}
+
+	// 按实例批量生成，跳过非工具方法并返回错误
+	entrys, err := ToolEntrysOf(&testToolkit{})
+	if len(entrys) != 1 || entrys[0].Name != "ToolkitEcho" {
+		t.Fatalf("unexpected toolkit entrys => %v", entrys)
+	}
+	if !errors.Is(err, ErrToolRegisterInvalid) || !strings.Contains(err.Error(), "NotTool") {
+		t.Fatalf("want skipped method reported, got %v", err)
+	}
+
+	// 签名不符报错，且整批不注册
+	err = GetToolHub().SetTool(
+		ToolEntry{Name: "test_batch_ok", Function: closure},
+		ToolEntry{Name: "test_batch_bad", Function: kit.NotTool},
+	)
+	if !errors.Is(err, ErrToolRegisterInvalid) {
+		t.Fatalf("want ErrToolRegisterInvalid, got %v", err)
+	}
+	if len(GetToolHub().GetTool("test_batch_ok")) != 0 {
+		t.Fatal("batch should not be registered partially")
+	}
+	if err = GetToolHub().SetTool(ToolEntry{Name: "test_closure", Function: closure}); !errors.Is(err, ErrToolRegisterRepeat) {
+		t.Fatalf("want ErrToolRegisterRepeat, got %v", err)
+	}
+}
+
+type TypedWeatherInput struct {
+	City string `json:"city" description:"城市名称"`
+}
+
+type TypedWeatherOutput struct {
+	City        string  `json:"city"`
+	Temperature float64 `json:"temperature" description:"气温"`
+}
+
+func Test_toolHub_NewTool(t *testing.T) {
+	entry := NewTool("typed_weather", "查询天气", func(ctx context.Context, input *TypedWeatherInput) (TypedWeatherOutput, error) {
+		return TypedWeatherOutput{City: input.City, Temperature: 21.5}, nil
+	})
+	if err := GetToolHub().SetTool(entry); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := &Message{}
+	if err := GetToolHub().ProxyCall(context.Background(), "typed_weather", `{"city":"杭州"}`, msg); err != nil {
+		t.Fatal(err)
+	}
+	if msg.Content != `{"city":"杭州","temperature":21.5}` {
+		t.Fatalf("unexpected content => %s", msg.Content)
+	}
+	if err := GetToolHub().ProxyCall(context.Background(), "typed_weather", `{}`, msg); !errors.Is(err, ErrToolArgumentsInvalid) {
+		t.Fatalf("want ErrToolArgumentsInvalid, got %v", err)
+	}
+
+	fn := GetToolHub().GetToolFunctions("typed_weather")[0]
+	if _, ok := fn.Parameters.Properties["city"]; !ok || fn.OutputSchema == nil || fn.OutputSchema.Properties["temperature"].Type != jsonschema.Number {
+		t.Fatalf("unexpected schema => %v, %v", fn.Parameters, fn.OutputSchema)
+	}
+	if bs, _ := json.Marshal(&Tool{Type: ToolTypeFunction, Function: fn}); strings.Contains(string(bs), "temperature") {
+		t.Fatalf("output schema should not be sent to llm => %s", bs)
+	}
+
+	openapi := gjson.Get(GetToolHub().ConvertToOPENAPIConfig(), "paths.typed_weather.post.responses.200.content.application/json.schema")
+	if openapi.Get("properties.temperature.description").String() != "气温" {
+		t.Fatalf("unexpected openapi response schema => %s", openapi.Raw)
+	}
+
+	// MCP工具元数据
+	srv := GetToolHub().GetMCPServer().(*mcpServer).mcpSrv
+	rsp, _ := json.Marshal(srv.HandleMessage(context.Background(), json.RawMessage(`{"jsonrpc":"2.0","id":1,"method":"tools/list"}`)))
+	desc := gjson.GetBytes(rsp, `result.tools.#(name=="typed_weather").description`).String()
+	if !strings.Contains(desc, "查询天气") || !strings.Contains(desc, `"temperature"`) {
+		t.Fatalf("unexpected mcp tool description => %s", desc)
+	}
+
+	// 字符串结果原样返回，非结构体入参注册失败
+	echo := NewTool("typed_echo", "", func(ctx context.Context, input TypedWeatherInput) (string, error) {
+		return "echo " + input.City, nil
+	})
+	if err := GetToolHub().SetTool(echo); err != nil {
+		t.Fatal(err)
+	}
+	if err := GetToolHub().ProxyCall(context.Background(), "typed_echo", `{"city":"上海"}`, msg); err != nil || msg.Content != "echo 上海" {
+		t.Fatalf("unexpected echo => %v, %s", err, msg.Content)
+	}
+	bad := NewTool("typed_bad", "", func(ctx context.Context, input string) (string, error) {
+		return input, nil
+	})
+	if err := GetToolHub().SetTool(bad); !errors.Is(err, ErrToolRegisterInvalid) {
+		t.Fatalf("want ErrToolRegisterInvalid, got %v", err)
+	}
+}
@@ -0,0 +1,236 @@
+/*
+@Project: aihub
+@Module: aihub
+@File : tool_policy.go
+*/
+package aihub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ToolPolicy 工具调用策略
+type ToolPolicy struct {
+	Timeout        int64 `json:"timeout,omitempty" yaml:"timeout,omitempty"`                 // 单次调用超时秒数，0表示仅受整体RunTimeout限制
+	MaxConcurrency int   `json:"max_concurrency,omitempty" yaml:"max_concurrency,omitempty"` // 该工具最大并发数，0表示不限制
+	MaxRetry       int   `json:"max_retry,omitempty" yaml:"max_retry,omitempty"`             // 可重试错误的最大重试次数
+	RetryWait      int64 `json:"retry_wait,omitempty" yaml:"retry_wait,omitempty"`           // 重试初始间隔毫秒数，按指数退避
+	RetryMaxWait   int64 `json:"retry_max_wait,omitempty" yaml:"retry_max_wait,omitempty"`   // 重试最大间隔毫秒数
+}
+
+const (
+	defaultToolPolicyRetryWait    = 200
+	defaultToolPolicyRetryMaxWait = 5000
+)
+
+func (p *ToolPolicy) AutoFix() {
+	if p.Timeout < 0 {
+		p.Timeout = 0
+	}
+	if p.MaxConcurrency < 0 {
+		p.MaxConcurrency = 0
+	}
+	if p.MaxRetry < 0 {
+		p.MaxRetry = 0
+	}
+	if p.RetryWait <= 0 {
+		p.RetryWait = defaultToolPolicyRetryWait
+	}
+	if p.RetryMaxWait < p.RetryWait {
+		p.RetryMaxWait = defaultToolPolicyRetryMaxWait
+		if p.RetryMaxWait < p.RetryWait {
+			p.RetryMaxWait = p.RetryWait
+		}
+	}
+}
+
+func (p *ToolPolicy) MergeWith(src *ToolPolicy) {
+	if src == nil {
+		return
+	}
+	if src.Timeout > 0 {
+		p.Timeout = src.Timeout
+	}
+	if src.MaxConcurrency > 0 {
+		p.MaxConcurrency = src.MaxConcurrency
+	}
+	if src.MaxRetry > 0 {
+		p.MaxRetry = src.MaxRetry
+	}
+	if src.RetryWait > 0 {
+		p.RetryWait = src.RetryWait
+	}
+	if src.RetryMaxWait > 0 {
+		p.RetryMaxWait = src.RetryMaxWait
+	}
+}
+
+// getRetryWait 获取第attempt次重试前的退避间隔
+func (p *ToolPolicy) getRetryWait(attempt int) time.Duration {
+	wait := p.RetryWait
+	for i := 0; i < attempt && wait < p.RetryMaxWait; i++ {
+		wait *= 2
+	}
+	if wait > p.RetryMaxWait {
+		wait = p.RetryMaxWait
+	}
+	return time.Duration(wait) * time.Millisecond
+}
+
+// NewRetryableError 包装工具错误为可重试错误
+func NewRetryableError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return fmt.Errorf("%w: %w", ErrToolCallRetryable, err)
+}
+
+// IsRetryableError 判断工具错误是否可重试
+func IsRetryableError(err error) bool {
+	return errors.Is(err, ErrToolCallRetryable) ||
+		errors.Is(err, ErrToolCallTimeout) ||
+		errors.Is(err, ErrHTTPRequestTimeout) ||
+		errors.Is(err, ErrProviderRateLimit)
+}
+
+// ========工具并发控制============
+
+var globalToolSem chan struct{} // 进程内所有工具调用共享的并发限制
+var globalToolSemLock sync.RWMutex
+
+// SetMaxToolConcurrency 设置进程内所有工具调用（含MCP工具）共享的最大并发数，0表示不限制，仅对之后发起的调用生效
+func SetMaxToolConcurrency(limit int) {
+	globalToolSemLock.Lock()
+	defer globalToolSemLock.Unlock()
+	globalToolSem = newSemaphore(limit)
+}
+
+func getGlobalToolSemaphore() chan struct{} {
+	globalToolSemLock.RLock()
+	defer globalToolSemLock.RUnlock()
+	return globalToolSem
+}
+
+// newSemaphore 按limit创建信号量，limit<=0时返回nil表示不限制
+func newSemaphore(limit int) chan struct{} {
+	if limit <= 0 {
+		return nil
+	}
+	return make(chan struct{}, limit)
+}
+
+// getToolPolicy 获取工具注册时的调用策略及并发限制，本地工具优先，其次MCP工具
+func getToolPolicy(name string) (ToolPolicy, chan struct{}) {
+	policy := ToolPolicy{}
+	if entrys := GetToolHub().GetTool(name); len(entrys) > 0 {
+		policy.MergeWith(&entrys[0].Policy)
+		return policy, entrys[0].sem
+	}
+	tmp, sem := GetMCPHub().(*mcpHub).getToolPolicy(name)
+	policy.MergeWith(tmp)
+	return policy, sem
+}
+
+// acquireSemaphore 获取信号量，ctx结束时返回错误
+func acquireSemaphore(ctx context.Context, sem chan struct{}) error {
+	if sem == nil {
+		return nil
+	}
+	select {
+	case sem <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return context.Cause(ctx)
+	}
+}
+
+func releaseSemaphore(sem chan struct{}) {
+	if sem == nil {
+		return
+	}
+	<-sem
+}
+
+// acquireSemaphores 按顺序获取多个信号量，失败时释放已获取的
+func acquireSemaphores(ctx context.Context, sems []chan struct{}) error {
+	for i, sem := range sems {
+		if err := acquireSemaphore(ctx, sem); err != nil {
+			releaseSemaphores(sems[:i])
+			return err
+		}
+	}
+	return nil
+}
+
+func releaseSemaphores(sems []chan struct{}) {
+	for i := len(sems) - 1; i >= 0; i-- {
+		releaseSemaphore(sems[i])
+	}
+}
+
+// ToolCallFunc 工具调用入口
+type ToolCallFunc func(ctx context.Context, output *Message) error
+
+// invokeWithPolicy 按策略执行工具调用：每次调用前按顺序获取sems并发限制、单次超时、可重试错误退避重试
+func invokeWithPolicy(ctx context.Context, policy ToolPolicy, sems []chan struct{}, call ToolCallFunc, output *Message) (err error) {
+	policy.AutoFix()
+
+	for attempt := 0; attempt <= policy.MaxRetry; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(policy.getRetryWait(attempt - 1)):
+			case <-ctx.Done():
+				return context.Cause(ctx)
+			}
+		}
+
+		tmpOutput := &Message{
+			Role:         output.Role,
+			ToolCallID:   output.ToolCallID,
+			MultiContent: make([]*MessageContentPart, 0),
+		}
+		err = invokeOnce(ctx, policy, sems, call, tmpOutput)
+		if err == nil {
+			*output = *tmpOutput
+			return
+		}
+		if !IsRetryableError(err) {
+			*output = *tmpOutput
+			return
+		}
+	}
+	return
+}
+
+// invokeOnce 单次调用，超时后直接返回，信号量由工具协程结束时释放，避免超时重试突破并发限制
+func invokeOnce(ctx context.Context, policy ToolPolicy, sems []chan struct{}, call ToolCallFunc, output *Message) error {
+	if err := acquireSemaphores(ctx, sems); err != nil {
+		return err
+	}
+	if policy.Timeout <= 0 {
+		defer releaseSemaphores(sems)
+		return call(ctx, output)
+	}
+
+	callCtx, cancel := context.WithTimeoutCause(ctx, time.Duration(policy.Timeout)*time.Second, ErrToolCallTimeout)
+	defer cancel()
+
+	tmpOutput := output.Copy()
+	doneCh := make(chan error, 1)
+	go func() {
+		defer releaseSemaphores(sems)
+		doneCh <- call(callCtx, tmpOutput)
+	}()
+
+	select {
+	case err := <-doneCh:
+		*output = *tmpOutput
+		return err
+	case <-callCtx.Done():
+		return context.Cause(callCtx)
+	}
+}
@@ -0,0 +1,120 @@
+/*
+@Project: aihub
+@Module: aihub
+@File : tool_policy_test.go
+*/
+package aihub
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func Test_invokeWithPolicy(t *testing.T) {
+	ctx := context.Background()
+
+	// 可重试错误按次数重试后成功
+	attempts := 0
+	output := &Message{}
+	err := invokeWithPolicy(ctx, ToolPolicy{MaxRetry: 2, RetryWait: 1}, nil, func(ctx context.Context, output *Message) error {
+		attempts++
+		if attempts < 3 {
+			return NewRetryableError(errors.New("busy"))
+		}
+		output.Content = "ok"
+		return nil
+	}, output)
+	if err != nil || output.Content != "ok" || attempts != 3 {
+		t.Fatalf("retry failed => err:%v, content:%s, attempts:%d", err, output.Content, attempts)
+	}
+
+	// 不可重试错误直接返回
+	attempts = 0
+	err = invokeWithPolicy(ctx, ToolPolicy{MaxRetry: 2, RetryWait: 1}, nil, func(ctx context.Context, output *Message) error {
+		attempts++
+		return ErrUnknown
+	}, &Message{})
+	if !errors.Is(err, ErrUnknown) || attempts != 1 {
+		t.Fatalf("no retry failed => err:%v, attempts:%d", err, attempts)
+	}
+
+	// 单次调用超时
+	start := time.Now()
+	err = invokeWithPolicy(ctx, ToolPolicy{Timeout: 1}, nil, func(ctx context.Context, output *Message) error {
+		time.Sleep(3 * time.Second)
+		return nil
+	}, &Message{})
+	if !errors.Is(err, ErrToolCallTimeout) || time.Since(start) > 2*time.Second {
+		t.Fatalf("timeout failed => err:%v, cost:%v", err, time.Since(start))
+	}
+}
+
+func Test_getToolPolicy(t *testing.T) {
+	// 查询早于注册时不缓存，注册后按工具定义生效
+	if _, sem := getToolPolicy("test_sem_tool"); sem != nil {
+		t.Fatal("unregistered tool semaphore not nil")
+	}
+	fn := func(ctx context.Context, input *Method1Input, output *Message) error { return nil }
+	if err := GetToolHub().SetTool(ToolEntry{Name: "test_sem_tool", Function: fn, Policy: ToolPolicy{MaxConcurrency: 1}}); err != nil {
+		t.Fatal(err)
+	}
+	policy, sem := getToolPolicy("test_sem_tool")
+	if policy.MaxConcurrency != 1 || cap(sem) != 1 {
+		t.Fatalf("registered policy not applied => %+v, cap:%d", policy, cap(sem))
+	}
+
+	// 重新注册后按新定义生效
+	GetToolHub().DelTool("test_sem_tool")
+	if err := GetToolHub().SetTool(ToolEntry{Name: "test_sem_tool", Function: fn, Policy: ToolPolicy{MaxConcurrency: 3}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, tmp := getToolPolicy("test_sem_tool"); cap(tmp) != 3 || tmp == sem {
+		t.Fatalf("semaphore not replaced => cap:%d", cap(tmp))
+	}
+
+	// MCP工具策略
+	GetMCPHub().SetToolPolicy("test_mcp_sem_tool", ToolPolicy{Timeout: 3, MaxConcurrency: 2, MaxRetry: 1})
+	if policy, sem = getToolPolicy("test_mcp_sem_tool"); policy.Timeout != 3 || policy.MaxRetry != 1 || cap(sem) != 2 {
+		t.Fatalf("mcp policy not applied => %+v, cap:%d", policy, cap(sem))
+	}
+
+	// 进程内整体限制
+	SetMaxToolConcurrency(4)
+	defer SetMaxToolConcurrency(0)
+	ag, err := newAgent(&AgentConfig{BriefInfo: BriefInfo{Name: "test-sem-agent"}, AgentRuntimeCfg: AgentRuntimeCfg{LLM: "test-sem-llm"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sems := ag.(*agent).getToolSemaphores("test_mcp_sem_tool"); cap(sems[0]) != 4 || cap(sems[2]) != 2 {
+		t.Fatalf("unexpected semaphores => %v", sems)
+	}
+}
+
+func Test_invokeWithPolicy_TimeoutHoldsSlot(t *testing.T) {
+	ctx := context.Background()
+	sem := make(chan struct{}, 1)
+	release := make(chan struct{})
+
+	// 超时返回后工具协程仍在执行，应继续占用并发槽位
+	err := invokeWithPolicy(ctx, ToolPolicy{Timeout: 1}, []chan struct{}{sem}, func(ctx context.Context, output *Message) error {
+		<-release
+		return nil
+	}, &Message{})
+	if !errors.Is(err, ErrToolCallTimeout) {
+		t.Fatalf("timeout failed => err:%v", err)
+	}
+	if len(sem) != 1 {
+		t.Fatalf("slot released before call returned")
+	}
+
+	close(release)
+	deadline := time.Now().Add(time.Second)
+	for len(sem) != 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if len(sem) != 0 {
+		t.Fatalf("slot not released after call returned")
+	}
+}
@@ -0,0 +1,64 @@
+/*
+@Project: aihub
+@Module: aihub
+@File : tool_typed.go
+*/
+package aihub
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+
+	"github.com/tidwall/gjson"
+)
+
+// NewTool 创建强类型工具：入参定义由In生成，输出定义由Out生成，返回值自动序列化为JSON作为工具结果（string原样返回）。
+// In需为结构体或结构体指针，嵌入ToolInputBase时同样支持INPUT_/SESSION_参数；name为空时取函数名
+func NewTool[In any, Out any](name string, description string, fn func(ctx context.Context, input In) (Out, error)) ToolEntry {
+	inType := reflect.TypeOf((*In)(nil)).Elem()
+	structType := inType
+	if structType.Kind() == reflect.Ptr {
+		structType = structType.Elem()
+	}
+
+	entry := ToolEntry{
+		Name:        name,
+		Description: description,
+		Function:    fn,
+		input:       structType,
+		output:      reflect.TypeOf((*Out)(nil)).Elem(),
+	}
+	entry.call = func(ctx context.Context, input string, output *Message) error {
+		var in In
+		var target interface{} = &in
+		if inType.Kind() == reflect.Ptr {
+			in = reflect.New(structType).Interface().(In)
+			target = in
+		}
+		if err := json.Unmarshal([]byte(input), target); err != nil {
+			return err
+		}
+		if toolInput, ok := target.(IToolInput); ok {
+			if rawArgs := gjson.Get(input, ToolArgumentsRawInputKey).String(); rawArgs != "" {
+				toolInput.SetRawInput(rawArgs)
+			}
+		}
+
+		out, err := fn(ctx, in)
+		if err != nil {
+			return err
+		}
+		if tmp, ok := any(out).(string); ok {
+			output.Content = tmp
+			return nil
+		}
+		bs, err := json.Marshal(out)
+		if err != nil {
+			return err
+		}
+		output.Content = string(bs)
+		return nil
+	}
+	return entry
+}
@@ -5,6 +5,7 @@ import (
 	"gopkg.in/yaml.v3"
 	"log"
 	"regexp"
+	"strings"
 )
 
 // 定义用于匹配 Markdown 语法的正则表达式
@@ -63,6 +64,17 @@ func YamlDataToAgentConfig(yamlData []byte) (*AgentConfig, error) {
 	return cfg, nil
 }
 
+// TrimJSONCodeBlock 去除模型输出中包裹JSON的Markdown代码块标记，供子包复用
+func TrimJSONCodeBlock(s string) string {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "```") {
+		s = strings.TrimPrefix(s, "```json")
+		s = strings.TrimPrefix(s, "```")
+		s = strings.TrimSuffix(s, "```")
+	}
+	return strings.TrimSpace(s)
+}
+
 const ContextAIHubSessionKey = "AIHUB_SESSION"
 
 func SessionFromContext(ctx context.Context) *Session {
@@ -77,3 +89,17 @@ func SessionFromContext(ctx context.Context) *Session {
 func ContextWithSession(ctx context.Context, session *Session) context.Context {
 	return context.WithValue(ctx, ContextAIHubSessionKey, session)
 }
+
+const contextAIHubRunOptionsKey = "AIHUB_RUN_OPTIONS"
+
+// runOptionsFromContext 获取当前运行选项，用于工具内读取调用方的运行时配置
+func runOptionsFromContext(ctx context.Context) *RunOptions {
+	if tmp, ok := ctx.Value(contextAIHubRunOptionsKey).(*RunOptions); ok {
+		return tmp
+	}
+	return nil
+}
+
+func contextWithRunOptions(ctx context.Context, opts *RunOptions) context.Context {
+	return context.WithValue(ctx, contextAIHubRunOptionsKey, opts)
+}
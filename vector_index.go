@@ -0,0 +1,240 @@
+/*
+@Project: aihub
+@Module: aihub
+@File : vector_index.go
+*/
+package aihub
+
+import (
+	"bufio"
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+// VectorItem 向量索引条目
+type VectorItem struct {
+	ID         string                 `json:"id"`
+	Scope      string                 `json:"scope,omitempty"` // 隔离范围，例如用户ID或会话ID
+	Text       string                 `json:"text"`
+	Vector     []float32              `json:"vector"`
+	Meta       map[string]interface{} `json:"meta,omitempty"`
+	CreateTime int64                  `json:"create_time"`
+}
+
+// VectorHit 向量检索结果
+type VectorHit struct {
+	*VectorItem
+	Score float64 `json:"score"` // 余弦相似度
+}
+
+// VectorIndex 暴力检索的向量索引，纯Go实现；指定路径时以JSONL文件持久化，新增条目追加写入
+type VectorIndex struct {
+	path  string
+	items []*VectorItem
+	lock  sync.RWMutex
+}
+
+var (
+	vectorIndexes     = make(map[string]*VectorIndex) // path => index，同一文件共用
+	vectorIndexesLock sync.Mutex
+)
+
+// OpenVectorIndex 打开向量索引，path为空时仅保存在内存，同一路径返回同一实例
+func OpenVectorIndex(path string) (*VectorIndex, error) {
+	if path == "" {
+		return &VectorIndex{items: make([]*VectorItem, 0)}, nil
+	}
+
+	vectorIndexesLock.Lock()
+	defer vectorIndexesLock.Unlock()
+
+	if ins, ok := vectorIndexes[path]; ok {
+		return ins, nil
+	}
+	ins := &VectorIndex{path: path, items: make([]*VectorItem, 0)}
+	if err := ins.load(); err != nil {
+		return nil, err
+	}
+	vectorIndexes[path] = ins
+	return ins, nil
+}
+
+func (idx *VectorIndex) load() error {
+	f, err := os.Open(idx.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		item := &VectorItem{}
+		if err = json.Unmarshal(scanner.Bytes(), item); err != nil {
+			return err
+		}
+		idx.items = append(idx.items, item)
+	}
+	return scanner.Err()
+}
+
+// Len 条目数
+func (idx *VectorIndex) Len() int {
+	idx.lock.RLock()
+	defer idx.lock.RUnlock()
+	return len(idx.items)
+}
+
+// Add 新增条目，未设置ID和创建时间时自动填充
+func (idx *VectorIndex) Add(items ...*VectorItem) error {
+	now := time.Now().Unix()
+	for _, item := range items {
+		if item.ID == "" {
+			item.ID = uuid.NewV4().String()
+		}
+		if item.CreateTime == 0 {
+			item.CreateTime = now
+		}
+	}
+
+	idx.lock.Lock()
+	defer idx.lock.Unlock()
+
+	if idx.path != "" {
+		if err := idx.appendFile(items); err != nil {
+			return err
+		}
+	}
+	idx.items = append(idx.items, items...)
+	return nil
+}
+
+func (idx *VectorIndex) appendFile(items []*VectorItem) error {
+	if err := os.MkdirAll(filepath.Dir(idx.path), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(idx.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	encoder := json.NewEncoder(f)
+	for _, item := range items {
+		if err = encoder.Encode(item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Search 检索与vector最相似的前k条，filter为空时不过滤
+func (idx *VectorIndex) Search(vector []float32, k int, filter func(item *VectorItem) bool) []*VectorHit {
+	idx.lock.RLock()
+	ret := make([]*VectorHit, 0)
+	for _, item := range idx.items {
+		if len(item.Vector) != len(vector) || (filter != nil && !filter(item)) {
+			continue
+		}
+		ret = append(ret, &VectorHit{VectorItem: item, Score: cosineSimilarity(vector, item.Vector)})
+	}
+	idx.lock.RUnlock()
+
+	sort.SliceStable(ret, func(i, j int) bool {
+		return ret[i].Score > ret[j].Score
+	})
+	if k > 0 && len(ret) > k {
+		ret = ret[:k]
+	}
+	return ret
+}
+
+// Find 查找满足条件的条目
+func (idx *VectorIndex) Find(filter func(item *VectorItem) bool) []*VectorItem {
+	idx.lock.RLock()
+	defer idx.lock.RUnlock()
+
+	ret := make([]*VectorItem, 0)
+	for _, item := range idx.items {
+		if filter(item) {
+			ret = append(ret, item)
+		}
+	}
+	return ret
+}
+
+// Delete 删除满足条件的条目，返回删除条数，持久化时重写文件
+func (idx *VectorIndex) Delete(filter func(item *VectorItem) bool) (int, error) {
+	idx.lock.Lock()
+	defer idx.lock.Unlock()
+
+	remain := make([]*VectorItem, 0, len(idx.items))
+	for _, item := range idx.items {
+		if !filter(item) {
+			remain = append(remain, item)
+		}
+	}
+	cnt := len(idx.items) - len(remain)
+	if cnt == 0 {
+		return 0, nil
+	}
+
+	if idx.path != "" {
+		if err := idx.rewriteFile(remain); err != nil {
+			return 0, err
+		}
+	}
+	idx.items = remain
+	return cnt, nil
+}
+
+func (idx *VectorIndex) rewriteFile(items []*VectorItem) error {
+	if err := os.MkdirAll(filepath.Dir(idx.path), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(idx.path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(tmp)
+	for _, item := range items {
+		if err = encoder.Encode(item); err != nil {
+			break
+		}
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), idx.path)
+}
+
+func cosineSimilarity(a, b []float32) float64 {
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
@@ -0,0 +1,600 @@
+/*
+@Project: aihub
+@Module: aihub
+@File : workflow.go
+*/
+package aihub
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/mvptianyu/aihub/ssestream"
+	uuid "github.com/satori/go.uuid"
+	"gopkg.in/yaml.v3"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+const (
+	WorkflowStepType_Agent = "agent" // 调用AgentHub中的agent
+	WorkflowStepType_Tool  = "tool"  // 调用ToolHub中的工具
+	WorkflowStepType_MCP   = "mcp"   // 调用MCPHub中的工具
+	WorkflowStepType_LLM   = "llm"   // 直接调用LLM
+)
+
+// WorkflowConfig 声明式工作流配置，步骤间构成有向无环图
+type WorkflowConfig struct {
+	BriefInfo `yaml:",inline"` // yaml解析inline结构
+
+	Steps       []*WorkflowStep `json:"steps" yaml:"steps"`                                   // 步骤列表
+	Output      string          `json:"output,omitempty" yaml:"output,omitempty"`             // 最终输出模板，默认取最后一个步骤的输出
+	MaxParallel int             `json:"max_parallel,omitempty" yaml:"max_parallel,omitempty"` // 并行步骤最大并发数
+	RunTimeout  int64           `json:"run_timeout,omitempty" yaml:"run_timeout,omitempty"`   // 执行超时秒数
+	Claim       string          `json:"claim,omitempty" yaml:"claim,omitempty"`               // 宣称文案
+	Debug       bool            `json:"debug,omitempty" yaml:"debug,omitempty"`               // debug输出标志，开启则输出每个步骤执行过程
+}
+
+// WorkflowStep 工作流步骤，模板字段可使用：
+//
+//	{{.Input}}               工作流输入
+//	{{.Steps.fetch}}         已完成步骤fetch的输出
+//	{{.Session.user_id}}     session数据
+//	{{.Item}} / {{.Index}}   foreach展开时的当前项及序号
+type WorkflowStep struct {
+	ID        string   `json:"id" yaml:"id"`                                     // 步骤ID，唯一
+	Type      string   `json:"type" yaml:"type"`                                 // 步骤类型：agent|tool|mcp|llm
+	Target    string   `json:"target" yaml:"target"`                             // agent名称/工具名称/MCP工具名称/LLM名称
+	Input     string   `json:"input,omitempty" yaml:"input,omitempty"`           // 输入模板：agent/llm为提问文本，tool/mcp为JSON参数，默认为工作流输入
+	Prompt    string   `json:"prompt,omitempty" yaml:"prompt,omitempty"`         // llm：系统提示词模板
+	DependsOn []string `json:"depends_on,omitempty" yaml:"depends_on,omitempty"` // 依赖的步骤ID，无依赖的步骤并行执行
+	When      string   `json:"when,omitempty" yaml:"when,omitempty"`             // 条件模板，渲染结果为空、false、0时跳过该步骤
+	Foreach   string   `json:"foreach,omitempty" yaml:"foreach,omitempty"`       // 展开模板，渲染结果为JSON数组时逐项并行执行，输出为结果JSON数组
+
+	Policy      *ToolPolicy       `json:"policy,omitempty" yaml:"policy,omitempty"`             // tool/mcp：调用策略，覆盖工具注册时的默认策略
+	SessionArgs map[string]string `json:"session_args,omitempty" yaml:"session_args,omitempty"` // tool/mcp：入参绑定session数据，参数名 => session key，调用时以session数据填充
+
+	inputTpl   *template.Template
+	promptTpl  *template.Template
+	whenTpl    *template.Template
+	foreachTpl *template.Template
+}
+
+func (cfg *WorkflowConfig) AutoFix() error {
+	if cfg.MaxParallel <= 0 || cfg.MaxParallel > 20 {
+		cfg.MaxParallel = 5
+	}
+	if cfg.RunTimeout <= 0 || cfg.RunTimeout > 60*60 {
+		cfg.RunTimeout = 60 * 60
+	}
+	if cfg.Name == "" || len(cfg.Steps) == 0 {
+		return ErrConfiguration
+	}
+
+	ids := make(map[string]bool)
+	for _, step := range cfg.Steps {
+		if step.ID == "" || step.Target == "" || ids[step.ID] {
+			return fmt.Errorf("%w: workflow %s step id empty or repeated: %s", ErrConfiguration, cfg.Name, step.ID)
+		}
+		ids[step.ID] = true
+
+		switch step.Type {
+		case WorkflowStepType_Agent, WorkflowStepType_Tool, WorkflowStepType_MCP, WorkflowStepType_LLM:
+		default:
+			return fmt.Errorf("%w: workflow %s step %s unknown type %s", ErrConfiguration, cfg.Name, step.ID, step.Type)
+		}
+		if step.Input == "" {
+			step.Input = "{{.Input}}"
+		}
+	}
+
+	for _, step := range cfg.Steps {
+		for _, dep := range step.DependsOn {
+			if !ids[dep] {
+				return fmt.Errorf("%w: workflow %s step %s depends on unknown step %s", ErrConfiguration, cfg.Name, step.ID, dep)
+			}
+		}
+		if err := step.parseTemplates(); err != nil {
+			return fmt.Errorf("%w: workflow %s step %s %v", ErrConfiguration, cfg.Name, step.ID, err)
+		}
+	}
+
+	if _, err := cfg.sortSteps(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// sortSteps 拓扑排序，检测循环依赖
+func (cfg *WorkflowConfig) sortSteps() ([]*WorkflowStep, error) {
+	ret := make([]*WorkflowStep, 0)
+	done := make(map[string]bool)
+	for len(ret) < len(cfg.Steps) {
+		progress := false
+		for _, step := range cfg.Steps {
+			if done[step.ID] || !step.isReady(done) {
+				continue
+			}
+			done[step.ID] = true
+			ret = append(ret, step)
+			progress = true
+		}
+		if !progress {
+			return nil, fmt.Errorf("%w: workflow %s", ErrWorkflowCycle, cfg.Name)
+		}
+	}
+	return ret, nil
+}
+
+func (s *WorkflowStep) isReady(done map[string]bool) bool {
+	for _, dep := range s.DependsOn {
+		if !done[dep] {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *WorkflowStep) parseTemplates() (err error) {
+	if s.inputTpl, err = newWorkflowTemplate(s.Input); err != nil {
+		return
+	}
+	if s.promptTpl, err = newWorkflowTemplate(s.Prompt); err != nil {
+		return
+	}
+	if s.whenTpl, err = newWorkflowTemplate(s.When); err != nil {
+		return
+	}
+	s.foreachTpl, err = newWorkflowTemplate(s.Foreach)
+	return
+}
+
+// workflowData 步骤模板数据
+type workflowData struct {
+	Input   string
+	Steps   map[string]string
+	Session map[string]interface{}
+	Item    interface{}
+	Index   int
+}
+
+func newWorkflowTemplate(content string) (*template.Template, error) {
+	if content == "" {
+		return nil, nil
+	}
+	return template.New("workflow").Funcs(template.FuncMap{
+		"json":     promptJSON,
+		"fromJSON": workflowFromJSON,
+		"join":     promptJoin,
+		"upper":    strings.ToUpper,
+		"lower":    strings.ToLower,
+		"trim":     strings.TrimSpace,
+		"indent":   promptIndent,
+		"default":  promptDefault,
+		"contains": strings.Contains,
+	}).Parse(content)
+}
+
+func renderWorkflowTemplate(tpl *template.Template, data *workflowData) (string, error) {
+	if tpl == nil {
+		return "", nil
+	}
+	buf := &bytes.Buffer{}
+	if err := tpl.Execute(buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func workflowFromJSON(s string) (interface{}, error) {
+	var ret interface{}
+	err := json.Unmarshal([]byte(TrimJSONCodeBlock(s)), &ret)
+	return ret, err
+}
+
+type workflow struct {
+	cfg *WorkflowConfig
+}
+
+func newWorkflow(cfg *WorkflowConfig) (IAgent, error) {
+	if err := cfg.AutoFix(); err != nil {
+		return nil, err
+	}
+	return &workflow{cfg: cfg}, nil
+}
+
+func (w *workflow) GetBriefInfo() BriefInfo {
+	return w.cfg.BriefInfo
+}
+
+func (w *workflow) Run(ctx context.Context, input string, opts ...RunOptionFunc) (ret *Response) {
+	ret = &Response{}
+	options := &RunOptions{
+		RunID: uuid.NewV4().String(),
+		RuntimeCfg: AgentRuntimeCfg{
+			RunTimeout: w.cfg.RunTimeout,
+			Claim:      w.cfg.Claim,
+			Debug:      w.cfg.Debug,
+		},
+		Session: newSession(nil),
+	}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	// 检测嵌套调用的循环及深度
+	var err error
+	if ctx, err = enterCallChain(ctx, w.cfg.Name, &options.RuntimeCfg); err != nil {
+		ret.Err = err
+		return
+	}
+
+	ret.RunID = options.RunID
+	ret.Session = options.Session
+	ctx = ContextWithSession(ctx, options.Session)
+	cancelCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	if options.RuntimeCfg.RunTimeout <= 0 {
+		options.RuntimeCfg.RunTimeout = w.cfg.RunTimeout
+	}
+	newCtx, cancelTimeout := context.WithTimeoutCause(cancelCtx, time.Duration(options.RuntimeCfg.RunTimeout)*time.Second, ErrAgentRunTimeout)
+	defer cancelTimeout()
+
+	registry := GetAgentHub().GetRunRegistry().(*runRegistry)
+	if err = registry.register(w, input, options, cancel); err != nil {
+		ret.Err = err
+		return
+	}
+	defer registry.unregister(options.RunID)
+
+	options.AddStep(&RunStep{
+		Question: input,
+		StepType: StepType_Start,
+		State:    RunState_Succeed,
+	})
+	endStep := &RunStep{
+		StepType: StepType_End,
+		State:    RunState_Idle,
+	}
+
+	outputs, err := w.execute(newCtx, input, options)
+	if err == nil {
+		content := ""
+		content, err = w.renderOutput(input, outputs, options)
+		if err == nil {
+			ret.Message = &Message{
+				Role:    MessageRoleAssistant,
+				Content: content,
+			}
+			endStep.Result = content
+			endStep.State = RunState_Succeed
+		}
+	}
+
+	ret.Err = err
+	if ret.Err != nil {
+		endStep.State = RunState_Failed
+	}
+	options.AddStep(endStep)
+	ret.Content = options.RenderFinalAnswer()
+	ret.Steps = options.GetSteps()
+	return
+}
+
+type workflowStepResult struct {
+	step   *WorkflowStep
+	output string
+	err    error
+}
+
+// execute 按依赖关系调度执行步骤，无依赖关系的步骤并行执行
+func (w *workflow) execute(ctx context.Context, input string, opts *RunOptions) (map[string]string, error) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	outputs := make(map[string]string)
+	done := make(map[string]bool)
+	running := make(map[string]bool)
+	resultCh := make(chan *workflowStepResult, len(w.cfg.Steps))
+	sem := make(chan struct{}, w.cfg.MaxParallel)
+
+	for len(done) < len(w.cfg.Steps) {
+		// 启动所有依赖已完成的步骤
+		for _, step := range w.cfg.Steps {
+			if done[step.ID] || running[step.ID] || !step.isReady(done) {
+				continue
+			}
+			running[step.ID] = true
+
+			data := &workflowData{
+				Input:   input,
+				Steps:   copyStringMap(outputs),
+				Session: opts.CopySessionData(),
+			}
+			go func(step *WorkflowStep, data *workflowData) {
+				result := &workflowStepResult{step: step}
+				if result.err = acquireSemaphore(ctx, sem); result.err == nil {
+					result.output, result.err = w.runStep(ctx, step, data, opts)
+					releaseSemaphore(sem)
+				}
+				resultCh <- result
+			}(step, data)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, context.Cause(ctx)
+		case result := <-resultCh:
+			if result.err != nil {
+				err := fmt.Errorf("%w: step %s => %v", ErrWorkflowStepFailed, result.step.ID, result.err)
+				cancel(err)
+				return nil, err
+			}
+			delete(running, result.step.ID)
+			done[result.step.ID] = true
+			outputs[result.step.ID] = result.output
+		}
+	}
+	return outputs, nil
+}
+
+// runStep 执行单个步骤，处理条件判断及foreach展开
+func (w *workflow) runStep(ctx context.Context, step *WorkflowStep, data *workflowData, opts *RunOptions) (string, error) {
+	if step.whenTpl != nil {
+		cond, err := renderWorkflowTemplate(step.whenTpl, data)
+		if err != nil {
+			return "", err
+		}
+		switch strings.ToLower(strings.TrimSpace(cond)) {
+		case "", "false", "0", "no", "<no value>":
+			opts.AddStep(&RunStep{
+				Action:   step.ID,
+				Question: step.When,
+				Result:   "skipped",
+				State:    RunState_Succeed,
+				StepType: getWorkflowStepType(step),
+			})
+			return "", nil
+		}
+	}
+
+	if step.foreachTpl == nil {
+		return w.invokeStep(ctx, step, data, opts)
+	}
+
+	rendered, err := renderWorkflowTemplate(step.foreachTpl, data)
+	if err != nil {
+		return "", err
+	}
+	items := make([]interface{}, 0)
+	if err = json.Unmarshal([]byte(TrimJSONCodeBlock(rendered)), &items); err != nil {
+		return "", fmt.Errorf("foreach result is not json array: %s", rendered)
+	}
+
+	// 逐项并行执行，结果按原顺序合并
+	results := make([]string, len(items))
+	errs := make([]error, len(items))
+	sem := make(chan struct{}, w.cfg.MaxParallel)
+	wg := sync.WaitGroup{}
+	for idx, item := range items {
+		wg.Add(1)
+		go func(idx int, item interface{}) {
+			defer wg.Done()
+			if errs[idx] = acquireSemaphore(ctx, sem); errs[idx] != nil {
+				return
+			}
+			defer releaseSemaphore(sem)
+
+			itemData := *data
+			itemData.Item = item
+			itemData.Index = idx
+			results[idx], errs[idx] = w.invokeStep(ctx, step, &itemData, opts)
+		}(idx, item)
+	}
+	wg.Wait()
+
+	for _, err = range errs {
+		if err != nil {
+			return "", err
+		}
+	}
+	bs, _ := json.Marshal(results)
+	return string(bs), nil
+}
+
+// invokeStep 按步骤类型调用agent、工具、MCP或LLM
+func (w *workflow) invokeStep(ctx context.Context, step *WorkflowStep, data *workflowData, opts *RunOptions) (output string, err error) {
+	input, err := renderWorkflowTemplate(step.inputTpl, data)
+	if err != nil {
+		return "", err
+	}
+
+	runStep := &RunStep{
+		Action:   step.Target,
+		Question: input,
+		State:    RunState_Running,
+		StepType: getWorkflowStepType(step),
+	}
+	defer func() {
+		runStep.Result = output
+		runStep.State = RunState_Succeed
+		if err != nil {
+			runStep.Result = err.Error()
+			runStep.State = RunState_Failed
+		}
+		opts.AddStep(runStep)
+	}()
+
+	switch step.Type {
+	case WorkflowStepType_Agent:
+		ag := GetAgentHub().GetAgent(step.Target)
+		if ag == nil {
+			return "", fmt.Errorf("%w: %s", ErrCallNameNotMatch, step.Target)
+		}
+		rsp := ag.Run(ctx, input, WithDebug(false), WithSessionData(opts.CopySessionData()))
+		if rsp.Err != nil {
+			return "", rsp.Err
+		}
+		if rsp.Session != nil {
+			opts.MergeSessionData(rsp.Session.CopySessionData())
+		}
+		if rsp.Message != nil {
+			output = rsp.Message.Content
+		}
+	case WorkflowStepType_Tool, WorkflowStepType_MCP:
+		msg := &Message{}
+		if err = w.invokeTool(ctx, step, input, msg); err != nil {
+			return "", err
+		}
+		output = msg.Content
+	case WorkflowStepType_LLM:
+		output, err = w.invokeLLM(ctx, step, data, input)
+	}
+	return
+}
+
+// invokeTool 按与agent一致的策略调用工具：填充session绑定的参数，按工具注册时的策略限制并发、超时及重试
+func (w *workflow) invokeTool(ctx context.Context, step *WorkflowStep, input string, output *Message) (err error) {
+	if input, err = injectToolSessionArgs(input, step.SessionArgs, SessionFromContext(ctx)); err != nil {
+		return
+	}
+
+	policy, sem := getToolPolicy(step.Target)
+	policy.MergeWith(step.Policy)
+	return invokeWithPolicy(ctx, policy, []chan struct{}{getGlobalToolSemaphore(), sem}, func(ctx context.Context, output *Message) error {
+		if step.Type == WorkflowStepType_Tool {
+			return GetToolHub().ProxyCall(ctx, step.Target, input, output)
+		}
+		return GetMCPHub().ProxyCall(ctx, step.Target, input, output)
+	}, output)
+}
+
+func (w *workflow) invokeLLM(ctx context.Context, step *WorkflowStep, data *workflowData, input string) (string, error) {
+	LLMIns := GetLLMHub().GetLLM(step.Target)
+	if LLMIns == nil {
+		return "", fmt.Errorf("%w: %s", ErrCallNameNotMatch, step.Target)
+	}
+
+	messages := make([]*Message, 0)
+	if step.promptTpl != nil {
+		prompt, err := renderWorkflowTemplate(step.promptTpl, data)
+		if err != nil {
+			return "", err
+		}
+		messages = append(messages, &Message{Role: MessageRoleSystem, Content: prompt})
+	}
+	messages = append(messages, &Message{Role: MessageRoleUser, Content: input})
+
+	rsp, err := createChatCompletion(ctx, LLMIns, &CreateChatCompletionReq{Messages: messages})
+	if err != nil {
+		return "", err
+	}
+	if rsp.Error != nil {
+		return "", fmt.Errorf("%s", rsp.Error.Message)
+	}
+	if len(rsp.Choices) == 0 || rsp.Choices[0].Message == nil {
+		return "", ErrToolCallResponseEmpty
+	}
+	return rsp.Choices[0].Message.Content, nil
+}
+
+// renderOutput 渲染最终输出，未配置时取最后一个步骤的输出
+func (w *workflow) renderOutput(input string, outputs map[string]string, opts *RunOptions) (string, error) {
+	if w.cfg.Output == "" {
+		return outputs[w.cfg.Steps[len(w.cfg.Steps)-1].ID], nil
+	}
+
+	tpl, err := newWorkflowTemplate(w.cfg.Output)
+	if err != nil {
+		return "", err
+	}
+	return renderWorkflowTemplate(tpl, &workflowData{
+		Input:   input,
+		Steps:   outputs,
+		Session: opts.CopySessionData(),
+	})
+}
+
+func getWorkflowStepType(step *WorkflowStep) StepType {
+	switch step.Type {
+	case WorkflowStepType_Agent:
+		return StepType_Agent
+	case WorkflowStepType_LLM:
+		return StepType_LLM
+	default:
+		return StepType_Tool
+	}
+}
+
+func copyStringMap(src map[string]string) map[string]string {
+	ret := make(map[string]string)
+	for key, value := range src {
+		ret[key] = value
+	}
+	return ret
+}
+
+func (w *workflow) RunStream(ctx context.Context, input string, opts ...RunOptionFunc) (stream *ssestream.StreamReader[Response]) {
+	return runStream(ctx, func() *Response {
+		return w.Run(ctx, input, opts...)
+	})
+}
+
+func (w *workflow) ResetMemory(ctx context.Context, opts ...RunOptionFunc) error {
+	return nil
+}
+
+func (w *workflow) GetMemory() IMemory {
+	return nil
+}
+
+func (w *workflow) Close() error {
+	return nil
+}
+
+func (w *workflow) GetToolFunctions() []ToolFunction {
+	return []ToolFunction{}
+}
+
+func (w *workflow) InvokeToolCall(ctx context.Context, name string, args string, output *Message) (err error) {
+	return ErrCallNameNotMatch
+}
+
+// ========AgentHub注册==========
+
+func (h *agentHub) SetWorkflow(cfg *WorkflowConfig) (IAgent, error) {
+	ag, err := newWorkflow(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.setAgentIns(ag)
+	return ag, nil
+}
+
+func (h *agentHub) SetWorkflowByYamlData(yamlData []byte) (IAgent, error) {
+	cfg := &WorkflowConfig{}
+	if err := yaml.Unmarshal(yamlData, cfg); err != nil {
+		log.Printf("Error WorkflowConfig Unmarshal YAML data: %s => %v\n", string(yamlData), err)
+		return nil, err
+	}
+	return h.SetWorkflow(cfg)
+}
+
+func (h *agentHub) SetWorkflowByYamlFile(yamlFile string) (IAgent, error) {
+	// 读取 YAML 文件内容
+	yamlData, err := os.ReadFile(filepath.Clean(yamlFile))
+	if err != nil {
+		log.Printf("Error reading YAML file: %s => %v\n", yamlFile, err)
+		return nil, err
+	}
+	return h.SetWorkflowByYamlData(yamlData)
+}
@@ -0,0 +1,196 @@
+/*
+@Project: aihub
+@Module: aihub
+@File : workflow_test.go
+*/
+package aihub
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type WorkflowWeatherInput struct {
+	ToolInputBase
+
+	City string `json:"city"`
+}
+
+func WorkflowWeather(ctx context.Context, input *WorkflowWeatherInput, output *Message) (err error) {
+	output.Content = input.City + ":晴"
+	return nil
+}
+
+func Test_workflow_Run(t *testing.T) {
+	newTestLLM(t, "test-workflow-llm", func(req *CreateChatCompletionReq) *ChatCompletionRspChoice {
+		return &ChatCompletionRspChoice{
+			Message:      &Message{Role: MessageRoleAssistant, Content: `["北京","上海"]`},
+			FinishReason: ChatCompletionRspFinishReasonStop,
+		}
+	})
+	if err := GetToolHub().SetTool(ToolEntry{Function: WorkflowWeather, Description: "查询天气"}); err != nil {
+		t.Fatal(err)
+	}
+
+	wf, err := GetAgentHub().SetWorkflowByYamlData([]byte(`
+name: test-workflow
+steps:
+  - id: cities
+    type: llm
+    target: test-workflow-llm
+    prompt: 提取城市列表，输出JSON数组
+  - id: weather
+    type: tool
+    target: WorkflowWeather
+    depends_on: [cities]
+    foreach: '{{.Steps.cities}}'
+    input: '{"city":"{{.Item}}"}'
+  - id: skipped
+    type: tool
+    target: WorkflowWeather
+    depends_on: [cities]
+    when: '{{contains .Input "广州"}}'
+output: '{{range fromJSON .Steps.weather}}{{.}};{{end}}{{.Steps.skipped}}'
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if GetAgentHub().GetAgent("test-workflow") == nil {
+		t.Fatal("workflow not registered")
+	}
+
+	rsp := wf.Run(context.Background(), "北京和上海天气怎么样")
+	if rsp.Err != nil {
+		t.Fatal(rsp.Err)
+	}
+	if rsp.Message.Content != "北京:晴;上海:晴;" {
+		t.Fatalf("unexpected output => %s", rsp.Message.Content)
+	}
+
+	// 循环依赖在注册时返回
+	_, err = GetAgentHub().SetWorkflowByYamlData([]byte(`
+name: test-workflow-cycle
+steps:
+  - {id: a, type: llm, target: test-workflow-llm, depends_on: [b]}
+  - {id: b, type: llm, target: test-workflow-llm, depends_on: [a]}
+`))
+	if !errors.Is(err, ErrWorkflowCycle) {
+		t.Fatalf("want ErrWorkflowCycle, got %v", err)
+	}
+
+	// 步骤失败
+	wf, _ = GetAgentHub().SetWorkflow(&WorkflowConfig{
+		BriefInfo: BriefInfo{Name: "test-workflow-failed"},
+		Steps:     []*WorkflowStep{{ID: "a", Type: WorkflowStepType_Agent, Target: "not-exist-agent"}},
+	})
+	if rsp = wf.Run(context.Background(), "hi"); !errors.Is(rsp.Err, ErrWorkflowStepFailed) {
+		t.Fatalf("want ErrWorkflowStepFailed, got %v", rsp.Err)
+	}
+}
+
+func Test_workflow_RunParallelSession(t *testing.T) {
+	newTestLLM(t, "test-workflow-session-llm", func(req *CreateChatCompletionReq) *ChatCompletionRspChoice {
+		time.Sleep(200 * time.Millisecond)
+		return &ChatCompletionRspChoice{
+			Message:      &Message{Role: MessageRoleAssistant, Content: "ok"},
+			FinishReason: ChatCompletionRspFinishReasonStop,
+		}
+	})
+	for name, data := range map[string]map[string]interface{}{
+		"test-workflow-session-a": {"a": "1"},
+		"test-workflow-session-b": {"b": "2"},
+	} {
+		if _, err := GetAgentHub().SetAgent(&AgentConfig{
+			BriefInfo:       BriefInfo{Name: name},
+			AgentRuntimeCfg: AgentRuntimeCfg{LLM: "test-workflow-session-llm"},
+			SessionData:     data,
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(GetToolHub().GetTool("WorkflowWeather")) == 0 {
+		if err := GetToolHub().SetTool(ToolEntry{Function: WorkflowWeather, Description: "查询天气"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 两个并行agent步骤写入session数据，期间其他步骤渲染模板读取session数据，需在-race下通过
+	wf, err := GetAgentHub().SetWorkflowByYamlData([]byte(`
+name: test-workflow-session
+steps:
+  - {id: a, type: agent, target: test-workflow-session-a, input: '{{.Session.user}}'}
+  - {id: b, type: agent, target: test-workflow-session-b, input: '{{.Session.user}}'}
+  - {id: c, type: tool, target: WorkflowWeather, input: '{"city":"{{.Session.user}}"}'}
+  - {id: d, type: tool, target: WorkflowWeather, depends_on: [c], input: '{"city":"{{.Session.user}}"}'}
+output: '{{.Session.user}}-{{.Session.a}}-{{.Session.b}}-{{.Steps.d}}'
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rsp := wf.Run(context.Background(), "test", WithSessionData(map[string]interface{}{"user": "u1"}))
+	if rsp.Err != nil {
+		t.Fatal(rsp.Err)
+	}
+	if rsp.Message.Content != "u1-1-2-u1:晴" {
+		t.Fatalf("unexpected output => %s", rsp.Message.Content)
+	}
+}
+
+func Test_workflow_RunToolPolicy(t *testing.T) {
+	var calls int32
+	flaky := func(ctx context.Context, input *WorkflowWeatherInput, output *Message) error {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			return NewRetryableError(errors.New("busy"))
+		}
+		output.Content = input.City + ":晴"
+		return nil
+	}
+	if err := GetToolHub().SetTool(ToolEntry{Name: "test_workflow_flaky", Function: flaky}); err != nil {
+		t.Fatal(err)
+	}
+	newTestLLM(t, "test-workflow-slow-llm", func(req *CreateChatCompletionReq) *ChatCompletionRspChoice {
+		time.Sleep(2 * time.Second)
+		return &ChatCompletionRspChoice{
+			Message:      &Message{Role: MessageRoleAssistant, Content: "ok"},
+			FinishReason: ChatCompletionRspFinishReasonStop,
+		}
+	})
+
+	// 工具步骤按策略重试，并从session填充绑定的参数
+	wf, err := GetAgentHub().SetWorkflowByYamlData([]byte(`
+name: test-workflow-policy
+steps:
+  - id: weather
+    type: tool
+    target: test_workflow_flaky
+    input: '{"city":"广州"}'
+    policy: {max_retry: 1, retry_wait: 10}
+    session_args: {city: city}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsp := wf.Run(context.Background(), "天气", WithSessionData(map[string]interface{}{"city": "深圳"}))
+	if rsp.Err != nil || rsp.Message.Content != "深圳:晴" || calls != 2 {
+		t.Fatalf("unexpected output => %v, %v, calls:%d", rsp.Message, rsp.Err, calls)
+	}
+
+	// 按本次运行的超时设置执行
+	wf, err = GetAgentHub().SetWorkflowByYamlData([]byte(`
+name: test-workflow-timeout
+steps:
+  - {id: slow, type: llm, target: test-workflow-slow-llm}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	rsp = wf.Run(context.Background(), "hi", WithRuntimeCfg(AgentRuntimeCfg{RunTimeout: 1}))
+	if rsp.Err == nil || time.Since(start) > 1800*time.Millisecond {
+		t.Fatalf("want run timeout => err:%v, cost:%v", rsp.Err, time.Since(start))
+	}
+}